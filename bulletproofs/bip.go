@@ -18,14 +18,12 @@
 package bulletproofs
 
 import (
-    "crypto/sha256"
     "errors"
     "math/big"
-    "fmt"
 
     "github.com/ing-bank/zkrp/crypto/p256"
+    "github.com/ing-bank/zkrp/crypto/transcript"
     "github.com/ing-bank/zkrp/util/bn"
-    "github.com/ing-bank/zkrp/util/byteconversion"
 )
 
 var SEEDU = "BulletproofsDoesNotNeedTrustedSetupU"
@@ -57,7 +55,24 @@ type InnerProductProof struct {
     Hh     *p256.P256
     A      *big.Int
     B      *big.Int
-    Params InnerProductParams
+}
+
+/*
+points returns the points of the proof, which are normalized together with the
+points of the range proofs.
+*/
+func (proof *InnerProductProof) points() []*p256.P256 {
+    points := []*p256.P256{proof.U, proof.P, proof.Gg, proof.Hh}
+    points = append(points, proof.Ls...)
+    return append(points, proof.Rs...)
+}
+
+/*
+check returns ErrInvalidProof if an element of the proof is missing.
+*/
+func (proof *InnerProductProof) check() error {
+    points := append(append([]*p256.P256{}, proof.Ls...), proof.Rs...)
+    return checkElements(points, proof.A, proof.B)
 }
 
 /*
@@ -73,14 +88,14 @@ func SetupInnerProduct(H *p256.P256, g, h []*p256.P256, c *big.Int, N int64) (In
         params.N = N
     }
     if H == nil {
-        params.H, _ = p256.MapToGroup(SEEDH)
+        params.H, _ = generator(SEEDH)
     } else {
         params.H = H
     }
     if g == nil {
         params.Gg = make([]*p256.P256, params.N)
         for i := int64(0); i < params.N; i++ {
-            params.Gg[i], _ = p256.MapToGroup(SEEDH + "g" + fmt.Sprint(i))
+            params.Gg[i], _ = generatorG(i)
         }
     } else {
         params.Gg = g
@@ -88,22 +103,43 @@ func SetupInnerProduct(H *p256.P256, g, h []*p256.P256, c *big.Int, N int64) (In
     if h == nil {
         params.Hh = make([]*p256.P256, params.N)
         for i := int64(0); i < params.N; i++ {
-            params.Hh[i], _ = p256.MapToGroup(SEEDH + "h" + fmt.Sprint(i))
+            params.Hh[i], _ = generatorH(i)
         }
     } else {
         params.Hh = h
     }
     params.Cc = c
-    params.Uu, _ = p256.MapToGroup(SEEDU)
+    params.Uu, _ = generator(SEEDU)
     params.P = new(p256.P256).SetInfinity()
 
     return params, nil
 }
 
+/*
+newInnerProductTranscript starts the transcript of a standalone inner product
+argument, binding the generators.
+*/
+func newInnerProductTranscript(params InnerProductParams) *transcript.Transcript {
+    t := transcript.New("zkrp bulletproofs inner product")
+    t.AppendInt64("n", params.N)
+    appendPoints(t, "g", params.Gg...)
+    appendPoints(t, "h", params.Hh...)
+    appendPoints(t, "u", params.Uu)
+    return t
+}
+
 /*
 ProveInnerProduct calculates the Zero Knowledge Proof for the Inner Product argument.
 */
 func ProveInnerProduct(a, b []*big.Int, P *p256.P256, params InnerProductParams) (InnerProductProof, error) {
+    return proveInnerProduct(newInnerProductTranscript(params), a, b, P, params)
+}
+
+/*
+proveInnerProduct computes the Inner Product argument, deriving the challenges from
+t, which allows to bind them to the range proof when used by Prove.
+*/
+func proveInnerProduct(t *transcript.Transcript, a, b []*big.Int, P *p256.P256, params InnerProductParams) (InnerProductProof, error) {
     var (
         proof InnerProductProof
         n, m  int64
@@ -120,27 +156,26 @@ func ProveInnerProduct(a, b []*big.Int, P *p256.P256, params InnerProductParams)
 
     // Fiat-Shamir:
     // x = Hash(g,h,P,c)
-    x, _ := HashIP(params.Gg, params.Hh, P, params.Cc, params.N)
+    appendPoints(t, "P", P)
+    appendScalars(t, "c", params.Cc)
+    x := challenge(t, "w")
     // Pprime = P.u^(x.c)
     ux := new(p256.P256).ScalarMult(params.Uu, x)
-    uxc := new(p256.P256).ScalarMult(ux, params.Cc)
-    PP := new(p256.P256).Multiply(P, uxc)
+    PP, _ := VectorExp([]*p256.P256{P, params.Uu}, []*big.Int{big.NewInt(1), bn.Multiply(x, params.Cc)})
     // Execute Protocol 2 recursively
-    proof = ComputeBipRecursive(a, b, params.Gg, params.Hh, ux, PP, n, Ls, Rs)
-    proof.Params = params
-    proof.Params.P = PP
+    proof = ComputeBipRecursive(t, a, b, params.Gg, params.Hh, ux, PP, n, Ls, Rs)
     return proof, nil
 }
 
 /*
 ComputeBipRecursive is the main recursive function that will be used to compute the inner product argument.
 */
-func ComputeBipRecursive(a, b []*big.Int, g, h []*p256.P256, u, P *p256.P256, n int64, Ls, Rs []*p256.P256) InnerProductProof {
+func ComputeBipRecursive(t *transcript.Transcript, a, b []*big.Int, g, h []*p256.P256, u, P *p256.P256, n int64, Ls, Rs []*p256.P256) InnerProductProof {
     var (
         proof                            InnerProductProof
         cL, cR, x, xinv, x2, x2inv       *big.Int
-        L, R, Lh, Rh, Pprime             *p256.P256
-        gprime, hprime, gprime2, hprime2 []*p256.P256
+        L, R, Pprime                     *p256.P256
+        gprime, hprime                   []*p256.P256
         aprime, bprime, aprime2, bprime2 []*big.Int
     )
 
@@ -166,36 +201,26 @@ func ComputeBipRecursive(a, b []*big.Int, g, h []*p256.P256, u, P *p256.P256, n
         // Compute cR = < a[n':], b[:n'] >                                    // (22)
         cR, _ = ScalarProduct(a[nprime:], b[:nprime])
         // Compute L = g[n':]^(a[:n']).h[:n']^(b[n':]).u^cL                   // (23)
-        L, _ = VectorExp(g[nprime:], a[:nprime])
-        Lh, _ = VectorExp(h[:nprime], b[nprime:])
-        L.Multiply(L, Lh)
-        L.Multiply(L, new(p256.P256).ScalarMult(u, cL))
+        L = commitCross(g[nprime:], h[:nprime], u, a[:nprime], b[nprime:], cL)
 
         // Compute R = g[:n']^(a[n':]).h[n':]^(b[:n']).u^cR                   // (24)
-        R, _ = VectorExp(g[:nprime], a[nprime:])
-        Rh, _ = VectorExp(h[nprime:], b[:nprime])
-        R.Multiply(R, Rh)
-        R.Multiply(R, new(p256.P256).ScalarMult(u, cR))
+        R = commitCross(g[:nprime], h[nprime:], u, a[nprime:], b[:nprime], cR)
 
         // Fiat-Shamir:                                                       // (26)
-        x, _, _ = HashBP(L, R)
+        appendPoints(t, "L", L)
+        appendPoints(t, "R", R)
+        x = challenge(t, "u")
         xinv = bn.ModInverse(x, ORDER)
 
         // Compute g' = g[:n']^(x^-1) * g[n':]^(x)                            // (29)
-        gprime = VectorScalarExp(g[:nprime], xinv)
-        gprime2 = VectorScalarExp(g[nprime:], x)
-        gprime, _ = VectorECAdd(gprime, gprime2)
+        gprime = foldGenerators(g[:nprime], g[nprime:], xinv, x)
         // Compute h' = h[:n']^(x)    * h[n':]^(x^-1)                         // (30)
-        hprime = VectorScalarExp(h[:nprime], x)
-        hprime2 = VectorScalarExp(h[nprime:], xinv)
-        hprime, _ = VectorECAdd(hprime, hprime2)
+        hprime = foldGenerators(h[:nprime], h[nprime:], x, xinv)
 
         // Compute P' = L^(x^2).P.R^(x^-2)                                    // (31)
         x2 = bn.Mod(bn.Multiply(x, x), ORDER)
         x2inv = bn.ModInverse(x2, ORDER)
-        Pprime = new(p256.P256).ScalarMult(L, x2)
-        Pprime.Multiply(Pprime, P)
-        Pprime.Multiply(Pprime, new(p256.P256).ScalarMult(R, x2inv))
+        Pprime, _ = VectorExp([]*p256.P256{L, P, R}, []*big.Int{x2, big.NewInt(1), x2inv})
 
         // Compute a' = a[:n'].x      + a[n':].x^(-1)                         // (33)
         aprime, _ = VectorScalarMul(a[:nprime], x)
@@ -209,81 +234,125 @@ func ComputeBipRecursive(a, b []*big.Int, g, h []*p256.P256, u, P *p256.P256, n
         Ls = append(Ls, L)
         Rs = append(Rs, R)
         // recursion ComputeBipRecursive(g',h',u,P'; a', b')                  // (35)
-        proof = ComputeBipRecursive(aprime, bprime, gprime, hprime, u, Pprime, nprime, Ls, Rs)
+        proof = ComputeBipRecursive(t, aprime, bprime, gprime, hprime, u, Pprime, nprime, Ls, Rs)
     }
     proof.N = n
     return proof
 }
 
 /*
-Verify is responsible for the verification of the Inner Product Proof.
+Verify is responsible for the verification of the Inner Product Proof. The
+statement is given by the verifier: params.P must be the commitment to a and b,
+and params.Cc the claimed inner product.
+*/
+func (proof InnerProductProof) Verify(params InnerProductParams) (bool, error) {
+    return proof.verify(newInnerProductTranscript(params), params)
+}
+
+/*
+verify checks the Inner Product Proof, deriving the challenges from t.
 */
-func (proof InnerProductProof) Verify() (bool, error) {
+func (proof InnerProductProof) verify(t *transcript.Transcript, params InnerProductParams) (bool, error) {
 
     logn := len(proof.Ls)
-    var (
-        x, xinv, x2, x2inv                   *big.Int
-        ngprime, nhprime, ngprime2, nhprime2 []*p256.P256
-    )
 
-    gprime := proof.Params.Gg
-    hprime := proof.Params.Hh
-    Pprime := proof.Params.P
-    nprime := proof.N
-    for i := int64(0); i < int64(logn); i++ {
-        nprime = nprime / 2                        // (20)
-        x, _, _ = HashBP(proof.Ls[i], proof.Rs[i]) // (26)
-        xinv = bn.ModInverse(x, ORDER)
-        // Compute g' = g[:n']^(x^-1) * g[n':]^(x)                            // (29)
-        ngprime = VectorScalarExp(gprime[:nprime], xinv)
-        ngprime2 = VectorScalarExp(gprime[nprime:], x)
-        gprime, _ = VectorECAdd(ngprime, ngprime2)
-        // Compute h' = h[:n']^(x)    * h[n':]^(x^-1)                         // (30)
-        nhprime = VectorScalarExp(hprime[:nprime], x)
-        nhprime2 = VectorScalarExp(hprime[nprime:], xinv)
-        hprime, _ = VectorECAdd(nhprime, nhprime2)
-        // Compute P' = L^(x^2).P.R^(x^-2)                                    // (31)
-        x2 = bn.Mod(bn.Multiply(x, x), ORDER)
-        x2inv = bn.ModInverse(x2, ORDER)
-        Pprime.Multiply(Pprime, new(p256.P256).ScalarMult(proof.Ls[i], x2))
-        Pprime.Multiply(Pprime, new(p256.P256).ScalarMult(proof.Rs[i], x2inv))
+    if int64(len(params.Gg)) != params.N || int64(len(params.Hh)) != params.N {
+        return false, errors.New("invalid inner product parameters")
+    }
+    if len(proof.Rs) != logn || logn >= 63 || int64(1)<<uint(logn) != params.N {
+        return false, errors.New("inner product proof does not match the parameters")
+    }
+    if params.P == nil {
+        return false, errors.New("inner product proof is incomplete")
+    }
+    if err := proof.check(); err != nil {
+        return false, err
     }
 
-    // c == a*b and checks if P = g^a.h^b.u^c                                     // (16)
-    ab := bn.Multiply(proof.A, proof.B)
-    ab = bn.Mod(ab, ORDER)
-    // Compute right hand side
-    rhs := new(p256.P256).ScalarMult(gprime[0], proof.A)
-    hb := new(p256.P256).ScalarMult(hprime[0], proof.B)
-    rhs.Multiply(rhs, hb)
-    rhs.Multiply(rhs, new(p256.P256).ScalarMult(proof.U, ab))
-    // Compute inverse of left hand side
-    nP := Pprime.Neg(Pprime)
-    nP.Multiply(nP, rhs)
-    // If both sides are equal then nP must be zero                               // (17)
-    c := nP.IsZero()
-
-    return c, nil
+    // Fiat-Shamir:
+    // x = Hash(g,h,P,c)
+    appendPoints(t, "P", params.P)
+    appendScalars(t, "c", params.Cc)
+    x := challenge(t, "w")
+
+    // The whole verification equation is checked with a single multi-exponentiation:
+    // P.u^(x.(c-ab)).prod(L^(x^2).R^(x^-2)).g^(-a.s).h^(-b.s^-1) = 1                // (16)
+    // where g'[0] = g^s and h'[0] = h^(s^-1) are the generators after the recursion
+    ab := bn.Mod(bn.Multiply(proof.A, proof.B), ORDER)
+    points := []*p256.P256{params.P, params.Uu}
+    scalars := []*big.Int{big.NewInt(1), bn.Multiply(x, bn.Sub(params.Cc, ab))}
+    xs := make([]*big.Int, logn)
+    for i := 0; i < logn; i++ {
+        // Fiat-Shamir:                                                       // (26)
+        appendPoints(t, "L", proof.Ls[i])
+        appendPoints(t, "R", proof.Rs[i])
+        xs[i] = challenge(t, "u")
+        // Compute P' = L^(x^2).P.R^(x^-2)                                    // (31)
+        x2 := bn.Mod(bn.Multiply(xs[i], xs[i]), ORDER)
+        points = append(points, proof.Ls[i], proof.Rs[i])
+        scalars = append(scalars, x2, bn.ModInverse(x2, ORDER))
+    }
+    s, sinv := challengeProducts(xs, params.N)
+    for i := int64(0); i < params.N; i++ {
+        points = append(points, params.Gg[i], params.Hh[i])
+        scalars = append(scalars, bn.Sub(ORDER, bn.Multiply(proof.A, s[i])), bn.Sub(ORDER, bn.Multiply(proof.B, sinv[i])))
+    }
+    result, _ := VectorExp(points, scalars)
+    // If both sides are equal then the result must be zero                        // (17)
+    return result.IsZero(), nil
 }
 
 /*
-HashIP is responsible for the computing a Zp element given elements from GT and G1.
+challengeProducts returns the exponents s and s^-1 such that the generators obtained
+after the log2(n) rounds of the inner product argument with challenges xs are
+g'[0] = g^s and h'[0] = h^(s^-1). s[i] is the product of xs[j] if the bit j of i,
+counted from the most significant one, is set, and of xs[j]^-1 otherwise.
 */
-func HashIP(g, h []*p256.P256, P *p256.P256, c *big.Int, n int64) (*big.Int, error) {
-    digest := sha256.New()
-    digest.Write([]byte(P.String()))
-
+func challengeProducts(xs []*big.Int, n int64) ([]*big.Int, []*big.Int) {
+    logn := int64(len(xs))
+    xinvs := make([]*big.Int, logn)
+    for j := int64(0); j < logn; j++ {
+        xinvs[j] = bn.ModInverse(xs[j], ORDER)
+    }
+    s := make([]*big.Int, n)
+    sinv := make([]*big.Int, n)
     for i := int64(0); i < n; i++ {
-        digest.Write([]byte(g[i].String()))
-        digest.Write([]byte(h[i].String()))
+        s[i] = new(big.Int).SetInt64(1)
+        sinv[i] = new(big.Int).SetInt64(1)
+        for j := int64(0); j < logn; j++ {
+            if (i>>uint(logn-1-j))&1 == 1 {
+                s[i] = bn.Mod(bn.Multiply(s[i], xs[j]), ORDER)
+                sinv[i] = bn.Mod(bn.Multiply(sinv[i], xinvs[j]), ORDER)
+            } else {
+                s[i] = bn.Mod(bn.Multiply(s[i], xinvs[j]), ORDER)
+                sinv[i] = bn.Mod(bn.Multiply(sinv[i], xs[j]), ORDER)
+            }
+        }
     }
+    return s, sinv
+}
 
-    digest.Write([]byte(c.String()))
-    output := digest.Sum(nil)
-    tmp := output[0:]
-    result, err := byteconversion.FromByteArray(tmp)
+/*
+commitCross computes g^a.h^b.u^c, i.e. the values L and R of each round of the
+inner product argument.
+*/
+func commitCross(g, h []*p256.P256, u *p256.P256, a, b []*big.Int, c *big.Int) *p256.P256 {
+    points := append(append([]*p256.P256{u}, g...), h...)
+    scalars := append(append([]*big.Int{c}, a...), b...)
+    result, _ := VectorExp(points, scalars)
+    return result
+}
 
-    return result, err
+/*
+foldGenerators computes g[i]^a.h[i]^b for each i, i.e. the generators of the next
+round of the inner product argument.
+*/
+func foldGenerators(g, h []*p256.P256, a, b *big.Int) []*p256.P256 {
+    result := make([]*p256.P256, len(g))
+    for i := range g {
+        result[i] = p256.MultiScalarMult([]*p256.P256{g[i], h[i]}, []*big.Int{a, b})
+    }
+    return result
 }
 
 /*
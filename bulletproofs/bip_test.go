@@ -32,7 +32,7 @@ func TestInnerProduct(t *testing.T) {
         b                  []*big.Int
     )
     c := new(big.Int).SetInt64(142)
-    innerProductParams, _ = setupInnerProduct(nil, nil, nil, c, 4)
+    innerProductParams, _ = SetupInnerProduct(nil, nil, nil, c, 4)
 
     a = make([]*big.Int, innerProductParams.N)
     a[0] = new(big.Int).SetInt64(2)
@@ -44,11 +44,19 @@ func TestInnerProduct(t *testing.T) {
     b[1] = new(big.Int).SetInt64(2)
     b[2] = new(big.Int).SetInt64(10)
     b[3] = new(big.Int).SetInt64(7)
-    commit := commitInnerProduct(innerProductParams.Gg, innerProductParams.Hh, a, b)
+    commit := CommitInnerProduct(innerProductParams.Gg, innerProductParams.Hh, a, b)
 
-    proof, _ := proveInnerProduct(a, b, commit, innerProductParams)
-    ok, _ := proof.Verify()
+    proof, _ := ProveInnerProduct(a, b, commit, innerProductParams)
+    innerProductParams.P = commit
+    ok, _ := proof.Verify(innerProductParams)
     if ok != true {
         t.Errorf("Assert failure: expected true, actual: %t", ok)
     }
+
+    // the verifier's statement c = <a,b> does not hold for a different c
+    innerProductParams.Cc = new(big.Int).SetInt64(143)
+    ok, _ = proof.Verify(innerProductParams)
+    if ok != false {
+        t.Errorf("Assert failure: expected false, actual: %t", ok)
+    }
 }
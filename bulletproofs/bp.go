@@ -18,13 +18,14 @@
 package bulletproofs
 
 import (
-    "math/rand"
+    "crypto/rand"
     "errors"
     "fmt"
-    "math"
+    "io"
     "math/big"
 
     "github.com/ing-bank/zkrp/crypto/p256"
+    "github.com/ing-bank/zkrp/crypto/transcript"
     . "github.com/ing-bank/zkrp/util"
     "github.com/ing-bank/zkrp/util/bn"
 )
@@ -38,10 +39,10 @@ type BulletProofSetupParams struct {
     N int64
     // G is the Elliptic Curve generator.
     G *p256.P256
-    // H is a new generator, computed using MapToGroup function,
+    // H is a new generator, computed using HashToCurve,
     // such that there is no discrete logarithm relation with G.
     H *p256.P256
-    // Gg and Hh are sets of new generators obtained using MapToGroup.
+    // Gg and Hh are sets of new generators obtained using HashToCurve.
     // They are used to compute Pedersen Vector Commitments.
     Gg []*p256.P256
     Hh []*p256.P256
@@ -64,79 +65,138 @@ type BulletProof struct {
     Tprime            *big.Int
     InnerProductProof InnerProductProof
     Commit            *p256.P256
-    Params            BulletProofSetupParams
+    // ParamsID identifies the public parameters used by the prover.
+    ParamsID          ParamsID
 }
 
 /*
-SetupInnerProduct is responsible for computing the common parameters.
-Only works for ranges to 0 to 2^n, where n is a power of 2 and n <= 32
-TODO: allow n > 32 (need uint64 for that).
+Setup is responsible for computing the common parameters for ranges [0, b), where
+b = 2^n and 1 <= n <= 64. When n is not a power of 2, the vectors of generators
+are padded to the next power of 2, which is required by the inner product argument.
 */
-func Setup(b int64) (BulletProofSetupParams, error) {
-    if !IsPowerOfTwo(b) {
+func Setup(b *big.Int) (BulletProofSetupParams, error) {
+    if b == nil || b.Sign() <= 0 || b.TrailingZeroBits() != uint(b.BitLen()-1) {
         return BulletProofSetupParams{}, errors.New("range end is not a power of 2")
     }
 
     params := BulletProofSetupParams{}
     params.G = new(p256.P256).ScalarBaseMult(new(big.Int).SetInt64(1))
-    params.H, _ = p256.MapToGroup(SEEDH)
-    params.N = int64(math.Log2(float64(b)))
-    if !IsPowerOfTwo(params.N) {
-        return BulletProofSetupParams{}, fmt.Errorf("range end is a power of 2, but it's exponent should also be. Exponent: %d", params.N)
+    params.H, _ = generator(SEEDH)
+    params.N = int64(b.BitLen() - 1)
+    if params.N < 1 {
+        return BulletProofSetupParams{}, errors.New("range end must be at least 2")
     }
-    if params.N > 32 {
-        return BulletProofSetupParams{}, errors.New("range end can not be greater than 2**32")
+    if params.N > MAX_RANGE_BITS {
+        return BulletProofSetupParams{}, fmt.Errorf("range end can not be greater than 2**%d", MAX_RANGE_BITS)
     }
-    params.Gg = make([]*p256.P256, params.N)
-    params.Hh = make([]*p256.P256, params.N)
-    for i := int64(0); i < params.N; i++ {
-        params.Gg[i], _ = p256.MapToGroup(SEEDH + "g" + fmt.Sprint(i))
-        params.Hh[i], _ = p256.MapToGroup(SEEDH + "h" + fmt.Sprint(i))
+    size := nextPowerOfTwo(params.N)
+    params.Gg = make([]*p256.P256, size)
+    params.Hh = make([]*p256.P256, size)
+    for i := int64(0); i < size; i++ {
+        params.Gg[i], _ = generatorG(i)
+        params.Hh[i], _ = generatorH(i)
+    }
+    // Every commitment multiplies these bases, so their tables are built only once.
+    // The tables are only used by affine points, and the bases are not shared yet.
+    bases := append(append([]*p256.P256{params.G, params.H}, params.Gg...), params.Hh...)
+    p256.BatchNormalize(bases...)
+    p256.Precompute(bases...)
+    if err := registerParams(params); err != nil {
+        return BulletProofSetupParams{}, err
     }
     return params, nil
 }
 
+/*
+newRangeTranscript starts the transcript of a range proof. The generators and the
+range [0, 2^n) are bound through the parameters ID, followed by the commitment V.
+*/
+func newRangeTranscript(id ParamsID, n int64, V *p256.P256) *transcript.Transcript {
+    t := transcript.New("zkrp bulletproofs range proof")
+    t.AppendMessage("params", id[:])
+    t.AppendInt64("n", n)
+    appendPoints(t, "V", V)
+    return t
+}
+
 /*
 Prove computes the ZK rangeproof. The documentation and comments are based on
 eprint version of Bulletproofs papers:
 https://eprint.iacr.org/2017/1066.pdf
+The blinding values are drawn from crypto/rand.
 */
 func Prove(secret *big.Int, params BulletProofSetupParams, gamma *big.Int) (BulletProof, error) {
+    return ProveWithReader(rand.Reader, secret, params, gamma)
+}
+
+/*
+ProveWithReader computes the ZK rangeproof drawing the blinding values from rnd.
+*/
+func ProveWithReader(rnd io.Reader, secret *big.Int, params BulletProofSetupParams, gamma *big.Int) (BulletProof, error) {
     var (
         proof BulletProof
     )
-    
-    seed := new(big.Int).Mod(gamma, big.NewInt(^int64(0))).Int64()
-    
-    rand.Seed(seed)
-    
+    size, err := params.generatorsSize()
+    if err != nil {
+        return proof, err
+    }
+    id, err := params.ID()
+    if err != nil {
+        return proof, err
+    }
+
     // ////////////////////////////////////////////////////////////////////////////
     // First phase: page 19
     // ////////////////////////////////////////////////////////////////////////////
 
     // commitment to v and gamma
     V, _ := CommitG1(secret, gamma, params.H)
+    t := newRangeTranscript(id, params.N, V)
 
     // aL, aR and commitment: (A, alpha)
-    aL, _ := Decompose(secret, 2, params.N)                                     // (41)
+    aL, err := Decompose(secret, 2, params.N)                                   // (41)
+    if err != nil {
+        return proof, err
+    }
     aR, _ := computeAR(aL)                                                      // (42)
-    alpha := big.NewInt(int64(rand.Int()))                                           // (43)
+    alpha, err := rand.Int(rnd, ORDER)                                          // (43)
+    if err != nil {
+        return proof, err
+    }
     A := commitVector(aL, aR, alpha, params.H, params.Gg, params.Hh, params.N)  // (44)
-    
+
     // sL, sR and commitment: (S, rho)                                          // (45)
-    sL := sampleRandomVector(params.N, seed)
-    sR := sampleRandomVector(params.N, seed)
-    rho := big.NewInt(int64(rand.Int()))                                             // (46)
+    sL, err := sampleRandomVector(rnd, params.N)
+    if err != nil {
+        return proof, err
+    }
+    sR, err := sampleRandomVector(rnd, params.N)
+    if err != nil {
+        return proof, err
+    }
+    rho, err := rand.Int(rnd, ORDER)                                            // (46)
+    if err != nil {
+        return proof, err
+    }
     S := commitVectorBig(sL, sR, rho, params.H, params.Gg, params.Hh, params.N) // (47)
 
     // Fiat-Shamir heuristic to compute challenges y and z, corresponds to      // (49)
-    y, z, _ := HashBP(A, S)
+    appendPoints(t, "A", A)
+    appendPoints(t, "S", S)
+    y := challenge(t, "y")
+    z := challenge(t, "z")
 
     // ////////////////////////////////////////////////////////////////////////////
     // Second phase: page 20
     // ////////////////////////////////////////////////////////////////////////////
-    tau1 := big.NewInt(int64(rand.Int())) // (52)
-    tau2 := big.NewInt(int64(rand.Int())) // (52)
+    tau1, err := rand.Int(rnd, ORDER) // (52)
+    if err != nil {
+        return proof, err
+    }
+    tau2, err := rand.Int(rnd, ORDER) // (52)
+    if err != nil {
+        return proof, err
+    }
 
     /*
        The paper does not describe how to compute t1 and t2.
@@ -183,7 +243,9 @@ func Prove(secret *big.Int, params BulletProofSetupParams, gamma *big.Int) (Bull
     T2, _ := CommitG1(t2, tau2, params.H) // (53)
 
     // Fiat-Shamir heuristic to compute 'random' challenge x
-    x, _, _ := HashBP(T1, T2)
+    appendPoints(t, "T1", T1)
+    appendPoints(t, "T2", T2)
+    x := challenge(t, "x")
 
     // ////////////////////////////////////////////////////////////////////////////
     // Third phase                                                              //
@@ -215,17 +277,20 @@ func Prove(secret *big.Int, params BulletProofSetupParams, gamma *big.Int) (Bull
     mu = bn.Add(mu, alpha)
     mu = bn.Mod(mu, ORDER)
 
-    // Inner Product over (g, h', P.h^-mu, tprime)
-    hprime := updateGenerators(params.Hh, y, params.N)
+    // Inner Product over (g, h', P.h^-mu, tprime), with l and r padded with zeros
+    bl = padVector(bl, size)
+    br = padVector(br, size)
+    hprime := updateGenerators(params.Hh, y, size)
 
     // SetupInnerProduct Inner Product (Section 4.2)
-    var setupErr error
-    params.InnerProductParams, setupErr = SetupInnerProduct(params.H, params.Gg, hprime, tprime, params.N)
-    if setupErr != nil {
-        return proof, setupErr
+    params.InnerProductParams, err = SetupInnerProduct(params.H, params.Gg, hprime, tprime, size)
+    if err != nil {
+        return proof, err
     }
     commit := CommitInnerProduct(params.Gg, hprime, bl, br)
-    proofip, _ := ProveInnerProduct(bl, br, commit, params.InnerProductParams)
+    appendScalars(t, "taux", taux)
+    appendScalars(t, "mu", mu)
+    proofip, _ := proveInnerProduct(t, bl, br, commit, params.InnerProductParams)
 
     proof.V = V
     proof.A = A
@@ -237,66 +302,105 @@ func Prove(secret *big.Int, params BulletProofSetupParams, gamma *big.Int) (Bull
     proof.Tprime = tprime
     proof.InnerProductProof = proofip
     proof.Commit = commit
-    proof.Params = params
+    proof.ParamsID = id
+
+    // Converts all the points of the proof to affine coordinates with one inversion
+    p256.BatchNormalize(append(proof.InnerProductProof.points(), V, A, S, T1, T2, commit)...)
 
     return proof, nil
 }
 
 /*
-Verify returns true if and only if the proof is valid.
+ErrInvalidProof is returned by Verify when an element of the proof is missing,
+e.g. when a field is absent from a JSON proof.
+*/
+var ErrInvalidProof = errors.New("invalid proof: missing element")
+
+/*
+checkElements returns ErrInvalidProof if any of the points or scalars is nil.
+*/
+func checkElements(points []*p256.P256, scalars ...*big.Int) error {
+    for _, p := range points {
+        if p == nil {
+            return ErrInvalidProof
+        }
+    }
+    for _, k := range scalars {
+        if k == nil {
+            return ErrInvalidProof
+        }
+    }
+    return nil
+}
+
+/*
+check returns ErrInvalidProof if an element of the proof is missing.
+*/
+func (proof *BulletProof) check() error {
+    points := []*p256.P256{proof.V, proof.A, proof.S, proof.T1, proof.T2, proof.Commit}
+    if err := checkElements(points, proof.Taux, proof.Mu, proof.Tprime); err != nil {
+        return err
+    }
+    return proof.InnerProductProof.check()
+}
+
+/*
+Verify returns true if and only if the proof is valid with respect to the
+verifier's parameters. Proofs computed with other generators are rejected with
+ErrParamsMismatch, and incomplete proofs with ErrInvalidProof.
 */
-func (proof *BulletProof) Verify() (bool, error) {
-    params := proof.Params
+func (proof *BulletProof) Verify(params BulletProofSetupParams) (bool, error) {
+    if err := proof.check(); err != nil {
+        return false, err
+    }
+    id, err := params.ID()
+    if err != nil {
+        return false, err
+    }
+    if proof.ParamsID != id {
+        return false, ErrParamsMismatch
+    }
+    size, err := params.generatorsSize()
+    if err != nil {
+        return false, err
+    }
     // Recover x, y, z using Fiat-Shamir heuristic
-    x, _, _ := HashBP(proof.T1, proof.T2)
-    y, z, _ := HashBP(proof.A, proof.S)
+    t := newRangeTranscript(id, params.N, proof.V)
+    appendPoints(t, "A", proof.A)
+    appendPoints(t, "S", proof.S)
+    y := challenge(t, "y")
+    z := challenge(t, "z")
+    appendPoints(t, "T1", proof.T1)
+    appendPoints(t, "T2", proof.T2)
+    x := challenge(t, "x")
 
     // Switch generators                                                   // (64)
-    hprime := updateGenerators(params.Hh, y, params.N)
+    hprime := updateGenerators(params.Hh, y, size)
 
     // ////////////////////////////////////////////////////////////////////////////
     // Check that tprime  = t(x) = t0 + t1x + t2x^2  ----------  Condition (65) //
     // ////////////////////////////////////////////////////////////////////////////
 
-    // Compute left hand side
-    lhs, _ := CommitG1(proof.Tprime, proof.Taux, params.H)
-
-    // Compute right hand side
     z2 := bn.Multiply(z, z)
     z2 = bn.Mod(z2, ORDER)
     x2 := bn.Multiply(x, x)
     x2 = bn.Mod(x2, ORDER)
 
-    rhs := new(p256.P256).ScalarMult(proof.V, z2)
-
     delta := params.delta(y, z)
 
-    gdelta := new(p256.P256).ScalarBaseMult(delta)
-
-    rhs.Multiply(rhs, gdelta)
-
-    T1x := new(p256.P256).ScalarMult(proof.T1, x)
-    T2x2 := new(p256.P256).ScalarMult(proof.T2, x2)
-
-    rhs.Multiply(rhs, T1x)
-    rhs.Multiply(rhs, T2x2)
-
-    // Subtract lhs and rhs and compare with poitn at infinity
-    lhs.Neg(lhs)
-    rhs.Multiply(rhs, lhs)
+    // g^tprime.h^taux = V^(z^2).g^delta.T1^x.T2^(x^2), i.e.
+    // g^(tprime-delta).h^taux.V^(-z^2).T1^(-x).T2^(-x^2) is the point at infinity
+    G := new(p256.P256).ScalarBaseMult(new(big.Int).SetInt64(1))
+    rhs, _ := VectorExp(
+        []*p256.P256{G, params.H, proof.V, proof.T1, proof.T2},
+        []*big.Int{bn.Sub(proof.Tprime, delta), proof.Taux, bn.Sub(ORDER, z2), bn.Sub(ORDER, x), bn.Sub(ORDER, x2)})
     c65 := rhs.IsZero() // Condition (65), page 20, from eprint version
 
     // Compute P - lhs  #################### Condition (66) ######################
 
-    // S^x
-    Sx := new(p256.P256).ScalarMult(proof.S, x)
-    // A.S^x
-    ASx := new(p256.P256).Add(proof.A, Sx)
-
     // g^-z
     mz := bn.Sub(ORDER, z)
     vmz, _ := VectorCopy(mz, params.N)
-    gpmz, _ := VectorExp(params.Gg, vmz)
 
     // z.y^n
     vz, _ := VectorCopy(z, params.N)
@@ -310,43 +414,56 @@ func (proof *BulletProof) Verify() (bool, error) {
     // z.y^n + z^2.2^n
     zynz22n, _ := VectorAdd(zyn, z22n)
 
-    lP := new(p256.P256)
-    lP.Add(ASx, gpmz)
-
-    // h'^(z.y^n + z^2.2^n)
-    hprimeexp, _ := VectorExp(hprime, zynz22n)
-
-    lP.Add(lP, hprimeexp)
-
     // Compute P - rhs  #################### Condition (67) ######################
 
-    // h^mu
-    rP := new(p256.P256).ScalarMult(params.H, proof.Mu)
-    rP.Multiply(rP, proof.Commit)
-
-    // Subtract lhs and rhs and compare with poitn at infinity
-    lP = lP.Neg(lP)
-    rP.Add(rP, lP)
+    // A.S^x.g^-z.h'^(z.y^n + z^2.2^n) = h^mu.Commit, where Commit = g^l.h'^r
+    points := []*p256.P256{proof.A, proof.S, params.H, proof.Commit}
+    points = append(append(points, params.Gg[:params.N]...), hprime[:params.N]...)
+    scalars := []*big.Int{big.NewInt(1), x, bn.Sub(ORDER, proof.Mu), big.NewInt(-1)}
+    scalars = append(append(scalars, vmz...), zynz22n...)
+    rP, _ := VectorExp(points, scalars)
     c67 := rP.IsZero()
 
     // Verify Inner Product Proof ################################################
-    ok, _ := proof.InnerProductProof.Verify()
+    ipParams, err := SetupInnerProduct(params.H, params.Gg, hprime, proof.Tprime, size)
+    if err != nil {
+        return false, err
+    }
+    ipParams.P = proof.Commit
+    appendScalars(t, "taux", proof.Taux)
+    appendScalars(t, "mu", proof.Mu)
+    ok, _ := proof.InnerProductProof.verify(t, ipParams)
 
     result := c65 && c67 && ok
 
     return result, nil
 }
 
+/*
+generatorsSize returns the length of the vectors of generators, which must be the
+smallest power of 2 greater than or equal to N.
+*/
+func (params *BulletProofSetupParams) generatorsSize() (int64, error) {
+    size := int64(len(params.Gg))
+    if params.N < 1 || params.N > MAX_RANGE_BITS || size != nextPowerOfTwo(params.N) || int64(len(params.Hh)) != size {
+        return 0, ErrInvalidParams
+    }
+    return size, nil
+}
+
 /*
 SampleRandomVector generates a vector composed by random big numbers.
 */
-func sampleRandomVector(N int64, seed int64) []*big.Int {
-    rand.Seed(seed)
+func sampleRandomVector(rnd io.Reader, N int64) ([]*big.Int, error) {
+    var err error
     s := make([]*big.Int, N)
     for i := int64(0); i < N; i++ {
-        s[i] = big.NewInt(int64(rand.Int()))
+        s[i], err = rand.Int(rnd, ORDER)
+        if err != nil {
+            return nil, err
+        }
     }
-    return s
+    return s, nil
 }
 
 /*
@@ -394,11 +511,9 @@ func computeAR(x []int64) ([]int64, error) {
 
 func commitVectorBig(aL, aR []*big.Int, alpha *big.Int, H *p256.P256, g, h []*p256.P256, n int64) *p256.P256 {
     // Compute h^alpha.vg^aL.vh^aR
-    R := new(p256.P256).ScalarMult(H, alpha)
-    for i := int64(0); i < n; i++ {
-        R.Multiply(R, new(p256.P256).ScalarMult(g[i], aL[i]))
-        R.Multiply(R, new(p256.P256).ScalarMult(h[i], aR[i]))
-    }
+    points := append(append([]*p256.P256{H}, g[:n]...), h[:n]...)
+    scalars := append(append([]*big.Int{alpha}, aL[:n]...), aR[:n]...)
+    R, _ := VectorExp(points, scalars)
     return R
 }
 
@@ -407,14 +522,9 @@ Commitvector computes a commitment to the bit of the secret.
 */
 func commitVector(aL, aR []int64, alpha *big.Int, H *p256.P256, g, h []*p256.P256, n int64) *p256.P256 {
     // Compute h^alpha.vg^aL.vh^aR
-    R := new(p256.P256).ScalarMult(H, alpha)
-    for i := int64(0); i < n; i++ {
-        gaL := new(p256.P256).ScalarMult(g[i], new(big.Int).SetInt64(aL[i]))
-        haR := new(p256.P256).ScalarMult(h[i], new(big.Int).SetInt64(aR[i]))
-        R.Multiply(R, gaL)
-        R.Multiply(R, haR)
-    }
-    return R
+    bigL, _ := VectorConvertToBig(aL, n)
+    bigR, _ := VectorConvertToBig(aR, n)
+    return commitVectorBig(bigL, bigR, alpha, H, g, h, n)
 }
 
 /*
@@ -18,14 +18,22 @@
 package bulletproofs
 
 import (
+    "bytes"
     "encoding/json"
     "math"
     "math/big"
     "testing"
 
+    "github.com/ing-bank/zkrp/crypto/p256"
+    "github.com/ing-bank/zkrp/util"
     "github.com/stretchr/testify/assert"
 )
 
+// testRangeBits is the number of bits of the range [0, testRangeEnd) of most tests.
+const testRangeBits = 32
+
+var testRangeEnd = new(big.Int).Lsh(big.NewInt(1), testRangeBits)
+
 func TestXEqualsRangeStart(t *testing.T) {
     rangeEnd := int64(math.Pow(2, 32))
     x := new(big.Int).SetInt64(0)
@@ -77,7 +85,7 @@ func TestXWithinRange(t *testing.T) {
 }
 
 func setupRange(t *testing.T, rangeEnd int64) BulletProofSetupParams {
-    params, err := Setup(rangeEnd)
+    params, err := Setup(new(big.Int).SetInt64(rangeEnd))
     if err != nil {
         t.Errorf("Invalid range end: %s", err)
         t.FailNow()
@@ -85,15 +93,127 @@ func setupRange(t *testing.T, rangeEnd int64) BulletProofSetupParams {
     return params
 }
 
+func TestRange40Bits(t *testing.T) {
+    rangeEnd := new(big.Int).Lsh(big.NewInt(1), 40)
+    params, err := Setup(rangeEnd)
+    if err != nil {
+        t.Fatalf("Setup failed: %s", err)
+    }
+    if len(params.Gg) != 64 {
+        t.Errorf("Assert failure: generators should be padded to 64, got %d", len(params.Gg))
+    }
+    x := new(big.Int).Sub(rangeEnd, big.NewInt(1))
+    if proveAndVerifyRange(x, params) != true {
+        t.Errorf("2^40 - 1 should verify successfully")
+    }
+    if proveAndVerifyRange(rangeEnd, params) == true {
+        t.Errorf("2^40 should not verify")
+    }
+}
+
+func TestRange64Bits(t *testing.T) {
+    rangeEnd := new(big.Int).Lsh(big.NewInt(1), 64)
+    params, err := Setup(rangeEnd)
+    if err != nil {
+        t.Fatalf("Setup failed: %s", err)
+    }
+    x := new(big.Int).Sub(rangeEnd, big.NewInt(1))
+    if proveAndVerifyRange(x, params) != true {
+        t.Errorf("2^64 - 1 should verify successfully")
+    }
+    if proveAndVerifyRange(rangeEnd, params) == true {
+        t.Errorf("2^64 should not verify")
+    }
+}
+
+func TestSetupInvalidRange(t *testing.T) {
+    for _, b := range []*big.Int{
+        big.NewInt(0),
+        big.NewInt(1),
+        big.NewInt(1000),
+        new(big.Int).Lsh(big.NewInt(1), 65),
+    } {
+        if _, err := Setup(b); err == nil {
+            t.Errorf("Assert failure: Setup(%s) should fail", b)
+        }
+    }
+}
+
+func TestGeneratorsAreHashedToCurve(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    dst := []byte(GeneratorsDST)
+    H, _ := p256.HashToCurve(dst, []byte(SEEDH))
+    assert.True(t, params.H.Equals(H), "H should be hashed from SEEDH")
+    g3, _ := p256.HashToCurve(dst, []byte(SEEDH+"g3"))
+    h3, _ := p256.HashToCurve(dst, []byte(SEEDH+"h3"))
+    assert.True(t, params.Gg[3].Equals(g3), "Gg[3] should be hashed from SEEDH || g3")
+    assert.True(t, params.Hh[3].Equals(h3), "Hh[3] should be hashed from SEEDH || h3")
+}
+
+func TestGeneratorsCache(t *testing.T) {
+    params1, _ := Setup(testRangeEnd)
+    params1.Gg[0].Add(params1.Gg[0], params1.H)
+    params2, _ := Setup(testRangeEnd)
+    assert.Equal(t, testRangeBits, len(params2.Gg))
+    g0, _ := p256.HashToCurve([]byte(GeneratorsDST), []byte(SEEDH+"g0"))
+    assert.True(t, params2.Gg[0].Equals(g0), "modifying a generator should not modify the cache")
+
+    var buf bytes.Buffer
+    assert.Nil(t, ExportGenerators(&buf))
+    data := buf.Bytes()
+    assert.Nil(t, LoadGenerators(bytes.NewReader(data)))
+    params3, _ := Setup(testRangeEnd)
+    id2, _ := params2.ID()
+    id3, _ := params3.ID()
+    assert.Equal(t, id2, id3)
+
+    // Hh[8] and Hh[9] are swapped, which keeps every point on the curve
+    swapped := append([]byte(nil), data...)
+    h8 := bytes.Index(swapped, []byte(SEEDH+"h8")) + len(SEEDH+"h8")
+    h9 := bytes.Index(swapped, []byte(SEEDH+"h9")) + len(SEEDH+"h9")
+    tmp := append([]byte(nil), swapped[h8:h8+p256.PointSize]...)
+    copy(swapped[h8:], swapped[h9:h9+p256.PointSize])
+    copy(swapped[h9:], tmp)
+    assert.NotNil(t, LoadGenerators(bytes.NewReader(swapped)), "generators that do not match their labels should be rejected")
+
+    data[len(data)-p256.PointSize] = 5
+    assert.NotNil(t, LoadGenerators(bytes.NewReader(data)), "invalid point should be rejected")
+    assert.NotNil(t, LoadGenerators(bytes.NewReader(data[:len(data)-1])), "truncated data should be rejected")
+}
+
+func TestFullSetupID(t *testing.T) {
+    points := make(map[string]*p256.P256)
+    for _, label := range fullSetupLabels() {
+        P, err := p256.HashToCurve([]byte(GeneratorsDST), []byte(label))
+        assert.Nil(t, err)
+        points[label] = P
+    }
+    params, err := fullSetupParams(points)
+    assert.Nil(t, err)
+    id, _ := params.ID()
+    assert.Equal(t, fullSetupID, id.String(), "fullSetupID should be the ID of the generators hashed from their labels")
+}
+
 func proveAndVerifyRange(x *big.Int, params BulletProofSetupParams) bool {
-    proof, _ := Prove(x, params)
-    ok, _ := proof.Verify()
+    proof, err := Prove(x, params, new(big.Int).SetInt64(12))
+    if err != nil {
+        return false
+    }
+    ok, _ := proof.Verify(params)
     return ok
 }
 
+func TestProveOutOfRange(t *testing.T) {
+    params := setupRange(t, int64(math.Pow(2, 32)))
+    for _, x := range []int64{-1, int64(math.Pow(2, 32))} {
+        _, err := Prove(new(big.Int).SetInt64(x), params, new(big.Int).SetInt64(12))
+        assert.NotNil(t, err, "secret out of range should be rejected")
+    }
+}
+
 func TestJsonEncodeDecode(t *testing.T) {
-    params, _ := Setup(MAX_RANGE_END)
-    proof, _ := Prove(new(big.Int).SetInt64(18), params)
+    params, _ := Setup(testRangeEnd)
+    proof, _ := Prove(new(big.Int).SetInt64(18), params, new(big.Int).SetInt64(12))
     jsonEncoded, err := json.Marshal(proof)
     if err != nil {
         t.Fatal("encode error:", err)
@@ -109,9 +229,100 @@ func TestJsonEncodeDecode(t *testing.T) {
 
     assert.Equal(t, proof, decodedProof, "should be equal")
 
-    ok, err := decodedProof.Verify()
+    ok, err := decodedProof.Verify(params)
     if err != nil {
         t.Fatal("verify error:", err)
     }
     assert.True(t, ok, "should verify")
 }
+
+func TestVerifyMissingElement(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    proof, _ := Prove(new(big.Int).SetInt64(18), params, new(big.Int).SetInt64(12))
+    jsonEncoded, _ := json.Marshal(proof)
+    for _, field := range []string{"V", "A", "T2", "Commit", "Taux", "InnerProductProof"} {
+        var fields map[string]json.RawMessage
+        _ = json.Unmarshal(jsonEncoded, &fields)
+        delete(fields, field)
+        data, _ := json.Marshal(fields)
+        var decodedProof BulletProof
+        if err := json.Unmarshal(data, &decodedProof); err != nil {
+            t.Fatal("decode error:", err)
+        }
+        ok, err := decodedProof.Verify(params)
+        assert.Equal(t, ErrInvalidProof, err, "proof without "+field+" should be rejected")
+        assert.False(t, ok)
+    }
+}
+
+func TestVerifyWithIncompleteParams(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    proof, _ := Prove(new(big.Int).SetInt64(18), params, new(big.Int).SetInt64(12))
+
+    // a verifier missing a generator must not accept a proof without ParamsID
+    incomplete := params
+    incomplete.Hh = append([]*p256.P256{}, params.Hh...)
+    incomplete.Hh[3] = nil
+    _, err := incomplete.ID()
+    assert.Equal(t, ErrInvalidParams, err)
+    proof.ParamsID = ParamsID{}
+    ok, err := proof.Verify(incomplete)
+    assert.Equal(t, ErrInvalidParams, err)
+    assert.False(t, ok, "proof should not verify with incomplete parameters")
+
+    ok, err = proof.Verify(params)
+    assert.Equal(t, ErrParamsMismatch, err)
+    assert.False(t, ok, "proof without ParamsID should not verify")
+}
+
+func TestVerifyWithOtherParams(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    proof, _ := Prove(new(big.Int).SetInt64(18), params, new(big.Int).SetInt64(12))
+
+    // a prover choosing its own H must be rejected by the verifier
+    otherParams := params
+    otherParams.H, _ = p256.MapToGroup("OtherH")
+    otherProof, _ := Prove(new(big.Int).SetInt64(18), otherParams, new(big.Int).SetInt64(12))
+    ok, err := otherProof.Verify(params)
+    assert.Equal(t, ErrParamsMismatch, err)
+    assert.False(t, ok, "proof under different generators should not verify")
+
+    // even if it claims to use the verifier's parameters
+    otherProof.ParamsID, _ = params.ID()
+    ok, _ = otherProof.Verify(params)
+    assert.False(t, ok, "proof under different generators should not verify")
+
+    ok, err = proof.VerifyByID()
+    if err != nil {
+        t.Fatal("verify error:", err)
+    }
+    assert.True(t, ok, "should verify with the registered parameters")
+}
+
+func TestProveWithDeterministicReader(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    secret := new(big.Int).SetInt64(18)
+    gamma := new(big.Int).SetInt64(12)
+    proof1, err := ProveWithReader(util.NewDeterministicReader([]byte("test vector")), secret, params, gamma)
+    if err != nil {
+        t.Fatal("prove error:", err)
+    }
+    proof2, _ := ProveWithReader(util.NewDeterministicReader([]byte("test vector")), secret, params, gamma)
+    assert.Equal(t, proof1, proof2, "same seed should produce the same proof")
+
+    ok, _ := proof1.Verify(params)
+    assert.True(t, ok, "should verify")
+
+    // the default prover must not depend on gamma only
+    proof3, _ := Prove(secret, params, gamma)
+    proof4, _ := Prove(secret, params, gamma)
+    assert.False(t, proof3.A.Equals(proof4.A), "blinding values should be fresh")
+    assert.False(t, proof3.S.Equals(proof4.S), "blinding values should be fresh")
+}
+
+func TestSampleRandomVector(t *testing.T) {
+    rnd := util.NewDeterministicReader([]byte("test vector"))
+    sL, _ := sampleRandomVector(rnd, 8)
+    sR, _ := sampleRandomVector(rnd, 8)
+    assert.NotEqual(t, sL, sR, "sL and sR should be sampled independently")
+}
@@ -0,0 +1,408 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bulletproofs
+
+import (
+    "crypto/rand"
+    "errors"
+    "fmt"
+    "io"
+    "math/big"
+
+    "github.com/ing-bank/zkrp/crypto/p256"
+    "github.com/ing-bank/zkrp/crypto/transcript"
+    . "github.com/ing-bank/zkrp/util"
+    "github.com/ing-bank/zkrp/util/bn"
+)
+
+/*
+AggregateBulletProof contains the elements that are necessary for the verification
+of an aggregated range proof, where m secrets are proven to belong to [0, 2^N)
+at once (Section 4.3 of the Bulletproofs paper).
+*/
+type AggregateBulletProof struct {
+    V                 []*p256.P256
+    A                 *p256.P256
+    S                 *p256.P256
+    T1                *p256.P256
+    T2                *p256.P256
+    Taux              *big.Int
+    Mu                *big.Int
+    Tprime            *big.Int
+    InnerProductProof InnerProductProof
+    Commit            *p256.P256
+    ParamsID          ParamsID
+}
+
+/*
+aggregateSize returns the number of secrets m padded to the next power of 2, or an
+error if m is not between 1 and MAX_AGGREGATE_SIZE. The verifier checks it before
+deriving any generator, since m is chosen by the prover.
+*/
+func aggregateSize(m int64) (int64, error) {
+    if m <= 0 {
+        return 0, errors.New("number of secrets must be greater than zero")
+    }
+    if m > MAX_AGGREGATE_SIZE {
+        return 0, fmt.Errorf("number of secrets can not be greater than %d", MAX_AGGREGATE_SIZE)
+    }
+    return nextPowerOfTwo(m), nil
+}
+
+/*
+setupAggregate returns the number of secrets m padded to the next power of 2, and
+extends the vectors of generators Gg and Hh to the smallest power of 2 greater than
+or equal to N times that number, which is required to prove m secrets at once. The
+padding secrets are zeros committed with zero blinding factors, i.e. the point at
+infinity, so they are left out of the proof. The generators are computed using the
+same seeds as Setup, so the first ones are left unchanged.
+*/
+func setupAggregate(params BulletProofSetupParams, m int64) (BulletProofSetupParams, int64, error) {
+    if _, err := params.generatorsSize(); err != nil {
+        return params, 0, err
+    }
+    m, err := aggregateSize(m)
+    if err != nil {
+        return params, 0, err
+    }
+    size := nextPowerOfTwo(params.N * m)
+    Gg := make([]*p256.P256, size)
+    Hh := make([]*p256.P256, size)
+    copy(Gg, params.Gg)
+    copy(Hh, params.Hh)
+    for i := int64(len(params.Gg)); i < size; i++ {
+        Gg[i], _ = generatorG(i)
+    }
+    for i := int64(len(params.Hh)); i < size; i++ {
+        Hh[i], _ = generatorH(i)
+    }
+    params.Gg = Gg
+    params.Hh = Hh
+    return params, m, nil
+}
+
+/*
+newAggregateTranscript starts the transcript of an aggregated range proof, binding
+the parameters ID, the range [0, 2^n), the number of commitments and each of them.
+*/
+func newAggregateTranscript(id ParamsID, n int64, V []*p256.P256) *transcript.Transcript {
+    t := transcript.New("zkrp bulletproofs aggregated range proof")
+    t.AppendMessage("params", id[:])
+    t.AppendInt64("n", n)
+    t.AppendInt64("m", int64(len(V)))
+    appendPoints(t, "V", V...)
+    return t
+}
+
+/*
+AggregateProve computes a single ZK rangeproof showing that each one of the
+secrets belongs to [0, 2^N), where N is defined by params, and gammas[j] is the
+blinding factor used to commit to secrets[j]. At most MAX_AGGREGATE_SIZE secrets
+are proven at once. The proof size is logarithmic in the number of secrets. The
+blinding values are drawn from crypto/rand.
+*/
+func AggregateProve(secrets []*big.Int, gammas []*big.Int, params BulletProofSetupParams) (AggregateBulletProof, error) {
+    return AggregateProveWithReader(rand.Reader, secrets, gammas, params)
+}
+
+/*
+AggregateProveWithReader computes the aggregated rangeproof drawing the blinding
+values from rnd.
+*/
+func AggregateProveWithReader(rnd io.Reader, secrets []*big.Int, gammas []*big.Int, params BulletProofSetupParams) (AggregateBulletProof, error) {
+    var (
+        proof AggregateBulletProof
+    )
+
+    if len(secrets) != len(gammas) {
+        return proof, errors.New("number of secrets and blinding factors must be equal")
+    }
+    m := int64(len(secrets))
+    id, err := params.ID()
+    if err != nil {
+        return proof, err
+    }
+    proof.ParamsID = id
+    params, mp, err := setupAggregate(params, m)
+    if err != nil {
+        return proof, err
+    }
+    n := params.N
+    nm := n * mp
+
+    // commitments to v_j and gamma_j, and aL as the concatenation of the bits of each
+    // v_j, followed by the bits of the padding zeros
+    V := make([]*p256.P256, m)
+    aL := make([]int64, 0, nm)
+    for j := int64(0); j < mp; j++ {
+        secret := new(big.Int)
+        if j < m {
+            secret = secrets[j]
+            V[j], _ = CommitG1(secret, gammas[j], params.H)
+        }
+        bits, err := Decompose(secret, 2, n)
+        if err != nil {
+            return proof, err
+        }
+        aL = append(aL, bits...)
+    }
+    t := newAggregateTranscript(proof.ParamsID, n, V)
+    aR, _ := computeAR(aL)
+    alpha, err := rand.Int(rnd, ORDER)
+    if err != nil {
+        return proof, err
+    }
+    A := commitVector(aL, aR, alpha, params.H, params.Gg, params.Hh, nm)
+
+    sL, err := sampleRandomVector(rnd, nm)
+    if err != nil {
+        return proof, err
+    }
+    sR, err := sampleRandomVector(rnd, nm)
+    if err != nil {
+        return proof, err
+    }
+    rho, err := rand.Int(rnd, ORDER)
+    if err != nil {
+        return proof, err
+    }
+    S := commitVectorBig(sL, sR, rho, params.H, params.Gg, params.Hh, nm)
+
+    // Fiat-Shamir heuristic to compute challenges y and z
+    appendPoints(t, "A", A)
+    appendPoints(t, "S", S)
+    y := challenge(t, "y")
+    z := challenge(t, "z")
+
+    tau1, err := rand.Int(rnd, ORDER)
+    if err != nil {
+        return proof, err
+    }
+    tau2, err := rand.Int(rnd, ORDER)
+    if err != nil {
+        return proof, err
+    }
+
+    // l(X) = (aL - z.1^nm) + sL.X
+    vz, _ := VectorCopy(z, nm)
+    vy := powerOf(y, nm)
+    naL, _ := VectorConvertToBig(aL, nm)
+    aLmvz, _ := VectorSub(naL, vz)
+
+    // r(X) = y^nm . (aR + z.1^nm + sR.X) + sum_j z^(1+j) . (0^((j-1)n) || 2^n || 0^((m-j)n))
+    naR, _ := VectorConvertToBig(aR, nm)
+    aRzn, _ := VectorAdd(naR, vz)
+    ynaRzn, _ := VectorMul(vy, aRzn)
+    z22n := params.zetaAggregate(z, mp)
+    r0, _ := VectorAdd(ynaRzn, z22n)
+    ynsR, _ := VectorMul(vy, sR)
+
+    // t1 = < l0, r1 > + < l1, r0 >, t2 = < l1, r1 >
+    sp1, _ := ScalarProduct(aLmvz, ynsR)
+    sp2, _ := ScalarProduct(sL, r0)
+    t1 := bn.Mod(bn.Add(sp1, sp2), ORDER)
+    t2, _ := ScalarProduct(sL, ynsR)
+
+    T1, _ := CommitG1(t1, tau1, params.H)
+    T2, _ := CommitG1(t2, tau2, params.H)
+
+    // Fiat-Shamir heuristic to compute 'random' challenge x
+    appendPoints(t, "T1", T1)
+    appendPoints(t, "T2", T2)
+    x := challenge(t, "x")
+
+    // l = l0 + sL.x, r = r0 + y^nm . sR.x
+    sLx, _ := VectorScalarMul(sL, x)
+    bl, _ := VectorAdd(aLmvz, sLx)
+    ynsRx, _ := VectorScalarMul(ynsR, x)
+    br, _ := VectorAdd(r0, ynsRx)
+
+    tprime, _ := ScalarProduct(bl, br)
+
+    // taux = tau2 . x^2 + tau1 . x + sum_j z^(1+j) . gamma_j
+    taux := bn.Multiply(tau2, bn.Multiply(x, x))
+    taux = bn.Add(taux, bn.Multiply(tau1, x))
+    zj := bn.Mod(bn.Multiply(z, z), ORDER)
+    for j := int64(0); j < m; j++ {
+        taux = bn.Add(taux, bn.Multiply(zj, gammas[j]))
+        zj = bn.Mod(bn.Multiply(zj, z), ORDER)
+    }
+    taux = bn.Mod(taux, ORDER)
+
+    // mu = alpha + rho.x
+    mu := bn.Mod(bn.Add(alpha, bn.Multiply(rho, x)), ORDER)
+
+    // Inner Product over (g, h', P.h^-mu, tprime), with l and r padded with zeros
+    size := int64(len(params.Gg))
+    bl = padVector(bl, size)
+    br = padVector(br, size)
+    hprime := updateGenerators(params.Hh, y, size)
+    params.InnerProductParams, err = SetupInnerProduct(params.H, params.Gg, hprime, tprime, size)
+    if err != nil {
+        return proof, err
+    }
+    commit := CommitInnerProduct(params.Gg, hprime, bl, br)
+    appendScalars(t, "taux", taux)
+    appendScalars(t, "mu", mu)
+    proofip, _ := proveInnerProduct(t, bl, br, commit, params.InnerProductParams)
+
+    proof.V = V
+    proof.A = A
+    proof.S = S
+    proof.T1 = T1
+    proof.T2 = T2
+    proof.Taux = taux
+    proof.Mu = mu
+    proof.Tprime = tprime
+    proof.InnerProductProof = proofip
+    proof.Commit = commit
+
+    // Converts all the points of the proof to affine coordinates with one inversion
+    points := append(proof.InnerProductProof.points(), A, S, T1, T2, commit)
+    p256.BatchNormalize(append(points, V...)...)
+
+    return proof, nil
+}
+
+/*
+Verify returns true if and only if the aggregated proof is valid with respect to
+the verifier's parameters, i.e. if every commitment in proof.V hides a value in
+[0, 2^N). Incomplete proofs are rejected with ErrInvalidProof.
+*/
+func (proof *AggregateBulletProof) Verify(params BulletProofSetupParams) (bool, error) {
+    if _, err := aggregateSize(int64(len(proof.V))); err != nil {
+        return false, err
+    }
+    elements := append([]*p256.P256{proof.A, proof.S, proof.T1, proof.T2, proof.Commit}, proof.V...)
+    if err := checkElements(elements, proof.Taux, proof.Mu, proof.Tprime); err != nil {
+        return false, err
+    }
+    if err := proof.InnerProductProof.check(); err != nil {
+        return false, err
+    }
+    id, err := params.ID()
+    if err != nil {
+        return false, err
+    }
+    if proof.ParamsID != id {
+        return false, ErrParamsMismatch
+    }
+    m := int64(len(proof.V))
+    params, mp, err := setupAggregate(params, m)
+    if err != nil {
+        return false, err
+    }
+    n := params.N
+    nm := n * mp
+
+    // Recover x, y, z using Fiat-Shamir heuristic
+    t := newAggregateTranscript(id, n, proof.V)
+    appendPoints(t, "A", proof.A)
+    appendPoints(t, "S", proof.S)
+    y := challenge(t, "y")
+    z := challenge(t, "z")
+    appendPoints(t, "T1", proof.T1)
+    appendPoints(t, "T2", proof.T2)
+    x := challenge(t, "x")
+
+    size := int64(len(params.Gg))
+    hprime := updateGenerators(params.Hh, y, size)
+
+    // Check that tprime = t(x) = t0 + t1x + t2x^2, i.e.
+    // g^(tprime-delta).h^taux.V^(-z^2.z^m).T1^(-x).T2^(-x^2) is the point at infinity
+    x2 := bn.Mod(bn.Multiply(x, x), ORDER)
+    delta := params.deltaAggregate(y, z, mp)
+    G := new(p256.P256).ScalarBaseMult(new(big.Int).SetInt64(1))
+    points := []*p256.P256{G, params.H, proof.T1, proof.T2}
+    scalars := []*big.Int{bn.Sub(proof.Tprime, delta), proof.Taux, bn.Sub(ORDER, x), bn.Sub(ORDER, x2)}
+    zj := bn.Mod(bn.Multiply(z, z), ORDER)
+    for j := int64(0); j < m; j++ {
+        points = append(points, proof.V[j])
+        scalars = append(scalars, bn.Sub(ORDER, zj))
+        zj = bn.Mod(bn.Multiply(zj, z), ORDER)
+    }
+    rhs, _ := VectorExp(points, scalars)
+    c65 := rhs.IsZero()
+
+    // A.S^x.g^-z.h'^(z.y^nm + sum_j z^(1+j).2^n) = h^mu.Commit, where Commit = g^l.h'^r
+    mz := bn.Sub(ORDER, z)
+    vmz, _ := VectorCopy(mz, nm)
+    vz, _ := VectorCopy(z, nm)
+    vy := powerOf(y, nm)
+    zyn, _ := VectorMul(vy, vz)
+    zynz22n, _ := VectorAdd(zyn, params.zetaAggregate(z, mp))
+
+    points = []*p256.P256{proof.A, proof.S, params.H, proof.Commit}
+    points = append(append(points, params.Gg[:nm]...), hprime[:nm]...)
+    scalars = []*big.Int{big.NewInt(1), x, bn.Sub(ORDER, proof.Mu), big.NewInt(-1)}
+    scalars = append(append(scalars, vmz...), zynz22n...)
+    rP, _ := VectorExp(points, scalars)
+    c67 := rP.IsZero()
+
+    ipParams, err := SetupInnerProduct(params.H, params.Gg, hprime, proof.Tprime, size)
+    if err != nil {
+        return false, err
+    }
+    ipParams.P = proof.Commit
+    appendScalars(t, "taux", proof.Taux)
+    appendScalars(t, "mu", proof.Mu)
+    ok, _ := proof.InnerProductProof.verify(t, ipParams)
+
+    return c65 && c67 && ok, nil
+}
+
+/*
+zetaAggregate returns the vector obtained by concatenating z^(1+j) . 2^n for
+j = 1 to m.
+*/
+func (params *BulletProofSetupParams) zetaAggregate(z *big.Int, m int64) []*big.Int {
+    p2n := powerOf(new(big.Int).SetInt64(2), params.N)
+    result := make([]*big.Int, 0, params.N*m)
+    zj := bn.Mod(bn.Multiply(z, z), ORDER)
+    for j := int64(0); j < m; j++ {
+        zj2n, _ := VectorScalarMul(p2n, zj)
+        result = append(result, zj2n...)
+        zj = bn.Mod(bn.Multiply(zj, z), ORDER)
+    }
+    return result
+}
+
+/*
+deltaAggregate(y,z) = (z-z^2) . < 1^nm, y^nm > - sum_j z^(j+2) . < 1^n, 2^n >
+*/
+func (params *BulletProofSetupParams) deltaAggregate(y, z *big.Int, m int64) *big.Int {
+    nm := params.N * m
+    z2 := bn.Mod(bn.Multiply(z, z), ORDER)
+
+    // < 1^nm, y^nm >
+    v1, _ := VectorCopy(new(big.Int).SetInt64(1), nm)
+    sp1y, _ := ScalarProduct(v1, powerOf(y, nm))
+
+    // < 1^n, 2^n >
+    sp12, _ := ScalarProduct(v1[:params.N], powerOf(new(big.Int).SetInt64(2), params.N))
+
+    result := bn.Mod(bn.Sub(z, z2), ORDER)
+    result = bn.Mod(bn.Multiply(result, sp1y), ORDER)
+
+    zj := bn.Mod(bn.Multiply(z2, z), ORDER)
+    for j := int64(0); j < m; j++ {
+        result = bn.Sub(result, bn.Multiply(zj, sp12))
+        zj = bn.Mod(bn.Multiply(zj, z), ORDER)
+    }
+    return bn.Mod(result, ORDER)
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bulletproofs
+
+import (
+    "encoding/json"
+    "math/big"
+    "testing"
+
+    "github.com/ing-bank/zkrp/crypto/p256"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestAggregateWithinRange(t *testing.T) {
+    if aggregateProveVerify(t, []int64{0, 3, 42, testRangeEnd.Int64() - 1}) != true {
+        t.Errorf("secrets within range should verify successfully")
+    }
+}
+
+func TestAggregateOneOutOfRange(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    secrets, gammas := aggregateInputs([]int64{18, testRangeEnd.Int64()})
+    _, err := AggregateProve(secrets, gammas, params)
+    if err == nil {
+        t.Errorf("secret equal to range end should be rejected by the prover")
+    }
+}
+
+func TestAggregateNotPowerOfTwo(t *testing.T) {
+    if aggregateProveVerify(t, []int64{1, 2, testRangeEnd.Int64() - 1}) != true {
+        t.Errorf("3 secrets within range should verify successfully")
+    }
+}
+
+func TestAggregateDroppedCommitment(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    secrets, gammas := aggregateInputs([]int64{1, 2, 3})
+    proof, err := AggregateProve(secrets, gammas, params)
+    if err != nil {
+        t.Fatal(err)
+    }
+    proof.V = proof.V[:2]
+    ok, _ := proof.Verify(params)
+    if ok {
+        t.Errorf("proof should not verify without one of its commitments")
+    }
+}
+
+func TestAggregateNoSecrets(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    _, err := AggregateProve(nil, nil, params)
+    if err == nil {
+        t.Errorf("empty list of secrets should be rejected")
+    }
+}
+
+func TestAggregateJsonEncodeDecode(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    secrets, gammas := aggregateInputs([]int64{18, 200})
+    proof, _ := AggregateProve(secrets, gammas, params)
+    jsonEncoded, err := json.Marshal(proof)
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+
+    var decodedProof AggregateBulletProof
+    err = json.Unmarshal(jsonEncoded, &decodedProof)
+    if err != nil {
+        t.Fatal("decode error:", err)
+    }
+
+    ok, err := decodedProof.Verify(params)
+    if err != nil {
+        t.Fatal("verify error:", err)
+    }
+    assert.True(t, ok, "should verify")
+}
+
+func TestAggregateTooManyCommitments(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    secrets, gammas := aggregateInputs([]int64{18, 200})
+    proof, _ := AggregateProve(secrets, gammas, params)
+    proof.V = make([]*p256.P256, MAX_AGGREGATE_SIZE+1)
+    for i := range proof.V {
+        proof.V[i] = proof.A
+    }
+    ok, err := proof.Verify(params)
+    if err == nil || ok {
+        t.Errorf("proof with more than MAX_AGGREGATE_SIZE commitments should be rejected")
+    }
+    _, err = AggregateProve(make([]*big.Int, MAX_AGGREGATE_SIZE+1), make([]*big.Int, MAX_AGGREGATE_SIZE+1), params)
+    if err == nil {
+        t.Errorf("more than MAX_AGGREGATE_SIZE secrets should be rejected")
+    }
+}
+
+func TestAggregateMissingElement(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    secrets, gammas := aggregateInputs([]int64{18, 200})
+    proof, _ := AggregateProve(secrets, gammas, params)
+    proof.V[1] = nil
+    ok, err := proof.Verify(params)
+    assert.Equal(t, ErrInvalidProof, err)
+    assert.False(t, ok)
+}
+
+func aggregateInputs(values []int64) ([]*big.Int, []*big.Int) {
+    secrets := make([]*big.Int, len(values))
+    gammas := make([]*big.Int, len(values))
+    for i := range values {
+        secrets[i] = new(big.Int).SetInt64(values[i])
+        gammas[i] = new(big.Int).SetInt64(int64(i) + 12)
+    }
+    return secrets, gammas
+}
+
+func aggregateProveVerify(t *testing.T, values []int64) bool {
+    params, _ := Setup(testRangeEnd)
+    secrets, gammas := aggregateInputs(values)
+    proof, err := AggregateProve(secrets, gammas, params)
+    if err != nil {
+        t.Errorf(err.Error())
+        t.FailNow()
+    }
+    ok, _ := proof.Verify(params)
+    return ok
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bulletproofs
+
+import (
+    "crypto/rand"
+    "errors"
+    "io"
+    "math/big"
+
+    "github.com/ing-bank/zkrp/crypto/p256"
+    "github.com/ing-bank/zkrp/util/bn"
+)
+
+/*
+multiExp accumulates the bases and exponents of a multi-exponentiation. Exponents
+of the same base are merged, so that generators shared by several proofs are only
+exponentiated once. The bases are identified by their address, which is the same
+for every use of a generator of the setup parameters, so that merging them does not
+require to compute their affine coordinates.
+*/
+type multiExp struct {
+    points  []*p256.P256
+    scalars []*big.Int
+    index   map[*p256.P256]int
+}
+
+func newMultiExp() *multiExp {
+    return &multiExp{index: make(map[*p256.P256]int)}
+}
+
+/*
+add includes P^k in the multi-exponentiation.
+*/
+func (me *multiExp) add(P *p256.P256, k *big.Int) {
+    if P.IsZero() {
+        return
+    }
+    if i, ok := me.index[P]; ok {
+        me.scalars[i] = bn.Mod(bn.Add(me.scalars[i], k), ORDER)
+        return
+    }
+    me.index[P] = len(me.points)
+    me.points = append(me.points, P)
+    me.scalars = append(me.scalars, bn.Mod(k, ORDER))
+}
+
+/*
+BatchVerify returns true if and only if all the proofs are valid. The verification
+equations of every proof, including the inner product argument, are combined using
+random weights and checked with a single multi-exponentiation, which is considerably
+faster than calling Verify for each proof. All the proofs must have been computed
+with the verifier's parameters. The random weights are drawn from crypto/rand.
+*/
+func BatchVerify(proofs []*BulletProof, params BulletProofSetupParams) (bool, error) {
+    return BatchVerifyWithReader(rand.Reader, proofs, params)
+}
+
+/*
+BatchVerifyWithReader verifies the proofs like BatchVerify, drawing the random
+weights from rnd.
+*/
+func BatchVerifyWithReader(rnd io.Reader, proofs []*BulletProof, params BulletProofSetupParams) (bool, error) {
+    id, err := params.ID()
+    if err != nil {
+        return false, err
+    }
+    me := newMultiExp()
+    G := new(p256.P256).ScalarBaseMult(new(big.Int).SetInt64(1))
+    U, _ := generator(SEEDU)
+    for _, proof := range proofs {
+        if proof == nil {
+            return false, ErrInvalidProof
+        }
+        if proof.ParamsID != id {
+            return false, ErrParamsMismatch
+        }
+        err := proof.addToBatch(rnd, me, &params, G, U)
+        if err != nil {
+            return false, err
+        }
+    }
+    result, err := VectorExp(me.points, me.scalars)
+    if err != nil {
+        return false, err
+    }
+    return result.IsZero(), nil
+}
+
+/*
+addToBatch adds to me the verification equations of the proof, each one
+multiplied by a random weight:
+    (65) g^(t'-delta).h^taux.V^(-z^2).T1^(-x).T2^(-x^2) = 1
+    (67) A.S^x.g^-z.h'^(z.y^n + z^2.2^n).h^-mu.Commit^-1 = 1
+    (16) P.prod(L^(x^2).R^(x^-2)).g^(-a.s).h'^(-b.s^-1).u'^(-ab) = 1
+where P = Commit.u'^t' and u' = U^x', x' being the inner product challenge. The
+weights are drawn from rnd. Incomplete proofs are rejected with ErrInvalidProof
+before any of their points is added.
+*/
+func (proof *BulletProof) addToBatch(rnd io.Reader, me *multiExp, params *BulletProofSetupParams, G, U *p256.P256) error {
+    if err := proof.check(); err != nil {
+        return err
+    }
+    ip := proof.InnerProductProof
+    n := params.N
+    size := int64(len(params.Gg))
+    logn := int64(len(ip.Ls))
+    if int64(len(ip.Rs)) != logn || logn >= 63 || int64(1)<<uint(logn) != size {
+        return errors.New("inner product proof does not match the setup parameters")
+    }
+
+    // Recover x, y, z and the inner product challenges using Fiat-Shamir heuristic
+    t := newRangeTranscript(proof.ParamsID, n, proof.V)
+    appendPoints(t, "A", proof.A)
+    appendPoints(t, "S", proof.S)
+    y := challenge(t, "y")
+    z := challenge(t, "z")
+    appendPoints(t, "T1", proof.T1)
+    appendPoints(t, "T2", proof.T2)
+    x := challenge(t, "x")
+    appendScalars(t, "taux", proof.Taux)
+    appendScalars(t, "mu", proof.Mu)
+    appendPoints(t, "P", proof.Commit)
+    appendScalars(t, "c", proof.Tprime)
+    xip := challenge(t, "w")
+    x2 := bn.Mod(bn.Multiply(x, x), ORDER)
+    z2 := bn.Mod(bn.Multiply(z, z), ORDER)
+
+    w, err := sampleRandomVector(rnd, 3)
+    if err != nil {
+        return err
+    }
+    w1, w2, w3 := w[0], w[1], w[2]
+
+    // Condition (65)
+    delta := params.delta(y, z)
+    me.add(G, bn.Multiply(w1, bn.Sub(proof.Tprime, delta)))
+    me.add(params.H, bn.Sub(bn.Multiply(w1, proof.Taux), bn.Multiply(w2, proof.Mu)))
+    me.add(proof.V, bn.Multiply(w1, bn.Sub(ORDER, z2)))
+    me.add(proof.T1, bn.Multiply(w1, bn.Sub(ORDER, x)))
+    me.add(proof.T2, bn.Multiply(w1, bn.Sub(ORDER, x2)))
+
+    // Condition (67)
+    me.add(proof.A, w2)
+    me.add(proof.S, bn.Multiply(w2, x))
+    me.add(proof.Commit, bn.Sub(w3, w2))
+
+    // P = Commit.u'^t' and u'^-ab
+    me.add(U, bn.Multiply(bn.Multiply(w3, xip), bn.Sub(proof.Tprime, bn.Multiply(ip.A, ip.B))))
+
+    // Condition (16), with g' and h' expanded as g'[0] = g^s and h'[0] = h'^(s^-1)
+    xs := make([]*big.Int, logn)
+    for j := int64(0); j < logn; j++ {
+        appendPoints(t, "L", ip.Ls[j])
+        appendPoints(t, "R", ip.Rs[j])
+        xs[j] = challenge(t, "u")
+        xj2 := bn.Mod(bn.Multiply(xs[j], xs[j]), ORDER)
+        me.add(ip.Ls[j], bn.Multiply(w3, xj2))
+        me.add(ip.Rs[j], bn.Multiply(w3, bn.ModInverse(xj2, ORDER)))
+    }
+
+    yinv := bn.ModInverse(y, ORDER)
+    yinvi := new(big.Int).SetInt64(1)
+    p2i := new(big.Int).SetInt64(1)
+    wa := bn.Multiply(w3, ip.A)
+    wb := bn.Multiply(w3, ip.B)
+    s, sinv := challengeProducts(xs, size)
+    for i := int64(0); i < size; i++ {
+        // g_i^(-w2.z - w3.a.s_i)
+        gi := bn.Multiply(wa, s[i])
+        // h_i^(w2.(z + z^2.2^i.y^-i) - w3.b.s_i^-1.y^-i)
+        hi := bn.Sub(ORDER, bn.Multiply(wb, bn.Multiply(sinv[i], yinvi)))
+        // the generators used for padding only appear in condition (16)
+        if i < n {
+            gi = bn.Add(gi, bn.Multiply(w2, z))
+            hz := bn.Add(z, bn.Multiply(z2, bn.Multiply(p2i, yinvi)))
+            hi = bn.Add(hi, bn.Multiply(w2, hz))
+        }
+        me.add(params.Gg[i], bn.Sub(ORDER, bn.Mod(gi, ORDER)))
+        me.add(params.Hh[i], hi)
+
+        yinvi = bn.Mod(bn.Multiply(yinvi, yinv), ORDER)
+        p2i = bn.Multiply(p2i, new(big.Int).SetInt64(2))
+    }
+    return nil
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bulletproofs
+
+import (
+    "errors"
+    "math/big"
+    "testing"
+)
+
+func TestBatchVerify(t *testing.T) {
+    params, proofs := batchProofs(t, []int64{0, 18, 200, testRangeEnd.Int64() - 1})
+    ok, err := BatchVerify(proofs, params)
+    if err != nil {
+        t.Fatal("verify error:", err)
+    }
+    if ok != true {
+        t.Errorf("batch of valid proofs should verify successfully")
+    }
+}
+
+func TestBatchVerifyOneInvalid(t *testing.T) {
+    params, proofs := batchProofs(t, []int64{18, 40, 200})
+    proofs[1].Tprime = new(big.Int).Add(proofs[1].Tprime, big.NewInt(1))
+    ok, _ := BatchVerify(proofs, params)
+    if ok == true {
+        t.Errorf("batch containing an invalid proof should not verify")
+    }
+}
+
+func TestBatchVerifyTamperedCommitment(t *testing.T) {
+    params, proofs := batchProofs(t, []int64{18, 200})
+    proofs[0].V = proofs[1].V
+    ok, _ := BatchVerify(proofs, params)
+    if ok == true {
+        t.Errorf("batch containing a swapped commitment should not verify")
+    }
+}
+
+func TestBatchVerifyPaddedRange(t *testing.T) {
+    params := setupRange(t, int64(1)<<40)
+    proofs := make([]*BulletProof, 2)
+    for i, x := range []int64{7, int64(1)<<40 - 1} {
+        proof, err := Prove(new(big.Int).SetInt64(x), params, new(big.Int).SetInt64(int64(i)+12))
+        if err != nil {
+            t.Fatal("prove error:", err)
+        }
+        proofs[i] = &proof
+    }
+    ok, err := BatchVerify(proofs, params)
+    if err != nil {
+        t.Fatal("verify error:", err)
+    }
+    if ok != true {
+        t.Errorf("batch of valid 40 bits proofs should verify successfully")
+    }
+}
+
+func TestBatchVerifyMissingElement(t *testing.T) {
+    params, proofs := batchProofs(t, []int64{18, 40})
+    proofs[1].T1 = nil
+    ok, err := BatchVerify(proofs, params)
+    if err != ErrInvalidProof || ok {
+        t.Errorf("batch containing an incomplete proof should be rejected with ErrInvalidProof")
+    }
+    ok, err = BatchVerify([]*BulletProof{proofs[0], nil}, params)
+    if err != ErrInvalidProof || ok {
+        t.Errorf("batch containing a nil proof should be rejected with ErrInvalidProof")
+    }
+}
+
+// failingReader returns an error on every read.
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+    return 0, errors.New("no randomness")
+}
+
+func TestBatchVerifyFailingReader(t *testing.T) {
+    params, proofs := batchProofs(t, []int64{18, 40})
+    ok, err := BatchVerifyWithReader(failingReader{}, proofs, params)
+    if err == nil || ok {
+        t.Errorf("error of the random source should be returned")
+    }
+}
+
+func batchProofs(t *testing.T, values []int64) (BulletProofSetupParams, []*BulletProof) {
+    params := setupRange(t, testRangeEnd.Int64())
+    proofs := make([]*BulletProof, len(values))
+    for i := range values {
+        proof, err := Prove(new(big.Int).SetInt64(values[i]), params, new(big.Int).SetInt64(int64(i)+12))
+        if err != nil {
+            t.Fatal("prove error:", err)
+        }
+        proofs[i] = &proof
+    }
+    return params, proofs
+}
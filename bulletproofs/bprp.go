@@ -18,91 +18,191 @@
 package bulletproofs
 
 import (
+    "crypto/rand"
+    "errors"
+    "io"
     "math/big"
+
+    "github.com/ing-bank/zkrp/crypto/commitment"
+    "github.com/ing-bank/zkrp/crypto/p256"
+    . "github.com/ing-bank/zkrp/util"
 )
 
 /*
-bprp structure contains 2 BulletProofs in order to allow computation of
-generic Range Proofs, for any interval [A, B).
+bprp structure contains the parameters of the generic Range Proofs, for any
+interval [A, B). Both BulletProofs use the same parameters BP, for the range
+[0, 2^N), where N is the smallest bit-length such that B - A <= 2^N.
 */
 type bprp struct {
-    A   int64
-    B   int64
-    BP1 BulletProofSetupParams
-    BP2 BulletProofSetupParams
+    A  int64
+    B  int64
+    BP BulletProofSetupParams
 }
 
 /*
-ProofBPRP stores the generic ZKRP.
+RangeProof stores the generic ZKRP. V is the commitment to the secret x. P1 proves
+that x - B + 2^N belongs to [0, 2^N) and P2 proves that x - A belongs to [0, 2^N),
+where the commitments of both proofs are derived from V by the verifier.
 */
-type ProofBPRP struct {
+type RangeProof struct {
+    V  *p256.P256
     P1 BulletProof
     P2 BulletProof
 }
 
 /*
-SetupGeneric is responsible for calling the Setup algorithm for each
-BulletProof.
+ProofBPRP is the former name of RangeProof.
+
+Deprecated: use RangeProof.
+*/
+type ProofBPRP = RangeProof
+
+/*
+SetupGeneric is responsible for calling the Setup algorithm for the BulletProofs
+of the interval [a, b). The proofs have as many bits as b - a - 1, which is limited
+to MAX_RANGE_BITS by Setup.
 */
 func SetupGeneric(a, b int64) (*bprp, error) {
+    if a >= b {
+        return nil, errors.New("range start must be lower than range end")
+    }
+    width := new(big.Int).Sub(big.NewInt(b), big.NewInt(a))
+    n := width.Sub(width, big.NewInt(1)).BitLen()
+    if n < 1 {
+        n = 1
+    }
+    bp, err := Setup(new(big.Int).Lsh(big.NewInt(1), uint(n)))
+    if err != nil {
+        return nil, err
+    }
     params := new(bprp)
     params.A = a
     params.B = b
-    var errBp1, errBp2 error
-    params.BP1, errBp1 = Setup(MAX_RANGE_END)
-    if errBp1 != nil {
-        return nil, errBp1
-    }
-    params.BP2, errBp2 = Setup(MAX_RANGE_END)
-    if errBp2 != nil {
-        return nil, errBp2
-    }
+    params.BP = bp
     return params, nil
 }
 
+/*
+Commit returns the commitment to x with blinding factor gamma, i.e. the value V of
+a generic range proof. The commitment is returned in affine coordinates, since it
+is published together with the proof.
+*/
+func (params *bprp) Commit(x, gamma *big.Int) (*p256.P256, error) {
+    V, err := CommitG1(x, gamma, params.BP.H)
+    if err != nil {
+        return nil, err
+    }
+    return V.Normalize(), nil
+}
+
+/*
+shifts returns the values added to the secret in each BulletProof: 2^N - B and -A.
+*/
+func (params *bprp) shifts() (*big.Int, *big.Int) {
+    shift1 := new(big.Int).Lsh(big.NewInt(1), uint(params.BP.N))
+    shift1.Sub(shift1, big.NewInt(params.B))
+    shift2 := big.NewInt(-params.A)
+    return shift1, shift2
+}
+
 /*
 BulletProof only works for interval in the format [0, 2^N). In order to
 allow generic intervals in the format [A, B) it is necessary to use 2
 BulletProofs, as explained in Section 4.3 from the following paper:
 https://infoscience.epfl.ch/record/128718/files/CCS08.pdf
+The blinding values are drawn from crypto/rand.
 */
-func ProveGeneric(secret *big.Int, params *bprp, seed *big.Int) (ProofBPRP, error) {
-    var proof ProofBPRP
+func ProveGeneric(secret *big.Int, params *bprp, seed *big.Int) (RangeProof, error) {
+    return ProveGenericWithReader(rand.Reader, secret, params, seed)
+}
+
+/*
+ProveGenericWithReader computes the generic rangeproof drawing the blinding values
+from rnd.
+*/
+func ProveGenericWithReader(rnd io.Reader, secret *big.Int, params *bprp, seed *big.Int) (RangeProof, error) {
+    var proof RangeProof
+    var err error
+    if params == nil {
+        return proof, ErrInvalidParams
+    }
+    shift1, shift2 := params.shifts()
+
+    proof.V, err = params.Commit(secret, seed)
+    if err != nil {
+        return proof, err
+    }
 
     // x - b + 2^N
-    p2 := new(big.Int).SetInt64(MAX_RANGE_END)
-    xb := new(big.Int).Sub(secret, new(big.Int).SetInt64(params.B))
-    xb.Add(xb, p2)
-
-    var err1 error
-    proof.P1, err1 = Prove(xb, params.BP1, seed)
-    if err1 != nil {
-        return proof, err1
+    xb := new(big.Int).Add(secret, shift1)
+    proof.P1, err = ProveWithReader(rnd, xb, params.BP, seed)
+    if err != nil {
+        return proof, err
     }
 
     // x - a
-    xa := new(big.Int).Sub(secret, new(big.Int).SetInt64(params.A))
-    var err2 error
-    proof.P2, err2 = Prove(xa, params.BP2, seed)
-    if err2 != nil {
-        return proof, err2
+    xa := new(big.Int).Add(secret, shift2)
+    proof.P2, err = ProveWithReader(rnd, xa, params.BP, seed)
+    if err != nil {
+        return proof, err
     }
 
     return proof, nil
 }
 
 /*
-Verify call the Verification algorithm for each BulletProof argument.
+bulletProofs returns copies of both BulletProofs, with the commitments derived from
+V and the interval of the verifier.
 */
-func (proof ProofBPRP) Verify() (bool, error) {
-    ok1, err1 := proof.P1.Verify()
+func (proof *RangeProof) bulletProofs(params *bprp) (*BulletProof, *BulletProof, error) {
+    if params == nil {
+        return nil, nil, ErrInvalidParams
+    }
+    if proof.V == nil {
+        return nil, nil, errors.New("invalid commitment")
+    }
+    shift1, shift2 := params.shifts()
+    V := commitment.FromPoint(proof.V, params.BP.H)
+    p1 := proof.P1
+    p1.V = V.AddConstant(shift1).C
+    p2 := proof.P2
+    p2.V = V.AddConstant(shift2).C
+    return &p1, &p2, nil
+}
+
+/*
+Verify call the Verification algorithm for each BulletProof argument, using the
+verifier's parameters.
+*/
+func (proof RangeProof) Verify(params *bprp) (bool, error) {
+    p1, p2, err := proof.bulletProofs(params)
+    if err != nil {
+        return false, err
+    }
+    ok1, err1 := p1.Verify(params.BP)
     if !ok1 {
         return false, err1
     }
-    ok2, err2 := proof.P2.Verify()
+    ok2, err2 := p2.Verify(params.BP)
     if !ok2 {
         return false, err2
     }
 
     return ok1 && ok2, nil
 }
+
+/*
+BatchVerifyGeneric verifies many generic range proofs at once, combining the
+underlying BulletProofs into a single BatchVerify call.
+*/
+func BatchVerifyGeneric(proofs []*RangeProof, params *bprp) (bool, error) {
+    bps := make([]*BulletProof, 0, 2*len(proofs))
+    for _, proof := range proofs {
+        p1, p2, err := proof.bulletProofs(params)
+        if err != nil {
+            return false, err
+        }
+        bps = append(bps, p1, p2)
+    }
+    return BatchVerify(bps, params.BP)
+}
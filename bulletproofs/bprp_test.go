@@ -55,6 +55,87 @@ func TestXEqualToRangeEndGeneric(t *testing.T) {
     }
 }
 
+func TestGenericRangeCommitment(t *testing.T) {
+    params, _ := SetupGeneric(18, 200)
+    secret := new(big.Int).SetInt64(40)
+    gamma := new(big.Int).SetInt64(12)
+    proof, _ := ProveGeneric(secret, params, gamma)
+    V, _ := params.Commit(secret, gamma)
+    assert.True(t, proof.V.Equals(V), "proof should expose the commitment to the secret")
+
+    // the commitment of the proof must not be replaceable by another one
+    other, _ := params.Commit(new(big.Int).SetInt64(41), gamma)
+    proof.V = other
+    ok, _ := proof.Verify(params)
+    assert.False(t, ok, "proof with a different commitment should not verify")
+}
+
+func TestGenericRangeBoundsAreBound(t *testing.T) {
+    params, _ := SetupGeneric(18, 200)
+    proof, _ := ProveGeneric(new(big.Int).SetInt64(40), params, new(big.Int).SetInt64(12))
+    other, _ := SetupGeneric(50, 232)
+    ok, _ := proof.Verify(other)
+    assert.False(t, ok, "proof should not verify for another interval of the same size")
+}
+
+func TestGenericRangeNegativeAndLarge(t *testing.T) {
+    cases := []struct {
+        a, b, x int64
+        valid   bool
+    }{
+        {-100, -10, -50, true},
+        {-100, -10, -10, false},
+        {-100, -10, -101, false},
+        {0, 1 << 32, 0, true},
+        {0, 1 << 32, 1<<32 - 1, true},
+        {0, 1 << 32, 1 << 32, false},
+        {1 << 40, 1<<40 + 3, 1<<40 + 2, true},
+    }
+    for _, c := range cases {
+        params, err := SetupGeneric(c.a, c.b)
+        if err != nil {
+            t.Fatal(err)
+        }
+        proof, _ := ProveGeneric(new(big.Int).SetInt64(c.x), params, new(big.Int).SetInt64(12))
+        ok, _ := proof.Verify(params)
+        if ok != c.valid {
+            t.Errorf("Assert failure: %d in [%d, %d) expected %v, got %v", c.x, c.a, c.b, c.valid, ok)
+        }
+    }
+}
+
+func TestGenericRangeZeroSecretAndBlinding(t *testing.T) {
+    params, _ := SetupGeneric(0, 10)
+    proof, err := ProveGeneric(new(big.Int), params, new(big.Int))
+    assert.NoError(t, err)
+    assert.True(t, proof.V.IsZero(), "commitment to 0 with blinding 0 should be the point at infinity")
+    ok, err := proof.Verify(params)
+    assert.NoError(t, err)
+    assert.True(t, ok, "should verify")
+}
+
+func TestSetupGenericInvalid(t *testing.T) {
+    _, err := SetupGeneric(200, 18)
+    assert.Error(t, err, "empty interval should be rejected")
+}
+
+func TestBatchVerifyGeneric(t *testing.T) {
+    params, _ := SetupGeneric(18, 200)
+    proofs := make([]*RangeProof, 3)
+    for i, x := range []int64{18, 40, 199} {
+        proof, _ := ProveGeneric(new(big.Int).SetInt64(x), params, new(big.Int).SetInt64(int64(i)+12))
+        proofs[i] = &proof
+    }
+    ok, err := BatchVerifyGeneric(proofs, params)
+    assert.NoError(t, err)
+    assert.True(t, ok, "batch should verify")
+
+    invalid, _ := ProveGeneric(new(big.Int).SetInt64(50), params, new(big.Int).SetInt64(15))
+    invalid.V = proofs[0].V
+    ok, _ = BatchVerifyGeneric(append(proofs, &invalid), params)
+    assert.False(t, ok, "batch containing an invalid proof should not verify")
+}
+
 func setupProveVerify18To200(t *testing.T, secret int) bool {
     params, errSetup := SetupGeneric(18, 200)
     if errSetup != nil {
@@ -62,12 +143,12 @@ func setupProveVerify18To200(t *testing.T, secret int) bool {
         t.FailNow()
     }
     bigSecret := new(big.Int).SetInt64(int64(secret))
-    proof, errProve := ProveGeneric(bigSecret, params)
+    proof, errProve := ProveGeneric(bigSecret, params, new(big.Int).SetInt64(12))
     if errProve != nil {
-        t.Errorf(errProve.Error())
-        t.FailNow()
+        // the prover refuses secrets out of the interval
+        return false
     }
-    ok, errVerify := proof.Verify()
+    ok, errVerify := proof.Verify(params)
     if errVerify != nil {
         t.Errorf(errVerify.Error())
         t.FailNow()
@@ -86,7 +167,7 @@ func TestJsonEncodeDecodeBPRP(t *testing.T) {
 
     // Create the proof
     bigSecret := new(big.Int).SetInt64(int64(40))
-    proof, errProve := ProveGeneric(bigSecret, params)
+    proof, errProve := ProveGeneric(bigSecret, params, new(big.Int).SetInt64(12))
     if errProve != nil {
         t.Errorf(errProve.Error())
         t.FailNow()
@@ -101,7 +182,7 @@ func TestJsonEncodeDecodeBPRP(t *testing.T) {
     // Here the proof is passed to the verifier, possibly over a network.
 
     // Decode the proof from JSON
-    var decodedProof ProofBPRP
+    var decodedProof RangeProof
     err = json.Unmarshal(jsonEncoded, &decodedProof)
     if err != nil {
         t.Fatal("decode error:", err)
@@ -110,7 +191,7 @@ func TestJsonEncodeDecodeBPRP(t *testing.T) {
     assert.Equal(t, proof, decodedProof, "should be equal")
 
     // Verify the proof
-    ok, errVerify := decodedProof.Verify()
+    ok, errVerify := decodedProof.Verify(params)
     if errVerify != nil {
         t.Errorf(errVerify.Error())
         t.FailNow()
@@ -30,5 +30,5 @@ import (
 
 var ORDER = p256.CURVE.N
 var SEEDH = "BulletproofsDoesNotNeedTrustedSetupH"
-var MAX_RANGE_END int64 = 4294967296 // 2**32
-var MAX_RANGE_END_EXPONENT = 32      // 2**32
+var MAX_RANGE_BITS int64 = 64     // ranges up to [0, 2**64), enforced by Setup
+var MAX_AGGREGATE_SIZE int64 = 64 // secrets in an aggregated proof
@@ -0,0 +1,316 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bulletproofs
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "math/big"
+
+    "github.com/ing-bank/zkrp/crypto/p256"
+    "github.com/ing-bank/zkrp/util/bn"
+)
+
+/*
+The binary encoding of the proofs only contains the elements produced by the prover.
+Points are written in compressed form (see p256.P256.MarshalBinary) and scalars as
+32 bytes in big-endian order. The public parameters are left out and only referred
+to by their ParamsID, since the verifier must use its own.
+*/
+
+// ScalarSize is the length in bytes of an encoded scalar.
+const ScalarSize = 32
+
+var errTrailingBytes = errors.New("unexpected trailing bytes")
+
+func writePoints(buf *bytes.Buffer, points ...*p256.P256) error {
+    for _, p := range points {
+        if p == nil {
+            return errors.New("can not encode nil point")
+        }
+        data, err := p.MarshalBinary()
+        if err != nil {
+            return err
+        }
+        buf.Write(data)
+    }
+    return nil
+}
+
+func writeScalars(buf *bytes.Buffer, scalars ...*big.Int) error {
+    for _, k := range scalars {
+        if k == nil {
+            return errors.New("can not encode nil scalar")
+        }
+        data := make([]byte, ScalarSize)
+        kBytes := bn.Mod(k, ORDER).Bytes()
+        copy(data[ScalarSize-len(kBytes):], kBytes)
+        buf.Write(data)
+    }
+    return nil
+}
+
+/*
+readPoint rejects the point at infinity, which is never part of an honest proof.
+*/
+func readPoint(r *bytes.Reader) (*p256.P256, error) {
+    p, err := readCommitment(r)
+    if err != nil {
+        return nil, err
+    }
+    if p.IsZero() {
+        return nil, p256.ErrInfinity
+    }
+    return p, nil
+}
+
+/*
+readCommitment accepts the point at infinity, which is the commitment to 0 with a
+zero blinding factor.
+*/
+func readCommitment(r *bytes.Reader) (*p256.P256, error) {
+    data := make([]byte, p256.PointSize)
+    if _, err := io.ReadFull(r, data); err != nil {
+        return nil, err
+    }
+    p := new(p256.P256)
+    if err := p.UnmarshalBinary(data); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+func readScalar(r *bytes.Reader) (*big.Int, error) {
+    data := make([]byte, ScalarSize)
+    if _, err := io.ReadFull(r, data); err != nil {
+        return nil, err
+    }
+    k := new(big.Int).SetBytes(data)
+    if k.Cmp(ORDER) >= 0 {
+        return nil, errors.New("scalar is not reduced modulo the group order")
+    }
+    return k, nil
+}
+
+/*
+MarshalBinary encodes the inner product proof as: the number of rounds k (1 byte),
+L[0..k), R[0..k), A and B. The generator U is left out, since the verifier uses its
+own.
+*/
+func (proof *InnerProductProof) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    err := proof.writeTo(&buf)
+    return buf.Bytes(), err
+}
+
+/*
+UnmarshalBinary decodes an inner product proof encoded with MarshalBinary.
+*/
+func (proof *InnerProductProof) UnmarshalBinary(data []byte) error {
+    r := bytes.NewReader(data)
+    if err := proof.readFrom(r); err != nil {
+        return err
+    }
+    if r.Len() != 0 {
+        return errTrailingBytes
+    }
+    return nil
+}
+
+func (proof *InnerProductProof) writeTo(buf *bytes.Buffer) error {
+    k := len(proof.Ls)
+    if k != len(proof.Rs) || k > 255 {
+        return errors.New("invalid number of rounds in inner product proof")
+    }
+    buf.WriteByte(byte(k))
+    if err := writePoints(buf, proof.Ls...); err != nil {
+        return err
+    }
+    if err := writePoints(buf, proof.Rs...); err != nil {
+        return err
+    }
+    return writeScalars(buf, proof.A, proof.B)
+}
+
+func (proof *InnerProductProof) readFrom(r *bytes.Reader) error {
+    k, err := r.ReadByte()
+    if err != nil {
+        return err
+    }
+    if k > 62 {
+        return errors.New("invalid number of rounds in inner product proof")
+    }
+    Ls := make([]*p256.P256, k)
+    Rs := make([]*p256.P256, k)
+    for i := range Ls {
+        if Ls[i], err = readPoint(r); err != nil {
+            return err
+        }
+    }
+    for i := range Rs {
+        if Rs[i], err = readPoint(r); err != nil {
+            return err
+        }
+    }
+    var ip InnerProductProof
+    if ip.A, err = readScalar(r); err != nil {
+        return err
+    }
+    if ip.B, err = readScalar(r); err != nil {
+        return err
+    }
+    ip.N = int64(1) << k
+    ip.Ls = Ls
+    ip.Rs = Rs
+    *proof = ip
+    return nil
+}
+
+/*
+MarshalBinary encodes the proof as: ParamsID, V, A, S, T1, T2, Commit, Taux, Mu,
+Tprime and the inner product proof. The public parameters are not included.
+*/
+func (proof *BulletProof) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    err := proof.writeTo(&buf)
+    return buf.Bytes(), err
+}
+
+/*
+UnmarshalBinary decodes a proof encoded with MarshalBinary.
+*/
+func (proof *BulletProof) UnmarshalBinary(data []byte) error {
+    r := bytes.NewReader(data)
+    if err := proof.readFrom(r); err != nil {
+        return err
+    }
+    if r.Len() != 0 {
+        return errTrailingBytes
+    }
+    return nil
+}
+
+func (proof *BulletProof) writeTo(buf *bytes.Buffer) error {
+    buf.Write(proof.ParamsID[:])
+    if err := writePoints(buf, proof.V); err != nil {
+        return err
+    }
+    return proof.writeArgument(buf)
+}
+
+/*
+writeArgument writes the elements of the proof that follow ParamsID and V.
+*/
+func (proof *BulletProof) writeArgument(buf *bytes.Buffer) error {
+    if err := writePoints(buf, proof.A, proof.S, proof.T1, proof.T2, proof.Commit); err != nil {
+        return err
+    }
+    if err := writeScalars(buf, proof.Taux, proof.Mu, proof.Tprime); err != nil {
+        return err
+    }
+    return proof.InnerProductProof.writeTo(buf)
+}
+
+func (proof *BulletProof) readFrom(r *bytes.Reader) error {
+    var (
+        bp  BulletProof
+        err error
+    )
+    if _, err = io.ReadFull(r, bp.ParamsID[:]); err != nil {
+        return err
+    }
+    if bp.V, err = readCommitment(r); err != nil {
+        return err
+    }
+    if err = bp.readArgument(r); err != nil {
+        return err
+    }
+    *proof = bp
+    return nil
+}
+
+/*
+readArgument reads the elements of the proof written by writeArgument.
+*/
+func (proof *BulletProof) readArgument(r *bytes.Reader) error {
+    var err error
+    points := []**p256.P256{&proof.A, &proof.S, &proof.T1, &proof.T2, &proof.Commit}
+    for _, p := range points {
+        if *p, err = readPoint(r); err != nil {
+            return err
+        }
+    }
+    scalars := []**big.Int{&proof.Taux, &proof.Mu, &proof.Tprime}
+    for _, k := range scalars {
+        if *k, err = readScalar(r); err != nil {
+            return err
+        }
+    }
+    return proof.InnerProductProof.readFrom(r)
+}
+
+/*
+MarshalBinary encodes the generic range proof as: ParamsID, V and both BulletProofs
+without their ParamsID and V. Both BulletProofs use the same parameters, and their
+commitments are derived from V by the verifier.
+*/
+func (proof *RangeProof) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    if proof.P1.ParamsID != proof.P2.ParamsID {
+        return nil, ErrParamsMismatch
+    }
+    buf.Write(proof.P1.ParamsID[:])
+    if err := writePoints(&buf, proof.V); err != nil {
+        return nil, err
+    }
+    if err := proof.P1.writeArgument(&buf); err != nil {
+        return nil, err
+    }
+    err := proof.P2.writeArgument(&buf)
+    return buf.Bytes(), err
+}
+
+/*
+UnmarshalBinary decodes a generic range proof encoded with MarshalBinary.
+*/
+func (proof *RangeProof) UnmarshalBinary(data []byte) error {
+    var (
+        p   RangeProof
+        err error
+    )
+    r := bytes.NewReader(data)
+    if _, err = io.ReadFull(r, p.P1.ParamsID[:]); err != nil {
+        return err
+    }
+    p.P2.ParamsID = p.P1.ParamsID
+    if p.V, err = readCommitment(r); err != nil {
+        return err
+    }
+    if err = p.P1.readArgument(r); err != nil {
+        return err
+    }
+    if err = p.P2.readArgument(r); err != nil {
+        return err
+    }
+    if r.Len() != 0 {
+        return errTrailingBytes
+    }
+    *proof = p
+    return nil
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bulletproofs
+
+import (
+    "encoding/json"
+    "math/big"
+    "testing"
+
+    "github.com/ing-bank/zkrp/crypto/p256"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestBinaryEncodeDecode(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    proof, _ := Prove(new(big.Int).SetInt64(18), params, new(big.Int).SetInt64(12))
+    data, err := proof.MarshalBinary()
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    jsonEncoded, _ := json.Marshal(proof)
+    assert.True(t, len(data)*3 < len(jsonEncoded), "binary encoding should be much smaller than JSON")
+
+    var decodedProof BulletProof
+    err = decodedProof.UnmarshalBinary(data)
+    if err != nil {
+        t.Fatal("decode error:", err)
+    }
+    ok, err := decodedProof.Verify(params)
+    if err != nil {
+        t.Fatal("verify error:", err)
+    }
+    assert.True(t, ok, "should verify")
+}
+
+func TestBinaryEncodeDecodeBPRP(t *testing.T) {
+    params, _ := SetupGeneric(18, 200)
+    proof, _ := ProveGeneric(new(big.Int).SetInt64(40), params, new(big.Int).SetInt64(12))
+    data, err := proof.MarshalBinary()
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+
+    var decodedProof RangeProof
+    err = decodedProof.UnmarshalBinary(data)
+    if err != nil {
+        t.Fatal("decode error:", err)
+    }
+    assert.True(t, decodedProof.V.Equals(proof.V), "commitments should be equal")
+
+    ok, _ := decodedProof.Verify(params)
+    assert.True(t, ok, "should verify")
+}
+
+func TestBinaryDecodeTruncated(t *testing.T) {
+    params, _ := Setup(testRangeEnd)
+    proof, _ := Prove(new(big.Int).SetInt64(18), params, new(big.Int).SetInt64(12))
+    data, _ := proof.MarshalBinary()
+
+    var decodedProof BulletProof
+    assert.Error(t, decodedProof.UnmarshalBinary(data[:len(data)-1]), "truncated proof should be rejected")
+    assert.Error(t, decodedProof.UnmarshalBinary(append(data, 0)), "trailing bytes should be rejected")
+}
+
+func TestBinaryEncodeDecodeZeroCommitment(t *testing.T) {
+    zero := new(big.Int)
+    params, _ := Setup(testRangeEnd)
+    proof, _ := Prove(zero, params, zero)
+    assert.True(t, proof.V.IsZero(), "commitment to 0 with a zero blinding factor should be the point at infinity")
+    data, err := proof.MarshalBinary()
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    var decodedProof BulletProof
+    if err = decodedProof.UnmarshalBinary(data); err != nil {
+        t.Fatal("decode error:", err)
+    }
+    ok, _ := decodedProof.Verify(params)
+    assert.True(t, ok, "should verify")
+
+    rpParams, _ := SetupGeneric(0, 200)
+    rp, _ := ProveGeneric(zero, rpParams, zero)
+    data, err = rp.MarshalBinary()
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    var decodedRP RangeProof
+    if err = decodedRP.UnmarshalBinary(data); err != nil {
+        t.Fatal("decode error:", err)
+    }
+    assert.True(t, decodedRP.V.IsZero(), "commitment should be the point at infinity")
+    ok, _ = decodedRP.Verify(rpParams)
+    assert.True(t, ok, "should verify")
+}
+
+func TestBinaryDecodeInvalidPoint(t *testing.T) {
+    params, _ := SetupGeneric(18, 200)
+    proof, _ := ProveGeneric(new(big.Int).SetInt64(40), params, new(big.Int).SetInt64(12))
+    data, _ := proof.MarshalBinary()
+    // the encoding starts with ParamsID, V and A
+    v := len(ParamsID{})
+    a := v + p256.PointSize
+
+    var decodedProof RangeProof
+    infinity := append([]byte{}, data...)
+    copy(infinity[a:a+p256.PointSize], make([]byte, p256.PointSize))
+    assert.Equal(t, p256.ErrInfinity, decodedProof.UnmarshalBinary(infinity), "point at infinity should be rejected")
+
+    // x = 5 is not the coordinate of a point of secp256k1
+    offCurve := append([]byte{}, data...)
+    copy(offCurve[v:a], append([]byte{2}, make([]byte, p256.PointSize-1)...))
+    offCurve[a-1] = 5
+    assert.Equal(t, p256.ErrNotOnCurve, decodedProof.UnmarshalBinary(offCurve), "point not on the curve should be rejected")
+}
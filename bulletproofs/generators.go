@@ -0,0 +1,229 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bulletproofs
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "math/big"
+    "sort"
+    "sync"
+
+    "github.com/ing-bank/zkrp/crypto/p256"
+)
+
+/*
+GeneratorsDST is the domain separation tag used to hash the labels of the
+generators to the curve, following RFC 9380. Any implementation can recompute the
+generators as HashToCurve(GeneratorsDST, label) with the labels:
+    H:     SEEDH
+    U:     SEEDU
+    Gg[i]: SEEDH || "g" || i
+    Hh[i]: SEEDH || "h" || i
+where i is written in decimal.
+*/
+const GeneratorsDST = "ZKRP-BULLETPROOFS-V01-CS01-with-" + p256.HashToCurveSuite
+
+/*
+generators caches the generators by label, since they are the same for every set of
+parameters and hashing them to the curve dominates the cost of Setup.
+*/
+var generators = struct {
+    sync.RWMutex
+    points map[string]*p256.P256
+}{points: make(map[string]*p256.P256)}
+
+/*
+generator hashes the label to a new generator, whose discrete logarithm relation
+with the other generators is unknown. The result is cached and a copy is returned,
+so that the callers can not modify the cached point.
+*/
+func generator(label string) (*p256.P256, error) {
+    generators.RLock()
+    P, ok := generators.points[label]
+    generators.RUnlock()
+    if ok {
+        return P.Copy(), nil
+    }
+    P, err := p256.HashToCurve([]byte(GeneratorsDST), []byte(label))
+    if err != nil {
+        return nil, err
+    }
+    generators.Lock()
+    generators.points[label] = P
+    generators.Unlock()
+    return P.Copy(), nil
+}
+
+func generatorG(i int64) (*p256.P256, error) {
+    return generator(SEEDH + "g" + fmt.Sprint(i))
+}
+
+func generatorH(i int64) (*p256.P256, error) {
+    return generator(SEEDH + "h" + fmt.Sprint(i))
+}
+
+/*
+fullSetupID is the ParamsID of Setup(2^MAX_RANGE_BITS), whose generators include the
+ones of every range. It was computed by hashing the labels of the generators to the
+curve, which TestFullSetupID repeats, and LoadGenerators checks the loaded
+generators against it.
+*/
+const fullSetupID = "261afbf106cc972fe39d259f253b950a48815842c42258aa3eb2775bf3a27b0c"
+
+/*
+fullSetupLabels returns the labels of the generators H, Gg and Hh of
+Setup(2^MAX_RANGE_BITS), in the order of the parameters.
+*/
+func fullSetupLabels() []string {
+    size := nextPowerOfTwo(MAX_RANGE_BITS)
+    labels := []string{SEEDH}
+    for i := int64(0); i < size; i++ {
+        labels = append(labels, SEEDH+"g"+fmt.Sprint(i))
+    }
+    for i := int64(0); i < size; i++ {
+        labels = append(labels, SEEDH+"h"+fmt.Sprint(i))
+    }
+    return labels
+}
+
+/*
+fullSetupParams returns the parameters of Setup(2^MAX_RANGE_BITS) made of the given
+generators, indexed by label, or ErrInvalidParams if one of them is missing.
+*/
+func fullSetupParams(points map[string]*p256.P256) (BulletProofSetupParams, error) {
+    labels := fullSetupLabels()
+    size := nextPowerOfTwo(MAX_RANGE_BITS)
+    params := BulletProofSetupParams{N: MAX_RANGE_BITS}
+    params.G = new(p256.P256).ScalarBaseMult(new(big.Int).SetInt64(1))
+    params.H = points[labels[0]]
+    params.Gg = make([]*p256.P256, size)
+    params.Hh = make([]*p256.P256, size)
+    for i := int64(0); i < size; i++ {
+        params.Gg[i] = points[labels[1+i]]
+        params.Hh[i] = points[labels[1+size+i]]
+    }
+    if _, err := params.ID(); err != nil {
+        return params, err
+    }
+    return params, nil
+}
+
+/*
+ExportGenerators writes the generators of Setup(2^MAX_RANGE_BITS), which include the
+ones of every range, so that they can be loaded by LoadGenerators instead of being
+hashed to the curve again. The encoding starts with GeneratorsDST, followed by the
+number of generators and, for each of them in the order of their labels, the length
+of the label (2 bytes), the label and the point in compressed form. The integers
+are big-endian.
+*/
+func ExportGenerators(w io.Writer) error {
+    labels := fullSetupLabels()
+    sort.Strings(labels)
+    var buf bytes.Buffer
+    writeLabel(&buf, GeneratorsDST)
+    _ = binary.Write(&buf, binary.BigEndian, uint32(len(labels)))
+    for _, label := range labels {
+        P, err := generator(label)
+        if err != nil {
+            return err
+        }
+        writeLabel(&buf, label)
+        if err := writePoints(&buf, P); err != nil {
+            return err
+        }
+    }
+    _, err := buf.WriteTo(w)
+    return err
+}
+
+/*
+LoadGenerators adds to the cache the generators written by ExportGenerators, which
+makes Setup skip hashing them to the curve. The points are checked to be on the
+curve, and the ParamsID of the parameters they form is checked against fullSetupID,
+which was computed from the labels, so that generators with a known discrete
+logarithm relation, which would allow to forge proofs, are rejected. The generators
+of other labels are ignored. Nothing is loaded if the data is invalid.
+*/
+func LoadGenerators(r io.Reader) error {
+    br := bufio.NewReader(r)
+    dst, err := readLabel(br)
+    if err != nil {
+        return err
+    }
+    if dst != GeneratorsDST {
+        return errors.New("generators were computed with another domain separation tag")
+    }
+    var count uint32
+    if err := binary.Read(br, binary.BigEndian, &count); err != nil {
+        return err
+    }
+    points := make(map[string]*p256.P256)
+    for i := uint32(0); i < count; i++ {
+        label, err := readLabel(br)
+        if err != nil {
+            return err
+        }
+        data := make([]byte, p256.PointSize)
+        if _, err := io.ReadFull(br, data); err != nil {
+            return err
+        }
+        P := new(p256.P256)
+        if err := P.UnmarshalBinary(data); err != nil {
+            return err
+        }
+        if P.IsZero() {
+            return p256.ErrInfinity
+        }
+        points[label] = P
+    }
+    params, err := fullSetupParams(points)
+    if err != nil {
+        return err
+    }
+    if id, _ := params.ID(); id.String() != fullSetupID {
+        return errors.New("generators do not match the hashes of their labels")
+    }
+    generators.Lock()
+    for _, label := range fullSetupLabels() {
+        generators.points[label] = points[label]
+    }
+    generators.Unlock()
+    return nil
+}
+
+func writeLabel(buf *bytes.Buffer, label string) {
+    _ = binary.Write(buf, binary.BigEndian, uint16(len(label)))
+    buf.WriteString(label)
+}
+
+func readLabel(r io.Reader) (string, error) {
+    var n uint16
+    if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+        return "", err
+    }
+    label := make([]byte, n)
+    if _, err := io.ReadFull(r, label); err != nil {
+        return "", err
+    }
+    return string(label), nil
+}
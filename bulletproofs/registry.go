@@ -0,0 +1,146 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bulletproofs
+
+import (
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/hex"
+    "errors"
+    "sync"
+
+    "github.com/ing-bank/zkrp/crypto/p256"
+)
+
+/*
+ParamsID identifies a set of public parameters. It is the SHA-256 digest of N and
+of the generators G, H, Gg and Hh, so two parameter sets share an ID if and only if
+they use the same generators.
+*/
+type ParamsID [sha256.Size]byte
+
+/*
+ErrParamsMismatch is returned by Verify when the proof was computed using
+different public parameters than the ones provided by the verifier.
+*/
+var ErrParamsMismatch = errors.New("proof was not computed with the given parameters")
+
+/*
+ErrInvalidParams is returned when the public parameters are incomplete, e.g. when
+a generator is missing, or do not match N.
+*/
+var ErrInvalidParams = errors.New("invalid setup parameters")
+
+/*
+String returns the hexadecimal representation of the ID.
+*/
+func (id ParamsID) String() string {
+    return hex.EncodeToString(id[:])
+}
+
+/*
+MarshalText encodes the ID in hexadecimal, so that it is readable in JSON.
+*/
+func (id ParamsID) MarshalText() ([]byte, error) {
+    return []byte(id.String()), nil
+}
+
+/*
+UnmarshalText decodes an ID encoded with MarshalText.
+*/
+func (id *ParamsID) UnmarshalText(text []byte) error {
+    var decoded ParamsID
+    if hex.DecodedLen(len(text)) != len(decoded) {
+        return errors.New("invalid parameters ID length")
+    }
+    if _, err := hex.Decode(decoded[:], text); err != nil {
+        return err
+    }
+    *id = decoded
+    return nil
+}
+
+/*
+ID computes the identifier of the parameters. It returns ErrInvalidParams instead of
+an ID if the parameters are incomplete, so that they can not match a proof whose
+ParamsID is missing.
+*/
+func (params *BulletProofSetupParams) ID() (ParamsID, error) {
+    var id ParamsID
+    if _, err := params.generatorsSize(); err != nil {
+        return id, err
+    }
+    digest := sha256.New()
+    _ = binary.Write(digest, binary.BigEndian, params.N)
+    points := append([]*p256.P256{params.G, params.H}, params.Gg...)
+    points = append(points, params.Hh...)
+    for _, p := range points {
+        if p == nil {
+            return id, ErrInvalidParams
+        }
+        data, _ := p.MarshalBinary()
+        digest.Write(data)
+    }
+    copy(id[:], digest.Sum(nil))
+    return id, nil
+}
+
+/*
+registry contains every set of parameters built by Setup in this process, so that
+a verifier can resolve the ParamsID carried by a proof into its own generators.
+*/
+var registry = struct {
+    sync.RWMutex
+    params map[ParamsID]BulletProofSetupParams
+}{params: make(map[ParamsID]BulletProofSetupParams)}
+
+func registerParams(params BulletProofSetupParams) error {
+    id, err := params.ID()
+    if err != nil {
+        return err
+    }
+    registry.Lock()
+    if _, ok := registry.params[id]; !ok {
+        registry.params[id] = params
+    }
+    registry.Unlock()
+    return nil
+}
+
+/*
+LookupParams returns the parameters with the given ID, if they were built by
+Setup in this process.
+*/
+func LookupParams(id ParamsID) (BulletProofSetupParams, bool) {
+    registry.RLock()
+    params, ok := registry.params[id]
+    registry.RUnlock()
+    return params, ok
+}
+
+/*
+VerifyByID verifies the proof using the local parameters registered under the
+ID the proof claims to be computed with.
+*/
+func (proof *BulletProof) VerifyByID() (bool, error) {
+    params, ok := LookupParams(proof.ParamsID)
+    if !ok {
+        return false, errors.New("unknown parameters " + proof.ParamsID.String())
+    }
+    return proof.Verify(params)
+}
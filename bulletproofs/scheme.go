@@ -0,0 +1,169 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bulletproofs
+
+import (
+    "encoding/binary"
+    "errors"
+    "math/big"
+
+    "github.com/ing-bank/zkrp"
+    "github.com/ing-bank/zkrp/crypto/p256"
+)
+
+/*
+SchemeName is the name of the generic BulletProofs range proofs in the zkrp
+registry.
+*/
+const SchemeName = "bulletproofs"
+
+func init() {
+    zkrp.Register(scheme{})
+}
+
+/*
+scheme implements zkrp.Scheme using SetupGeneric.
+*/
+type scheme struct{}
+
+func (scheme) Name() string {
+    return SchemeName
+}
+
+/*
+Setup returns the same value as the parameters of the provers and the key of the
+verifier, since the setup of BulletProofs has no secret.
+*/
+func (scheme) Setup(a, b int64) (zkrp.ProverParams, zkrp.VerifierKey, error) {
+    params, err := SetupGeneric(a, b)
+    if err != nil {
+        return nil, nil, err
+    }
+    return schemeParams{params}, schemeParams{params}, nil
+}
+
+/*
+UnmarshalVerifierKey decodes the interval of the key and runs SetupGeneric, which
+derives the same generators.
+*/
+func (scheme) UnmarshalVerifierKey(data []byte) (zkrp.VerifierKey, error) {
+    if len(data) != 16 {
+        return nil, errors.New("invalid verifier key length")
+    }
+    a := int64(binary.BigEndian.Uint64(data[:8]))
+    b := int64(binary.BigEndian.Uint64(data[8:]))
+    params, err := SetupGeneric(a, b)
+    if err != nil {
+        return nil, err
+    }
+    return schemeParams{params}, nil
+}
+
+/*
+schemeParams implements zkrp.ProverParams and zkrp.VerifierKey for the parameters
+of the generic range proofs.
+*/
+type schemeParams struct {
+    params *bprp
+}
+
+func (p schemeParams) Interval() (int64, int64) {
+    return p.params.A, p.params.B
+}
+
+func (p schemeParams) Commit(x, r *big.Int) (zkrp.Commitment, error) {
+    V, err := p.params.Commit(x, r)
+    if err != nil {
+        return nil, err
+    }
+    return V, nil
+}
+
+func (p schemeParams) Prove(x, r *big.Int) (zkrp.Proof, error) {
+    proof, err := ProveGeneric(x, p.params, r)
+    if err != nil {
+        return nil, err
+    }
+    return &proof, nil
+}
+
+func (p schemeParams) Verify(proof zkrp.Proof) (bool, error) {
+    rp, ok := proof.(*RangeProof)
+    if !ok {
+        return false, zkrp.ErrWrongProofType
+    }
+    return rp.Verify(p.params)
+}
+
+/*
+BatchVerify implements zkrp.BatchVerifier with BatchVerifyGeneric.
+*/
+func (p schemeParams) BatchVerify(proofs []zkrp.Proof) (bool, error) {
+    rps := make([]*RangeProof, len(proofs))
+    for i, proof := range proofs {
+        rp, ok := proof.(*RangeProof)
+        if !ok {
+            return false, zkrp.ErrWrongProofType
+        }
+        rps[i] = rp
+    }
+    return BatchVerifyGeneric(rps, p.params)
+}
+
+func (p schemeParams) UnmarshalProof(data []byte) (zkrp.Proof, error) {
+    proof := new(RangeProof)
+    if err := proof.UnmarshalBinary(data); err != nil {
+        return nil, err
+    }
+    return proof, nil
+}
+
+func (p schemeParams) UnmarshalCommitment(data []byte) (zkrp.Commitment, error) {
+    V := new(p256.P256)
+    if err := V.UnmarshalBinary(data); err != nil {
+        return nil, err
+    }
+    return V, nil
+}
+
+func (p schemeParams) AddCommitments(a, b zkrp.Commitment) (zkrp.Commitment, error) {
+    Va, ok1 := a.(*p256.P256)
+    Vb, ok2 := b.(*p256.P256)
+    if !ok1 || !ok2 {
+        return nil, errors.New("commitment was computed by another scheme")
+    }
+    return new(p256.P256).Add(Va, Vb).Normalize(), nil
+}
+
+/*
+MarshalBinary encodes the interval [A, B) of the key as two integers of 8 bytes in
+big-endian order, since the generators are derived from it.
+*/
+func (p schemeParams) MarshalBinary() ([]byte, error) {
+    data := make([]byte, 16)
+    binary.BigEndian.PutUint64(data[:8], uint64(p.params.A))
+    binary.BigEndian.PutUint64(data[8:], uint64(p.params.B))
+    return data, nil
+}
+
+/*
+Commitment returns the commitment V to the secret.
+*/
+func (proof *RangeProof) Commitment() zkrp.Commitment {
+    return proof.V
+}
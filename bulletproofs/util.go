@@ -18,12 +18,11 @@
 package bulletproofs
 
 import (
-    "bytes"
-    "crypto/sha256"
     "errors"
     "math/big"
 
     "github.com/ing-bank/zkrp/crypto/p256"
+    "github.com/ing-bank/zkrp/crypto/transcript"
     "github.com/ing-bank/zkrp/util/bn"
     "github.com/ing-bank/zkrp/util/intconversion"
 )
@@ -49,56 +48,39 @@ func powerOf(x *big.Int, n int64) []*big.Int {
 }
 
 /*
-Hash is responsible for the computing a Zp element given elements from GT and G1.
+appendPoints appends the compressed encoding of each point to the transcript.
 */
-func HashBP(A, S *p256.P256) (*big.Int, *big.Int, error) {
-
-    digest1 := sha256.New()
-    var buffer bytes.Buffer
-    buffer.WriteString(A.X.String())
-    buffer.WriteString(A.Y.String())
-    buffer.WriteString(S.X.String())
-    buffer.WriteString(S.Y.String())
-    digest1.Write(buffer.Bytes())
-    output1 := digest1.Sum(nil)
-    tmp1 := output1[0:]
-    result1 := new(big.Int).SetBytes(tmp1)
+func appendPoints(t *transcript.Transcript, label string, points ...*p256.P256) {
+    for _, p := range points {
+        data, _ := p.MarshalBinary()
+        t.AppendMessage(label, data)
+    }
+}
 
-    digest2 := sha256.New()
-    var buffer2 bytes.Buffer
-    buffer2.WriteString(A.X.String())
-    buffer2.WriteString(A.Y.String())
-    buffer2.WriteString(S.X.String())
-    buffer2.WriteString(S.Y.String())
-    buffer2.WriteString(result1.String())
-    digest2.Write(buffer.Bytes())
-    output2 := digest2.Sum(nil)
-    tmp2 := output2[0:]
-    result2 := new(big.Int).SetBytes(tmp2)
+/*
+appendScalars appends each scalar, reduced modulo the group order, to the transcript.
+*/
+func appendScalars(t *transcript.Transcript, label string, scalars ...*big.Int) {
+    for _, k := range scalars {
+        t.AppendScalar(label, bn.Mod(k, ORDER))
+    }
+}
 
-    return result1, result2, nil
+/*
+challenge squeezes a Zp element from the transcript, as in the Fiat-Shamir heuristic.
+*/
+func challenge(t *transcript.Transcript, label string) *big.Int {
+    return t.ChallengeScalar(label, ORDER)
 }
 
 /*
-VectorExp computes Prod_i^n{a[i]^b[i]}.
+VectorExp computes Prod_i^n{a[i]^b[i]} using a multi-scalar multiplication.
 */
 func VectorExp(a []*p256.P256, b []*big.Int) (*p256.P256, error) {
-    var (
-        result  *p256.P256
-        i, n, m int64
-    )
-    n = int64(len(a))
-    m = int64(len(b))
-    if n != m {
+    if len(a) != len(b) {
         return nil, errors.New("Size of first argument is different from size of second argument.")
     }
-    i = 0
-    result = new(p256.P256).SetInfinity()
-    for i < n {
-        result.Multiply(result, new(p256.P256).ScalarMult(a[i], b[i]))
-        i = i + 1
-    }
-    return result, nil
+    return p256.MultiScalarMult(a, b), nil
 }
 
 /*
@@ -132,3 +114,26 @@ https://stackoverflow.com/a/600306/844313
 func IsPowerOfTwo(x int64) bool {
     return (x != 0) && ((x & (x - 1)) == 0)
 }
+
+/*
+nextPowerOfTwo returns the smallest power of 2 greater than or equal to x.
+*/
+func nextPowerOfTwo(x int64) int64 {
+    result := int64(1)
+    for result < x {
+        result = result << 1
+    }
+    return result
+}
+
+/*
+padVector returns a copy of a extended with zeros to length n.
+*/
+func padVector(a []*big.Int, n int64) []*big.Int {
+    result := make([]*big.Int, n)
+    copy(result, a)
+    for i := int64(len(a)); i < n; i++ {
+        result[i] = new(big.Int)
+    }
+    return result
+}
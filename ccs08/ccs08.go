@@ -28,134 +28,324 @@ import (
     "bytes"
     "crypto/rand"
     "errors"
-    "math"
+    "io"
     "math/big"
-    "strconv"
+    "sync"
 
     "github.com/ing-bank/zkrp/crypto/bbsignatures"
     "github.com/ing-bank/zkrp/crypto/bn256"
+    "github.com/ing-bank/zkrp/crypto/transcript"
     . "github.com/ing-bank/zkrp/util"
     "github.com/ing-bank/zkrp/util/bn"
-    "github.com/ing-bank/zkrp/util/intconversion"
 )
 
 /*
-paramsSet contains elements generated by the verifier, which are necessary for the prover.
-This must be computed in a trusted setup.
+HDST is the domain separation tag used to hash the label "H" to the curve, following
+RFC 9380, in order to obtain the generator H of the commitments. Its discrete
+logarithm relation with the generator of the group is unknown.
 */
-type paramsSet struct {
-    signatures map[int64]*bn256.G2
+const HDST = "ZKRP-CCS08-V01-CS01-with-" + bn256.HashToG2Suite
+
+func generatorH() (*bn256.G2, error) {
+    return bn256.HashToG2([]byte(HDST), []byte("H"))
+}
+
+var (
+    hOnce  sync.Once
+    hBytes []byte
+    hErr   error
+)
+
+/*
+expectedH returns the encoding of the generator H, which is hashed to the curve only
+once, so that VerifyParams does not repeat it for each verification.
+*/
+func expectedH() ([]byte, error) {
+    hOnce.Do(func() {
+        var H *bn256.G2
+        if H, hErr = generatorH(); hErr == nil {
+            hBytes = H.Marshal()
+        }
+    })
+    return hBytes, hErr
+}
+
+/*
+ErrInvalidH is returned by VerifyParams when H was not derived by hashing to the
+curve. The discrete logarithm of such an H may be known, in which case the
+commitments are not binding.
+*/
+var ErrInvalidH = errors.New("H is not the generator obtained by hashing to the curve")
+
+/*
+VerifyParams checks that the H of the public parameters is equal to the generator
+obtained by hashing to the curve with HDST, which is computed once per process.
+*/
+func VerifyParams(H *bn256.G2) error {
+    expected, err := expectedH()
+    if err != nil {
+        return err
+    }
+    if H == nil || !bytes.Equal(H.Marshal(), expected) {
+        return ErrInvalidH
+    }
+    return nil
+}
+
+/*
+ErrInvalidProof is returned by the verifiers when an element of the proof is missing
+or is the point at infinity. A blinded signature V equal to the point at infinity
+would satisfy the pairing equation for any element, so that the proof could be
+forged.
+*/
+var ErrInvalidProof = errors.New("proof contains a missing element or the point at infinity")
+
+/*
+checkPoints returns ErrInvalidProof if any of the points is nil or the point at
+infinity.
+*/
+func checkPoints(points ...*bn256.G2) error {
+    for _, P := range points {
+        if P == nil || P.IsZero() {
+            return ErrInvalidProof
+        }
+    }
+    return nil
+}
+
+/*
+ErrInvalidSignatures is returned by VerifySignatures when the published signatures
+are not valid signatures of their elements under the public key.
+*/
+var ErrInvalidSignatures = errors.New("invalid signatures in the public parameters")
+
+/*
+VerifySignatures checks all the signatures of the public parameters at once, using
+bbsignatures.BatchVerify. It is called when the parameters are decoded, so that the
+verifier does not need to trust the party that published them.
+*/
+func VerifySignatures(pubk *bn256.G1, signatures map[int64]*bn256.G2) error {
+    sigs := make([]*bn256.G2, 0, len(signatures))
+    messages := make([]*big.Int, 0, len(signatures))
+    for x, signature := range signatures {
+        sigs = append(sigs, signature)
+        messages = append(messages, new(big.Int).SetInt64(x))
+    }
+    ok, err := bbsignatures.BatchVerify(sigs, messages, pubk)
+    if err != nil {
+        return err
+    }
+    if !ok {
+        return ErrInvalidSignatures
+    }
+    return nil
+}
+
+/*
+SetParams contains elements generated by the verifier, which are necessary for the prover.
+This must be computed in a trusted setup. Only the verifier who ran SetupSet knows the
+private key of the signatures; Public returns the view that can be sent to provers.
+*/
+type SetParams struct {
+    // Signatures contains the signature of each element of the set.
+    Signatures map[int64]*bn256.G2
     H          *bn256.G2
-    kp         bbsignatures.Keypair
-    // u determines the amount of signatures we need in the public params.
-    // Each signature can be compressed to just 1 field element of 256 bits.
-    // Then the parameters have minimum size equal to 256*u bits.
-    // l determines how many pairings we need to compute, then in order to improve
-    // verifier`s performance we want to minize it.
-    // Namely, we have 2*l pairings for the prover and 3*l for the verifier.
+    // Pubk is the public key of the signatures.
+    Pubk  *bn256.G1
+    privk *big.Int
 }
 
 /*
-ParamsUL contains elements generated by the verifier, which are necessary for the prover.
-This must be computed in a trusted setup.
+ProverParams contains the public elements generated by the verifier, which are
+necessary for the prover. This must be computed in a trusted setup.
 */
-type ParamsUL struct {
-    signatures map[string]*bn256.G2
+type ProverParams struct {
+    // Signatures contains the signature of each digit in [0, U).
+    Signatures map[int64]*bn256.G2
     H          *bn256.G2
-    kp         bbsignatures.Keypair
-    // u determines the amount of signatures we need in the public params.
-    // Each signature can be compressed to just 1 field element of 256 bits.
-    // Then the parameters have minimum size equal to 256*u bits.
-    // l determines how many pairings we need to compute, then in order to improve
+    // Pubk is the public key of the signatures, which is bound by the challenge.
+    Pubk *bn256.G1
+    // U determines the amount of signatures we need in the public params.
+    // Each signature is compressed to its x coordinate in GF(p²) when encoded,
+    // then the parameters have size close to 512*U bits.
+    // L determines how many pairings we need to compute, then in order to improve
     // verifier`s performance we want to minize it.
-    // Namely, we have 2*l pairings for the prover and 3*l for the verifier.
-    u, l int64
+    // Namely, we have 2*L pairings for the prover and 3*L for the verifier.
+    U, L int64
+}
+
+/*
+VerifierKey contains the elements kept by the verifier. The private key of the
+signatures is only used by SetupUL, and must not be given to the provers.
+VerifyUL only uses the public key.
+*/
+type VerifierKey struct {
+    Keypair bbsignatures.Keypair
+    H       *bn256.G2
+    U, L    int64
 }
 
 /*
-proofSet contains the necessary elements for the ZK Set Membership proof.
+SetProof contains the necessary elements for the ZK Set Membership proof. The
+nonces s, t and m of the prover and the challenge c are not exported, nor encoded:
+the verifier recomputes c, and the nonces would reveal the secret.
 */
-type proofSet struct {
-    V              *bn256.G2
-    D, C           *bn256.G2
-    a              *bn256.GT
-    s, t, zsig, zv *big.Int
-    c, m, zr       *big.Int
+type SetProof struct {
+    V        *bn256.G2
+    D, C     *bn256.G2
+    A        *bn256.GT
+    Zsig, Zv *big.Int
+    Zr       *big.Int
+    s, t     *big.Int
+    c, m     *big.Int
 }
 
 /*
-ProofUL contains the necessary elements for the ZK proof.
+ProofUL contains the necessary elements for the ZK proof. As in SetProof, only the
+elements checked by the verifier are exported.
 */
 type ProofUL struct {
-    V              []*bn256.G2
-    D, C           *bn256.G2
-    a              []*bn256.GT
-    s, t, zsig, zv []*big.Int
-    c, m, zr       *big.Int
+    V        []*bn256.G2
+    D, C     *bn256.G2
+    A        []*bn256.GT
+    Zsig, Zv []*big.Int
+    Zr       *big.Int
+    s, t     []*big.Int
+    c, m     *big.Int
+}
+
+/*
+challengeSet computes the challenge of the Set Membership proof. The transcript
+binds the public parameters, the commitment C and the first message of the prover,
+which is formed by the blinded signature V and (a, D).
+*/
+func challengeSet(p *SetParams, proof_out *SetProof) *big.Int {
+    t := transcript.New("zkrp ccs08 set membership proof")
+    t.AppendMessage("H", p.H.Marshal())
+    t.AppendMessage("y", p.Pubk.Marshal())
+    t.AppendMessage("C", proof_out.C.Marshal())
+    t.AppendMessage("V", proof_out.V.Marshal())
+    t.AppendMessage("a", proof_out.A.Marshal())
+    t.AppendMessage("D", proof_out.D.Marshal())
+    return t.ChallengeScalar("c", bn256.Order)
+}
+
+/*
+challengeUL computes the challenge of the range proof. The transcript binds the
+public parameters, including the range [0, u^l), the commitment C and the first
+message of the prover, which is formed by the blinded signatures V[i] and (a, D).
+*/
+func challengeUL(H *bn256.G2, pubk *bn256.G1, u, l int64, proof_out *ProofUL) *big.Int {
+    t := transcript.New("zkrp ccs08 range proof")
+    t.AppendMessage("H", H.Marshal())
+    t.AppendMessage("y", pubk.Marshal())
+    t.AppendInt64("u", u)
+    t.AppendInt64("l", l)
+    t.AppendMessage("C", proof_out.C.Marshal())
+    for i := range proof_out.V {
+        t.AppendMessage("V", proof_out.V[i].Marshal())
+    }
+    for i := range proof_out.A {
+        t.AppendMessage("a", proof_out.A[i].Marshal())
+    }
+    t.AppendMessage("D", proof_out.D.Marshal())
+    return t.ChallengeScalar("c", bn256.Order)
 }
 
 /*
 SetupSet generates the signature for the elements in the set.
 */
-func SetupSet(s []int64) (paramsSet, error) {
+func SetupSet(s []int64) (SetParams, error) {
     var (
-        i int
-        p paramsSet
+        i   int
+        p   SetParams
+        err error
     )
-    p.kp, _ = bbsignatures.Keygen()
+    kp, _ := bbsignatures.Keygen()
+    p.Pubk = kp.Pubk
+    p.privk = kp.Privk
 
-    p.signatures = make(map[int64]*bn256.G2)
+    p.Signatures = make(map[int64]*bn256.G2)
     for i = 0; i < len(s); i++ {
-        sig_i, _ := bbsignatures.Sign(new(big.Int).SetInt64(int64(s[i])), p.kp.Privk)
-        p.signatures[s[i]] = sig_i
+        sig_i, _ := bbsignatures.Sign(new(big.Int).SetInt64(int64(s[i])), p.privk)
+        p.Signatures[s[i]] = sig_i
+    }
+    p.H, err = generatorH()
+    if err != nil {
+        return p, err
     }
-    // Issue #12: p.H must be computed using MapToPoint method.
-    h := intconversion.BigFromBase10("18560948149108576432482904553159745978835170526553990798435819795989606410925")
-    p.H = new(bn256.G2).ScalarBaseMult(h)
     return p, nil
 }
 
 /*
-SetupUL generates the signature for the interval [0,u^l).
+Public returns the parameters without the private key of the signatures, which
+can be given to the provers.
+*/
+func (p *SetParams) Public() *SetParams {
+    return &SetParams{Signatures: p.Signatures, H: p.H, Pubk: p.Pubk}
+}
+
+/*
+SetupUL generates the signature for the interval [0,u^l). The ProverParams can be
+given to the provers, while the VerifierKey is kept by the verifier.
 The value of u should be roughly b/log(b), but we can choose smaller values in
 order to get smaller parameters, at the cost of having worse performance.
 */
-func SetupUL(u, l int64) (ParamsUL, error) {
+func SetupUL(u, l int64) (ProverParams, VerifierKey, error) {
     var (
-        i int64
-        p ParamsUL
+        i   int64
+        pp  ProverParams
+        vk  VerifierKey
+        err error
     )
-    p.kp, _ = bbsignatures.Keygen()
+    vk.Keypair, _ = bbsignatures.Keygen()
 
-    p.signatures = make(map[string]*bn256.G2)
+    pp.Signatures = make(map[int64]*bn256.G2)
     for i = 0; i < u; i++ {
-        sig_i, _ := bbsignatures.Sign(new(big.Int).SetInt64(i), p.kp.Privk)
-        p.signatures[strconv.FormatInt(i, 10)] = sig_i
-    }
-    // Issue #12: p.H must be computed using MapToPoint method.
-    h := intconversion.BigFromBase10("18560948149108576432482904553159745978835170526553990798435819795989606410925")
-    p.H = new(bn256.G2).ScalarBaseMult(h)
-    p.u = u
-    p.l = l
-    return p, nil
+        sig_i, _ := bbsignatures.Sign(new(big.Int).SetInt64(i), vk.Keypair.Privk)
+        pp.Signatures[i] = sig_i
+    }
+    vk.H, err = generatorH()
+    if err != nil {
+        return pp, vk, err
+    }
+    pp.H = vk.H
+    pp.Pubk = vk.Keypair.Pubk
+    pp.U, vk.U = u, u
+    pp.L, vk.L = l, l
+    return pp, vk, nil
 }
 
 /*
-ProveSet method is used to produce the ZK Set Membership proof.
+ProveSet method is used to produce the ZK Set Membership proof. The blinding values
+are drawn from crypto/rand.
 */
-func ProveSet(x int64, r *big.Int, p paramsSet) (proofSet, error) {
+func ProveSet(x int64, r *big.Int, p SetParams) (SetProof, error) {
+    return ProveSetWithReader(rand.Reader, x, r, p)
+}
+
+/*
+ProveSetWithReader produces the ZK Set Membership proof drawing the blinding values
+from rnd.
+*/
+func ProveSetWithReader(rnd io.Reader, x int64, r *big.Int, p SetParams) (SetProof, error) {
     var (
         v         *big.Int
-        proof_out proofSet
+        proof_out SetProof
+        err       error
     )
 
     // Initialize variables
     proof_out.D = new(bn256.G2)
     proof_out.D.SetInfinity()
-    proof_out.m, _ = rand.Int(rand.Reader, bn256.Order)
+    if proof_out.m, err = rand.Int(rnd, bn256.Order); err != nil {
+        return proof_out, err
+    }
 
-    v, _ = rand.Int(rand.Reader, bn256.Order)
-    A, ok := p.signatures[x]
+    if v, err = rand.Int(rnd, bn256.Order); err != nil {
+        return proof_out, err
+    }
+    A, ok := p.Signatures[x]
 
     if !ok {
         return proof_out, errors.New("Could not generate proof. Element does not belong to the interval.")
@@ -163,72 +353,100 @@ func ProveSet(x int64, r *big.Int, p paramsSet) (proofSet, error) {
 
     // D = g^s.H^m
     D := new(bn256.G2).ScalarMult(p.H, proof_out.m)
-    proof_out.s, _ = rand.Int(rand.Reader, bn256.Order)
+    if proof_out.s, err = rand.Int(rnd, bn256.Order); err != nil {
+        return proof_out, err
+    }
     aux := new(bn256.G2).ScalarBaseMult(proof_out.s)
     D.Add(D, aux)
 
     proof_out.V = new(bn256.G2).ScalarMult(A, v)
-    proof_out.t, _ = rand.Int(rand.Reader, bn256.Order)
-    proof_out.a = bn256.Pair(G1, proof_out.V)
-    proof_out.a.ScalarMult(proof_out.a, proof_out.s)
-    proof_out.a.Invert(proof_out.a)
-    proof_out.a.Add(proof_out.a, new(bn256.GT).ScalarMult(E, proof_out.t))
+    if proof_out.t, err = rand.Int(rnd, bn256.Order); err != nil {
+        return proof_out, err
+    }
+    proof_out.A = bn256.Pair(G1, proof_out.V)
+    proof_out.A.ScalarMult(proof_out.A, proof_out.s)
+    proof_out.A.Invert(proof_out.A)
+    proof_out.A.Add(proof_out.A, new(bn256.GT).ScalarMult(E, proof_out.t))
     proof_out.D.Add(proof_out.D, D)
 
     // Consider passing C as input,
     // so that it is possible to delegate the commitment computation to an external party.
     proof_out.C, _ = Commit(new(big.Int).SetInt64(x), r, p.H)
     // Fiat-Shamir heuristic
-    proof_out.c, _ = HashSet(proof_out.a, proof_out.D)
-    proof_out.c = bn.Mod(proof_out.c, bn256.Order)
-
-    proof_out.zr = bn.Sub(proof_out.m, bn.Multiply(r, proof_out.c))
-    proof_out.zr = bn.Mod(proof_out.zr, bn256.Order)
-    proof_out.zsig = bn.Sub(proof_out.s, bn.Multiply(new(big.Int).SetInt64(x), proof_out.c))
-    proof_out.zsig = bn.Mod(proof_out.zsig, bn256.Order)
-    proof_out.zv = bn.Sub(proof_out.t, bn.Multiply(v, proof_out.c))
-    proof_out.zv = bn.Mod(proof_out.zv, bn256.Order)
+    proof_out.c = challengeSet(&p, &proof_out)
+
+    proof_out.Zr = bn.Sub(proof_out.m, bn.Multiply(r, proof_out.c))
+    proof_out.Zr = bn.Mod(proof_out.Zr, bn256.Order)
+    proof_out.Zsig = bn.Sub(proof_out.s, bn.Multiply(new(big.Int).SetInt64(x), proof_out.c))
+    proof_out.Zsig = bn.Mod(proof_out.Zsig, bn256.Order)
+    proof_out.Zv = bn.Sub(proof_out.t, bn.Multiply(v, proof_out.c))
+    proof_out.Zv = bn.Mod(proof_out.Zv, bn256.Order)
     return proof_out, nil
 }
 
 /*
 ProveUL method is used to produce the ZKRP proof that secret x belongs to the interval [0,U^L].
+The blinding values are drawn from crypto/rand.
 */
-func ProveUL(x, r *big.Int, p ParamsUL) (ProofUL, error) {
+func ProveUL(x, r *big.Int, p ProverParams) (ProofUL, error) {
+    return ProveULWithReader(rand.Reader, x, r, p)
+}
+
+/*
+ProveULWithReader produces the ZKRP proof that secret x belongs to the interval [0,U^L],
+drawing the blinding values from rnd.
+*/
+func ProveULWithReader(rnd io.Reader, x, r *big.Int, p ProverParams) (ProofUL, error) {
     var (
         i         int64
         v         []*big.Int
         proof_out ProofUL
+        err       error
     )
-    decx, _ := Decompose(x, p.u, p.l)
+    ul := new(big.Int).Exp(big.NewInt(p.U), big.NewInt(p.L), nil)
+    if x.Sign() < 0 || x.Cmp(ul) >= 0 {
+        return proof_out, errors.New("secret does not belong to the interval [0, u^l)")
+    }
+    decx, err := Decompose(x, p.U, p.L)
+    if err != nil {
+        return proof_out, err
+    }
 
     // Initialize variables
-    v = make([]*big.Int, p.l)
-    proof_out.V = make([]*bn256.G2, p.l)
-    proof_out.a = make([]*bn256.GT, p.l)
-    proof_out.s = make([]*big.Int, p.l)
-    proof_out.t = make([]*big.Int, p.l)
-    proof_out.zsig = make([]*big.Int, p.l)
-    proof_out.zv = make([]*big.Int, p.l)
+    v = make([]*big.Int, p.L)
+    proof_out.V = make([]*bn256.G2, p.L)
+    proof_out.A = make([]*bn256.GT, p.L)
+    proof_out.s = make([]*big.Int, p.L)
+    proof_out.t = make([]*big.Int, p.L)
+    proof_out.Zsig = make([]*big.Int, p.L)
+    proof_out.Zv = make([]*big.Int, p.L)
     proof_out.D = new(bn256.G2)
     proof_out.D.SetInfinity()
-    proof_out.m, _ = rand.Int(rand.Reader, bn256.Order)
+    if proof_out.m, err = rand.Int(rnd, bn256.Order); err != nil {
+        return proof_out, err
+    }
 
     // D = H^m
     D := new(bn256.G2).ScalarMult(p.H, proof_out.m)
-    for i = 0; i < p.l; i++ {
-        v[i], _ = rand.Int(rand.Reader, bn256.Order)
-        A, ok := p.signatures[strconv.FormatInt(decx[i], 10)]
+    for i = 0; i < p.L; i++ {
+        if v[i], err = rand.Int(rnd, bn256.Order); err != nil {
+            return proof_out, err
+        }
+        A, ok := p.Signatures[decx[i]]
         if ok {
             proof_out.V[i] = new(bn256.G2).ScalarMult(A, v[i])
-            proof_out.s[i], _ = rand.Int(rand.Reader, bn256.Order)
-            proof_out.t[i], _ = rand.Int(rand.Reader, bn256.Order)
-            proof_out.a[i] = bn256.Pair(G1, proof_out.V[i])
-            proof_out.a[i].ScalarMult(proof_out.a[i], proof_out.s[i])
-            proof_out.a[i].Invert(proof_out.a[i])
-            proof_out.a[i].Add(proof_out.a[i], new(bn256.GT).ScalarMult(E, proof_out.t[i]))
-
-            ui := new(big.Int).Exp(new(big.Int).SetInt64(p.u), new(big.Int).SetInt64(i), nil)
+            if proof_out.s[i], err = rand.Int(rnd, bn256.Order); err != nil {
+                return proof_out, err
+            }
+            if proof_out.t[i], err = rand.Int(rnd, bn256.Order); err != nil {
+                return proof_out, err
+            }
+            proof_out.A[i] = bn256.Pair(G1, proof_out.V[i])
+            proof_out.A[i].ScalarMult(proof_out.A[i], proof_out.s[i])
+            proof_out.A[i].Invert(proof_out.A[i])
+            proof_out.A[i].Add(proof_out.A[i], new(bn256.GT).ScalarMult(E, proof_out.t[i]))
+
+            ui := new(big.Int).Exp(new(big.Int).SetInt64(p.U), new(big.Int).SetInt64(i), nil)
             muisi := new(big.Int).Mul(proof_out.s[i], ui)
             muisi = bn.Mod(muisi, bn256.Order)
             aux := new(bn256.G2).ScalarBaseMult(muisi)
@@ -243,33 +461,44 @@ func ProveUL(x, r *big.Int, p ParamsUL) (ProofUL, error) {
     // so that it is possible to delegate the commitment computation to an external party.
     proof_out.C, _ = Commit(x, r, p.H)
     // Fiat-Shamir heuristic
-    proof_out.c, _ = Hash(proof_out.a, proof_out.D)
-    proof_out.c = bn.Mod(proof_out.c, bn256.Order)
-
-    proof_out.zr = bn.Sub(proof_out.m, bn.Multiply(r, proof_out.c))
-    proof_out.zr = bn.Mod(proof_out.zr, bn256.Order)
-    for i = 0; i < p.l; i++ {
-        proof_out.zsig[i] = bn.Sub(proof_out.s[i], bn.Multiply(new(big.Int).SetInt64(decx[i]), proof_out.c))
-        proof_out.zsig[i] = bn.Mod(proof_out.zsig[i], bn256.Order)
-        proof_out.zv[i] = bn.Sub(proof_out.t[i], bn.Multiply(v[i], proof_out.c))
-        proof_out.zv[i] = bn.Mod(proof_out.zv[i], bn256.Order)
+    proof_out.c = challengeUL(p.H, p.Pubk, p.U, p.L, &proof_out)
+
+    proof_out.Zr = bn.Sub(proof_out.m, bn.Multiply(r, proof_out.c))
+    proof_out.Zr = bn.Mod(proof_out.Zr, bn256.Order)
+    for i = 0; i < p.L; i++ {
+        proof_out.Zsig[i] = bn.Sub(proof_out.s[i], bn.Multiply(new(big.Int).SetInt64(decx[i]), proof_out.c))
+        proof_out.Zsig[i] = bn.Mod(proof_out.Zsig[i], bn256.Order)
+        proof_out.Zv[i] = bn.Sub(proof_out.t[i], bn.Multiply(v[i], proof_out.c))
+        proof_out.Zv[i] = bn.Mod(proof_out.Zv[i], bn256.Order)
     }
     return proof_out, nil
 }
 
 /*
 VerifySet is used to validate the ZK Set Membership proof. It returns true iff the proof is valid.
+The parameters are checked with VerifyParams first, and ErrInvalidProof is returned if
+V, C or D is the point at infinity.
 */
-func VerifySet(proof_out *proofSet, p *paramsSet) (bool, error) {
+func VerifySet(proof_out *SetProof, p *SetParams) (bool, error) {
     var (
         D      *bn256.G2
         r1, r2 bool
-        p1, p2 *bn256.GT
     )
+    if err := VerifyParams(p.H); err != nil {
+        return false, err
+    }
+    if err := checkPoints(proof_out.V, proof_out.C, proof_out.D); err != nil {
+        return false, err
+    }
+    if proof_out.A == nil || proof_out.Zsig == nil || proof_out.Zv == nil || proof_out.Zr == nil {
+        return false, ErrInvalidProof
+    }
+    // Fiat-Shamir heuristic
+    c := challengeSet(p, proof_out)
     // D == C^c.h^ zr.g^zsig ?
-    D = new(bn256.G2).ScalarMult(proof_out.C, proof_out.c)
-    D.Add(D, new(bn256.G2).ScalarMult(p.H, proof_out.zr))
-    aux := new(bn256.G2).ScalarBaseMult(proof_out.zsig)
+    D = new(bn256.G2).ScalarMult(proof_out.C, c)
+    D.Add(D, new(bn256.G2).ScalarMult(p.H, proof_out.Zr))
+    aux := new(bn256.G2).ScalarBaseMult(proof_out.Zsig)
     D.Add(D, aux)
 
     DBytes := D.Marshal()
@@ -278,36 +507,62 @@ func VerifySet(proof_out *proofSet, p *paramsSet) (bool, error) {
 
     r2 = true
     // a == [e(V,y)^c].[e(V,g)^-zsig].[e(g,g)^zv]
-    p1 = bn256.Pair(p.kp.Pubk, proof_out.V)
-    p1.ScalarMult(p1, proof_out.c)
-    p2 = bn256.Pair(G1, proof_out.V)
-    p2.ScalarMult(p2, proof_out.zsig)
-    p2.Invert(p2)
-    p1.Add(p1, p2)
-    p1.Add(p1, new(bn256.GT).ScalarMult(E, proof_out.zv))
+    p1 := pairingEquation(p.Pubk, proof_out.V, c, proof_out.Zsig, proof_out.Zv)
 
     pBytes := p1.Marshal()
-    aBytes := proof_out.a.Marshal()
+    aBytes := proof_out.A.Marshal()
     r2 = r2 && bytes.Equal(pBytes, aBytes)
     return r1 && r2, nil
 }
 
+/*
+pairingEquation computes [e(V,y)^c].[e(V,g)^-zsig].[e(g,g)^zv] as
+e(y^c.g^-zsig, V).e(g^zv, g), which needs a single final exponentiation.
+*/
+func pairingEquation(pubk *bn256.G1, V *bn256.G2, c, zsig, zv *big.Int) *bn256.GT {
+    a := new(bn256.G1).ScalarMult(pubk, c)
+    a.Add(a, new(bn256.G1).ScalarBaseMult(new(big.Int).Neg(zsig)))
+    b := new(bn256.G1).ScalarBaseMult(zv)
+    return bn256.PairBatch([]*bn256.G1{a, b}, []*bn256.G2{V, G2})
+}
+
 /*
 VerifyUL is used to validate the ZKRP proof. It returns true iff the proof is valid.
+The parameters are checked with VerifyParams first, and ErrInvalidProof is returned if
+C, D or any V[i] is the point at infinity.
 */
-func VerifyUL(proof_out *ProofUL, p *ParamsUL) (bool, error) {
+func VerifyUL(proof_out *ProofUL, vk *VerifierKey) (bool, error) {
     var (
         i      int64
         D      *bn256.G2
         r1, r2 bool
-        p1, p2 *bn256.GT
     )
+    if err := VerifyParams(vk.H); err != nil {
+        return false, err
+    }
+    if int64(len(proof_out.V)) != vk.L || int64(len(proof_out.A)) != vk.L ||
+        int64(len(proof_out.Zsig)) != vk.L || int64(len(proof_out.Zv)) != vk.L {
+        return false, errors.New("proof does not match the parameters")
+    }
+    if err := checkPoints(append([]*bn256.G2{proof_out.C, proof_out.D}, proof_out.V...)...); err != nil {
+        return false, err
+    }
+    if proof_out.Zr == nil {
+        return false, ErrInvalidProof
+    }
+    for i = 0; i < vk.L; i++ {
+        if proof_out.A[i] == nil || proof_out.Zsig[i] == nil || proof_out.Zv[i] == nil {
+            return false, ErrInvalidProof
+        }
+    }
+    // Fiat-Shamir heuristic
+    c := challengeUL(vk.H, vk.Keypair.Pubk, vk.U, vk.L, proof_out)
     // D == C^c.h^ zr.g^zsig ?
-    D = new(bn256.G2).ScalarMult(proof_out.C, proof_out.c)
-    D.Add(D, new(bn256.G2).ScalarMult(p.H, proof_out.zr))
-    for i = 0; i < p.l; i++ {
-        ui := new(big.Int).Exp(new(big.Int).SetInt64(p.u), new(big.Int).SetInt64(i), nil)
-        muizsigi := new(big.Int).Mul(proof_out.zsig[i], ui)
+    D = new(bn256.G2).ScalarMult(proof_out.C, c)
+    D.Add(D, new(bn256.G2).ScalarMult(vk.H, proof_out.Zr))
+    for i = 0; i < vk.L; i++ {
+        ui := new(big.Int).Exp(new(big.Int).SetInt64(vk.U), new(big.Int).SetInt64(i), nil)
+        muizsigi := new(big.Int).Mul(proof_out.Zsig[i], ui)
         muizsigi = bn.Mod(muizsigi, bn256.Order)
         aux := new(bn256.G2).ScalarBaseMult(muizsigi)
         D.Add(D, aux)
@@ -318,110 +573,13 @@ func VerifyUL(proof_out *ProofUL, p *ParamsUL) (bool, error) {
     r1 = bytes.Equal(DBytes, pDBytes)
 
     r2 = true
-    for i = 0; i < p.l; i++ {
+    for i = 0; i < vk.L; i++ {
         // a == [e(V,y)^c].[e(V,g)^-zsig].[e(g,g)^zv]
-        p1 = bn256.Pair(p.kp.Pubk, proof_out.V[i])
-        p1.ScalarMult(p1, proof_out.c)
-        p2 = bn256.Pair(G1, proof_out.V[i])
-        p2.ScalarMult(p2, proof_out.zsig[i])
-        p2.Invert(p2)
-        p1.Add(p1, p2)
-        p1.Add(p1, new(bn256.GT).ScalarMult(E, proof_out.zv[i]))
+        p1 := pairingEquation(vk.Keypair.Pubk, proof_out.V[i], c, proof_out.Zsig[i], proof_out.Zv[i])
 
         pBytes := p1.Marshal()
-        aBytes := proof_out.a[i].Marshal()
+        aBytes := proof_out.A[i].Marshal()
         r2 = r2 && bytes.Equal(pBytes, aBytes)
     }
     return r1 && r2, nil
 }
-
-/*
-proof contains the necessary elements for the ZK proof.
-*/
-type proof struct {
-    p1, p2 ProofUL
-}
-
-/*
-params contains elements generated by the verifier, which are necessary for the prover.
-This must be computed in a trusted setup.
-*/
-type params struct {
-    p    *ParamsUL
-    a, b int64
-}
-
-type ccs08 struct {
-    p         *params
-    x, r      *big.Int
-    proof_out proof
-}
-
-/*
-SetupInnerProduct receives integers a and b, and configures the parameters for the rangeproof scheme.
-*/
-func (zkrp *ccs08) Setup(a, b int64) error {
-    // Compute optimal values for u and l
-    var (
-        u, l int64
-        logb float64
-        p    *params
-    )
-    if a > b {
-        zkrp.p = nil
-        return errors.New("a must be less than or equal to b")
-    }
-    p = new(params)
-    logb = math.Log(float64(b))
-    if logb != 0 {
-        // u = b / int64(logb)
-        u = 57
-        if u != 0 {
-            l = 0
-            for i := b; i > 0; i = i / u {
-                l = l + 1
-            }
-            params_out, e := SetupUL(u, l)
-            p.p = &params_out
-            p.a = a
-            p.b = b
-            zkrp.p = p
-            return e
-        } else {
-            zkrp.p = nil
-            return errors.New("u is zero")
-        }
-    } else {
-        zkrp.p = nil
-        return errors.New("log(b) is zero")
-    }
-}
-
-/*
-Prove method is responsible for generating the zero knowledge proof.
-*/
-func (zkrp *ccs08) Prove() error {
-    ul := new(big.Int).Exp(new(big.Int).SetInt64(zkrp.p.p.u), new(big.Int).SetInt64(zkrp.p.p.l), nil)
-
-    // x - b + ul
-    xb := new(big.Int).Sub(zkrp.x, new(big.Int).SetInt64(zkrp.p.b))
-    xb.Add(xb, ul)
-    first, _ := ProveUL(xb, zkrp.r, *zkrp.p.p)
-
-    // x - a
-    xa := new(big.Int).Sub(zkrp.x, new(big.Int).SetInt64(zkrp.p.a))
-    second, _ := ProveUL(xa, zkrp.r, *zkrp.p.p)
-
-    zkrp.proof_out.p1 = first
-    zkrp.proof_out.p2 = second
-    return nil
-}
-
-/*
-Verify is responsible for validating the proof.
-*/
-func (zkrp *ccs08) Verify() (bool, error) {
-    first, _ := VerifyUL(&zkrp.proof_out.p1, zkrp.p.p)
-    second, _ := VerifyUL(&zkrp.proof_out.p2, zkrp.p.p)
-    return first && second, nil
-}
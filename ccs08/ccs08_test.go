@@ -98,26 +98,53 @@ func TestZKRP_UL(t *testing.T) {
     var (
         r *big.Int
     )
-    p, _ := SetupUL(10, 5)
+    p, vk, _ := SetupUL(10, 5)
     r, _ = rand.Int(rand.Reader, bn256.Order)
     proof_out, _ := ProveUL(new(big.Int).SetInt64(42176), r, p)
-    result, _ := VerifyUL(&proof_out, &p)
+    result, _ := VerifyUL(&proof_out, &vk)
     if result != true {
         t.Errorf("Assert failure: expected true, actual: %t", result)
     }
 }
 
 /*
-Tests if the SetupInnerProduct algorithm is rejecting wrong input as expected.
+Tests that the verifier rejects parameters where H is not hashed to the curve.
+*/
+func TestVerifyParams(t *testing.T) {
+    p, vk, _ := SetupUL(10, 5)
+    if err := VerifyParams(vk.H); err != nil {
+        t.Errorf("Assert failure: expected nil, actual: %v", err)
+    }
+    r, _ := rand.Int(rand.Reader, bn256.Order)
+    proof_out, _ := ProveUL(new(big.Int).SetInt64(42176), r, p)
+
+    // H = g^h, where h is known
+    h := intconversion.BigFromBase10("18560948149108576432482904553159745978835170526553990798435819795989606410925")
+    vk.H = new(bn256.G2).ScalarBaseMult(h)
+    if err := VerifyParams(vk.H); err != ErrInvalidH {
+        t.Errorf("Assert failure: expected ErrInvalidH, actual: %v", err)
+    }
+    result, err := VerifyUL(&proof_out, &vk)
+    if result || err != ErrInvalidH {
+        t.Errorf("Assert failure: expected false and ErrInvalidH, actual: %t, %v", result, err)
+    }
+}
+
+/*
+Tests if the setup of the range proofs is rejecting wrong input as expected.
 */
 func TestZKRPSetupInput(t *testing.T) {
-    var (
-        zkrp ccs08
-    )
-    e := zkrp.Setup(1900, 1899)
-    result := e.Error() != "a must be less than or equal to b"
-    if result {
-        t.Errorf("Assert failure: expected true, actual: %t", result)
+    _, e := NewRangeProver(1900, 1899)
+    if e == nil {
+        t.Errorf("Assert failure: expected error for a > b")
+    }
+    _, e = NewRangeProver(0, 100, WithU(1))
+    if e == nil {
+        t.Errorf("Assert failure: expected error for u = 1")
+    }
+    _, e = NewRangeProver(0, 100, WithU(3), WithL(2))
+    if e == nil {
+        t.Errorf("Assert failure: expected error for u^l < b - a")
     }
 }
 
@@ -147,19 +174,130 @@ func TestZKSet(t *testing.T) {
 Tests the entire ZK Range Proof (CCS08) protocol.
 */
 func TestZKRP(t *testing.T) {
-    var (
-        result bool
-        zkrp   ccs08
-    )
-    zkrp.Setup(347184000, 599644800)
-    zkrp.x = new(big.Int).SetInt64(419835123)
-    zkrp.r, _ = rand.Int(rand.Reader, bn256.Order)
-    e := zkrp.Prove()
+    zkrp, e := NewRangeProver(347184000, 599644800)
+    if e != nil {
+        t.Fatalf("Error during setup: %s", e.Error())
+    }
+    r, _ := rand.Int(rand.Reader, bn256.Order)
+    proof_out, e := zkrp.Public().Prove(new(big.Int).SetInt64(419835123), r)
     if e != nil {
         t.Errorf("Error while proving ZKRP: %s", e.Error())
     }
-    result, _ = zkrp.Verify()
+    result, _ := zkrp.Verify(&proof_out)
     if result != true {
         t.Errorf("Assert failure: expected true, actual: %t", result)
     }
 }
+
+/*
+Tests the range proofs for intervals with a negative start, using the options.
+*/
+func TestZKRPNegativeStart(t *testing.T) {
+    zkrp, e := NewRangeProver(-1000, 24, WithOptimalU())
+    if e != nil {
+        t.Fatalf("Error during setup: %s", e.Error())
+    }
+    if zkrp.Params.U != 147 || zkrp.Params.L != 2 {
+        t.Errorf("Assert failure: expected u = 147 and l = 2, actual: %d, %d", zkrp.Params.U, zkrp.Params.L)
+    }
+    r, _ := rand.Int(rand.Reader, bn256.Order)
+    for _, x := range []int64{-1000, -1, 0, 23} {
+        proof_out, e := zkrp.Prove(new(big.Int).SetInt64(x), r)
+        if e != nil {
+            t.Fatalf("Error while proving ZKRP: %s", e.Error())
+        }
+        result, _ := zkrp.Verify(&proof_out)
+        if result != true {
+            t.Errorf("Assert failure: expected true for %d, actual: %t", x, result)
+        }
+    }
+    for _, x := range []int64{-1001, 24} {
+        if _, e = zkrp.Prove(new(big.Int).SetInt64(x), r); e == nil {
+            t.Errorf("Assert failure: expected error for %d", x)
+        }
+    }
+
+    // the proof is bound to its commitment
+    proof_out, _ := zkrp.Prove(new(big.Int).SetInt64(5), r)
+    proof_out.C, _ = zkrp.Commit(new(big.Int).SetInt64(6), r)
+    result, _ := zkrp.Verify(&proof_out)
+    if result != false {
+        t.Errorf("Assert failure: expected false, actual: %t", result)
+    }
+}
+
+/*
+Tests that WithL chooses the smallest u such that u^l >= b - a.
+*/
+func TestZKRPWithL(t *testing.T) {
+    zkrp, e := NewRangeProver(0, 1000, WithL(3))
+    if e != nil {
+        t.Fatalf("Error during setup: %s", e.Error())
+    }
+    if zkrp.Params.U != 10 || zkrp.Params.L != 3 {
+        t.Errorf("Assert failure: expected u = 10 and l = 3, actual: %d, %d", zkrp.Params.U, zkrp.Params.L)
+    }
+}
+
+/*
+Tests that the verifiers reject the point at infinity in the proofs. With V equal to
+the point at infinity, the pairing equation does not depend on the signature, so that
+a proof for an element that does not belong to the set can be forged.
+*/
+func TestVerifyInfinity(t *testing.T) {
+    p, _ := SetupSet([]int64{12, 42, 61, 71})
+    x := new(big.Int).SetInt64(13)
+    r, _ := rand.Int(rand.Reader, bn256.Order)
+    m, _ := rand.Int(rand.Reader, bn256.Order)
+    s, _ := rand.Int(rand.Reader, bn256.Order)
+    var forged SetProof
+    forged.C, _ = Commit(x, r, p.H)
+    forged.D = new(bn256.G2).ScalarMult(p.H, m)
+    forged.D.Add(forged.D, new(bn256.G2).ScalarBaseMult(s))
+    forged.V = new(bn256.G2).SetInfinity()
+    forged.Zv, _ = rand.Int(rand.Reader, bn256.Order)
+    forged.A = new(bn256.GT).ScalarMult(E, forged.Zv)
+    c := challengeSet(&p, &forged)
+    forged.Zr = bn.Mod(bn.Sub(m, bn.Multiply(r, c)), bn256.Order)
+    forged.Zsig = bn.Mod(bn.Sub(s, bn.Multiply(x, c)), bn256.Order)
+    result, err := VerifySet(&forged, &p)
+    if result || err != ErrInvalidProof {
+        t.Errorf("Assert failure: expected false and ErrInvalidProof, actual: %t, %v", result, err)
+    }
+
+    pp, vk, _ := SetupUL(10, 3)
+    proof_out, _ := ProveUL(new(big.Int).SetInt64(421), r, pp)
+    proof_out.V[1] = new(bn256.G2).SetInfinity()
+    result, err = VerifyUL(&proof_out, &vk)
+    if result || err != ErrInvalidProof {
+        t.Errorf("Assert failure: expected false and ErrInvalidProof, actual: %t, %v", result, err)
+    }
+    proof_out, _ = ProveUL(new(big.Int).SetInt64(421), r, pp)
+    proof_out.C = new(bn256.G2).SetInfinity()
+    result, err = VerifyUL(&proof_out, &vk)
+    if result || err != ErrInvalidProof {
+        t.Errorf("Assert failure: expected false and ErrInvalidProof, actual: %t, %v", result, err)
+    }
+}
+
+/*
+Tests that the parameters given to the provers by the zkrp scheme do not contain the
+private key of the signatures.
+*/
+func TestSchemeSetupPrivateKey(t *testing.T) {
+    params, key, err := scheme{}.Setup(0, 100)
+    if err != nil {
+        t.Fatalf("Error during setup: %s", err.Error())
+    }
+    if params.(proverParams).rp.Key.Keypair.Privk != nil {
+        t.Errorf("Assert failure: the prover parameters contain the private key")
+    }
+    r, _ := rand.Int(rand.Reader, bn256.Order)
+    proof, err := params.Prove(new(big.Int).SetInt64(42), r)
+    if err != nil {
+        t.Fatalf("Error while proving: %s", err.Error())
+    }
+    if ok, _ := key.Verify(proof); !ok {
+        t.Errorf("Assert failure: expected true, actual: %t", ok)
+    }
+}
@@ -0,0 +1,851 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ccs08
+
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "io"
+    "math/big"
+    "sort"
+
+    "github.com/ing-bank/zkrp/crypto/bbsignatures"
+    "github.com/ing-bank/zkrp/crypto/bn256"
+)
+
+/*
+The binary encodings use bn256.G1.MarshalCompressed (33 bytes),
+bn256.G2.MarshalCompressed (65 bytes) and bn256.GT.Marshal (384 bytes) for the
+group elements, 32 bytes in big-endian order for the scalars and 4 bytes in
+big-endian order for the lengths. The JSON encodings contain the same group
+elements in base64 and the scalars as numbers. Only public values are encoded, so
+decoding never restores the private key of the signatures, nor the nonces of the
+prover.
+*/
+
+const (
+    // ScalarSize is the length in bytes of an encoded scalar.
+    ScalarSize = 32
+    g1Size     = bn256.G1CompressedSize
+    g2Size     = bn256.G2CompressedSize
+    gtSize     = 384
+    // maxLength bounds the lengths read from encoded data, before allocating.
+    maxLength = 1 << 20
+)
+
+var errTrailingBytes = errors.New("unexpected trailing bytes")
+
+func checkScalar(k *big.Int) error {
+    if k == nil || k.Sign() < 0 || k.Cmp(bn256.Order) >= 0 {
+        return errors.New("scalar is not reduced modulo the group order")
+    }
+    return nil
+}
+
+func writeScalars(buf *bytes.Buffer, scalars ...*big.Int) error {
+    for _, k := range scalars {
+        if err := checkScalar(k); err != nil {
+            return err
+        }
+        data := make([]byte, ScalarSize)
+        kBytes := k.Bytes()
+        copy(data[ScalarSize-len(kBytes):], kBytes)
+        buf.Write(data)
+    }
+    return nil
+}
+
+func writeG2(buf *bytes.Buffer, points ...*bn256.G2) error {
+    for _, p := range points {
+        if p == nil {
+            return errors.New("can not encode nil point")
+        }
+        buf.Write(p.MarshalCompressed())
+    }
+    return nil
+}
+
+func writeGT(buf *bytes.Buffer, elements ...*bn256.GT) error {
+    for _, e := range elements {
+        if e == nil {
+            return errors.New("can not encode nil element of GT")
+        }
+        buf.Write(e.Marshal())
+    }
+    return nil
+}
+
+func writeLength(buf *bytes.Buffer, n int) {
+    var data [4]byte
+    binary.BigEndian.PutUint32(data[:], uint32(n))
+    buf.Write(data[:])
+}
+
+func readBytes(r *bytes.Reader, n int) ([]byte, error) {
+    data := make([]byte, n)
+    if _, err := io.ReadFull(r, data); err != nil {
+        return nil, err
+    }
+    return data, nil
+}
+
+func readLength(r *bytes.Reader) (int, error) {
+    data, err := readBytes(r, 4)
+    if err != nil {
+        return 0, err
+    }
+    n := binary.BigEndian.Uint32(data)
+    if n > maxLength {
+        return 0, errors.New("invalid length")
+    }
+    return int(n), nil
+}
+
+func decodeScalar(data []byte) (*big.Int, error) {
+    k := new(big.Int).SetBytes(data)
+    return k, checkScalar(k)
+}
+
+/*
+decodeG1 and decodeG2 reject the point at infinity, which is never a valid public
+key, signature or element of a proof.
+*/
+func decodeG1(data []byte) (*bn256.G1, error) {
+    p, err := new(bn256.G1).UnmarshalCompressed(data)
+    if err != nil {
+        return nil, err
+    }
+    if p.IsZero() {
+        return nil, bn256.ErrInfinity
+    }
+    return p, nil
+}
+
+func decodeG2(data []byte) (*bn256.G2, error) {
+    p, err := new(bn256.G2).UnmarshalCompressed(data)
+    if err != nil {
+        return nil, err
+    }
+    if p.IsZero() {
+        return nil, bn256.ErrInfinity
+    }
+    return p, nil
+}
+
+func decodeGT(data []byte) (*bn256.GT, error) {
+    return new(bn256.GT).Unmarshal(data)
+}
+
+func readScalar(r *bytes.Reader) (*big.Int, error) {
+    data, err := readBytes(r, ScalarSize)
+    if err != nil {
+        return nil, err
+    }
+    return decodeScalar(data)
+}
+
+func readG1(r *bytes.Reader) (*bn256.G1, error) {
+    data, err := readBytes(r, g1Size)
+    if err != nil {
+        return nil, err
+    }
+    return decodeG1(data)
+}
+
+func readG2(r *bytes.Reader) (*bn256.G2, error) {
+    data, err := readBytes(r, g2Size)
+    if err != nil {
+        return nil, err
+    }
+    return decodeG2(data)
+}
+
+func readGT(r *bytes.Reader) (*bn256.GT, error) {
+    data, err := readBytes(r, gtSize)
+    if err != nil {
+        return nil, err
+    }
+    return decodeGT(data)
+}
+
+/*
+MarshalBinary encodes the proof as: V, D, C, A, Zsig, Zv and Zr.
+*/
+func (proof *SetProof) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    if err := writeG2(&buf, proof.V, proof.D, proof.C); err != nil {
+        return nil, err
+    }
+    if err := writeGT(&buf, proof.A); err != nil {
+        return nil, err
+    }
+    if err := writeScalars(&buf, proof.Zsig, proof.Zv, proof.Zr); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+/*
+UnmarshalBinary decodes a proof encoded with MarshalBinary.
+*/
+func (proof *SetProof) UnmarshalBinary(data []byte) error {
+    var (
+        p   SetProof
+        err error
+    )
+    r := bytes.NewReader(data)
+    for _, q := range []**bn256.G2{&p.V, &p.D, &p.C} {
+        if *q, err = readG2(r); err != nil {
+            return err
+        }
+    }
+    if p.A, err = readGT(r); err != nil {
+        return err
+    }
+    for _, k := range []**big.Int{&p.Zsig, &p.Zv, &p.Zr} {
+        if *k, err = readScalar(r); err != nil {
+            return err
+        }
+    }
+    if r.Len() != 0 {
+        return errTrailingBytes
+    }
+    *proof = p
+    return nil
+}
+
+type setProofJSON struct {
+    V, D, C      []byte
+    A            []byte
+    Zsig, Zv, Zr *big.Int
+}
+
+/*
+MarshalJSON encodes the exported fields of the proof.
+*/
+func (proof *SetProof) MarshalJSON() ([]byte, error) {
+    if proof.V == nil || proof.D == nil || proof.C == nil || proof.A == nil {
+        return nil, errors.New("can not encode incomplete proof")
+    }
+    return json.Marshal(setProofJSON{
+        V:    proof.V.MarshalCompressed(),
+        D:    proof.D.MarshalCompressed(),
+        C:    proof.C.MarshalCompressed(),
+        A:    proof.A.Marshal(),
+        Zsig: proof.Zsig,
+        Zv:   proof.Zv,
+        Zr:   proof.Zr,
+    })
+}
+
+/*
+UnmarshalJSON decodes a proof encoded with MarshalJSON.
+*/
+func (proof *SetProof) UnmarshalJSON(data []byte) error {
+    var (
+        encoded setProofJSON
+        p       SetProof
+        err     error
+    )
+    if err = json.Unmarshal(data, &encoded); err != nil {
+        return err
+    }
+    if p.V, err = decodeG2(encoded.V); err != nil {
+        return err
+    }
+    if p.D, err = decodeG2(encoded.D); err != nil {
+        return err
+    }
+    if p.C, err = decodeG2(encoded.C); err != nil {
+        return err
+    }
+    if p.A, err = decodeGT(encoded.A); err != nil {
+        return err
+    }
+    for _, k := range []*big.Int{encoded.Zsig, encoded.Zv, encoded.Zr} {
+        if err = checkScalar(k); err != nil {
+            return err
+        }
+    }
+    p.Zsig, p.Zv, p.Zr = encoded.Zsig, encoded.Zv, encoded.Zr
+    *proof = p
+    return nil
+}
+
+/*
+MarshalBinary encodes the proof as: l, V[0..l), D, C, A[0..l), Zsig[0..l),
+Zv[0..l) and Zr.
+*/
+func (proof *ProofUL) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    l := len(proof.V)
+    if len(proof.A) != l || len(proof.Zsig) != l || len(proof.Zv) != l {
+        return nil, errors.New("can not encode incomplete proof")
+    }
+    writeLength(&buf, l)
+    if err := writeG2(&buf, proof.V...); err != nil {
+        return nil, err
+    }
+    if err := writeG2(&buf, proof.D, proof.C); err != nil {
+        return nil, err
+    }
+    if err := writeGT(&buf, proof.A...); err != nil {
+        return nil, err
+    }
+    if err := writeScalars(&buf, proof.Zsig...); err != nil {
+        return nil, err
+    }
+    if err := writeScalars(&buf, proof.Zv...); err != nil {
+        return nil, err
+    }
+    if err := writeScalars(&buf, proof.Zr); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+/*
+UnmarshalBinary decodes a proof encoded with MarshalBinary.
+*/
+func (proof *ProofUL) UnmarshalBinary(data []byte) error {
+    var p ProofUL
+    r := bytes.NewReader(data)
+    l, err := readLength(r)
+    if err != nil {
+        return err
+    }
+    if r.Len() < l*(g2Size+gtSize+2*ScalarSize) {
+        return io.ErrUnexpectedEOF
+    }
+    p.V = make([]*bn256.G2, l)
+    p.A = make([]*bn256.GT, l)
+    p.Zsig = make([]*big.Int, l)
+    p.Zv = make([]*big.Int, l)
+    for i := range p.V {
+        if p.V[i], err = readG2(r); err != nil {
+            return err
+        }
+    }
+    if p.D, err = readG2(r); err != nil {
+        return err
+    }
+    if p.C, err = readG2(r); err != nil {
+        return err
+    }
+    for i := range p.A {
+        if p.A[i], err = readGT(r); err != nil {
+            return err
+        }
+    }
+    for i := range p.Zsig {
+        if p.Zsig[i], err = readScalar(r); err != nil {
+            return err
+        }
+    }
+    for i := range p.Zv {
+        if p.Zv[i], err = readScalar(r); err != nil {
+            return err
+        }
+    }
+    if p.Zr, err = readScalar(r); err != nil {
+        return err
+    }
+    if r.Len() != 0 {
+        return errTrailingBytes
+    }
+    *proof = p
+    return nil
+}
+
+type proofULJSON struct {
+    V        [][]byte
+    D, C     []byte
+    A        [][]byte
+    Zsig, Zv []*big.Int
+    Zr       *big.Int
+}
+
+/*
+MarshalJSON encodes the exported fields of the proof.
+*/
+func (proof *ProofUL) MarshalJSON() ([]byte, error) {
+    if proof.D == nil || proof.C == nil {
+        return nil, errors.New("can not encode incomplete proof")
+    }
+    encoded := proofULJSON{
+        V:    make([][]byte, len(proof.V)),
+        D:    proof.D.MarshalCompressed(),
+        C:    proof.C.MarshalCompressed(),
+        A:    make([][]byte, len(proof.A)),
+        Zsig: proof.Zsig,
+        Zv:   proof.Zv,
+        Zr:   proof.Zr,
+    }
+    for i, V := range proof.V {
+        if V == nil {
+            return nil, errors.New("can not encode nil point")
+        }
+        encoded.V[i] = V.MarshalCompressed()
+    }
+    for i, A := range proof.A {
+        if A == nil {
+            return nil, errors.New("can not encode nil element of GT")
+        }
+        encoded.A[i] = A.Marshal()
+    }
+    return json.Marshal(encoded)
+}
+
+/*
+UnmarshalJSON decodes a proof encoded with MarshalJSON.
+*/
+func (proof *ProofUL) UnmarshalJSON(data []byte) error {
+    var (
+        encoded proofULJSON
+        p       ProofUL
+        err     error
+    )
+    if err = json.Unmarshal(data, &encoded); err != nil {
+        return err
+    }
+    l := len(encoded.V)
+    if len(encoded.A) != l || len(encoded.Zsig) != l || len(encoded.Zv) != l {
+        return errors.New("inconsistent lengths in proof")
+    }
+    p.V = make([]*bn256.G2, l)
+    p.A = make([]*bn256.GT, l)
+    for i := 0; i < l; i++ {
+        if p.V[i], err = decodeG2(encoded.V[i]); err != nil {
+            return err
+        }
+        if p.A[i], err = decodeGT(encoded.A[i]); err != nil {
+            return err
+        }
+        if err = checkScalar(encoded.Zsig[i]); err != nil {
+            return err
+        }
+        if err = checkScalar(encoded.Zv[i]); err != nil {
+            return err
+        }
+    }
+    if p.D, err = decodeG2(encoded.D); err != nil {
+        return err
+    }
+    if p.C, err = decodeG2(encoded.C); err != nil {
+        return err
+    }
+    if err = checkScalar(encoded.Zr); err != nil {
+        return err
+    }
+    p.Zsig, p.Zv, p.Zr = encoded.Zsig, encoded.Zv, encoded.Zr
+    *proof = p
+    return nil
+}
+
+/*
+sortedElements returns the elements of the map in increasing order, so that the
+encodings are deterministic.
+*/
+func sortedElements(signatures map[int64]*bn256.G2) []int64 {
+    elements := make([]int64, 0, len(signatures))
+    for x := range signatures {
+        elements = append(elements, x)
+    }
+    sort.Slice(elements, func(i, j int) bool { return elements[i] < elements[j] })
+    return elements
+}
+
+func writeInt64(buf *bytes.Buffer, values ...int64) {
+    for _, v := range values {
+        var data [8]byte
+        binary.BigEndian.PutUint64(data[:], uint64(v))
+        buf.Write(data[:])
+    }
+}
+
+func readInt64(r *bytes.Reader) (int64, error) {
+    data, err := readBytes(r, 8)
+    if err != nil {
+        return 0, err
+    }
+    return int64(binary.BigEndian.Uint64(data)), nil
+}
+
+/*
+writePublicParams writes H, the public key pubk, the number of signatures n and n
+pairs (element, signature).
+*/
+func writePublicParams(buf *bytes.Buffer, H *bn256.G2, pubk *bn256.G1, signatures map[int64]*bn256.G2) error {
+    if pubk == nil {
+        return errors.New("can not encode nil public key")
+    }
+    if err := writeG2(buf, H); err != nil {
+        return err
+    }
+    buf.Write(pubk.MarshalCompressed())
+    elements := sortedElements(signatures)
+    writeLength(buf, len(elements))
+    for _, x := range elements {
+        writeInt64(buf, x)
+        if err := writeG2(buf, signatures[x]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+/*
+checkPublicParams validates the decoded public parameters once, at load time: H must
+be hashed to the curve and the signatures must be valid under pubk.
+*/
+func checkPublicParams(H *bn256.G2, pubk *bn256.G1, signatures map[int64]*bn256.G2) error {
+    if err := VerifyParams(H); err != nil {
+        return err
+    }
+    return VerifySignatures(pubk, signatures)
+}
+
+func readPublicParams(r *bytes.Reader) (*bn256.G2, *bn256.G1, map[int64]*bn256.G2, error) {
+    H, err := readG2(r)
+    if err != nil {
+        return nil, nil, nil, err
+    }
+    pubk, err := readG1(r)
+    if err != nil {
+        return nil, nil, nil, err
+    }
+    n, err := readLength(r)
+    if err != nil {
+        return nil, nil, nil, err
+    }
+    if r.Len() < n*(8+g2Size) {
+        return nil, nil, nil, io.ErrUnexpectedEOF
+    }
+    signatures := make(map[int64]*bn256.G2, n)
+    for i := 0; i < n; i++ {
+        x, err := readInt64(r)
+        if err != nil {
+            return nil, nil, nil, err
+        }
+        if _, ok := signatures[x]; ok {
+            return nil, nil, nil, errors.New("duplicate signed element")
+        }
+        if signatures[x], err = readG2(r); err != nil {
+            return nil, nil, nil, err
+        }
+    }
+    if err = checkPublicParams(H, pubk, signatures); err != nil {
+        return nil, nil, nil, err
+    }
+    return H, pubk, signatures, nil
+}
+
+type publicParamsJSON struct {
+    Signatures map[int64][]byte
+    H          []byte
+    Pubk       []byte
+}
+
+func encodePublicParams(H *bn256.G2, pubk *bn256.G1, signatures map[int64]*bn256.G2) (publicParamsJSON, error) {
+    if H == nil || pubk == nil {
+        return publicParamsJSON{}, errors.New("can not encode incomplete parameters")
+    }
+    encoded := publicParamsJSON{
+        Signatures: make(map[int64][]byte, len(signatures)),
+        H:          H.MarshalCompressed(),
+        Pubk:       pubk.MarshalCompressed(),
+    }
+    for x, signature := range signatures {
+        if signature == nil {
+            return encoded, errors.New("can not encode nil point")
+        }
+        encoded.Signatures[x] = signature.MarshalCompressed()
+    }
+    return encoded, nil
+}
+
+func (encoded *publicParamsJSON) decode() (*bn256.G2, *bn256.G1, map[int64]*bn256.G2, error) {
+    H, err := decodeG2(encoded.H)
+    if err != nil {
+        return nil, nil, nil, err
+    }
+    pubk, err := decodeG1(encoded.Pubk)
+    if err != nil {
+        return nil, nil, nil, err
+    }
+    signatures := make(map[int64]*bn256.G2, len(encoded.Signatures))
+    for x, signature := range encoded.Signatures {
+        if signatures[x], err = decodeG2(signature); err != nil {
+            return nil, nil, nil, err
+        }
+    }
+    if err = checkPublicParams(H, pubk, signatures); err != nil {
+        return nil, nil, nil, err
+    }
+    return H, pubk, signatures, nil
+}
+
+/*
+MarshalBinary encodes the public parameters as: H, Pubk, the number of elements n
+and n pairs (element, signature), where the element is written as 8 bytes in
+big-endian order. The private key is not encoded.
+*/
+func (p *SetParams) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    err := writePublicParams(&buf, p.H, p.Pubk, p.Signatures)
+    return buf.Bytes(), err
+}
+
+/*
+UnmarshalBinary decodes public parameters encoded with MarshalBinary.
+*/
+func (p *SetParams) UnmarshalBinary(data []byte) error {
+    var (
+        params SetParams
+        err    error
+    )
+    r := bytes.NewReader(data)
+    if params.H, params.Pubk, params.Signatures, err = readPublicParams(r); err != nil {
+        return err
+    }
+    if r.Len() != 0 {
+        return errTrailingBytes
+    }
+    *p = params
+    return nil
+}
+
+/*
+MarshalJSON encodes the public parameters. The private key is not encoded.
+*/
+func (p *SetParams) MarshalJSON() ([]byte, error) {
+    encoded, err := encodePublicParams(p.H, p.Pubk, p.Signatures)
+    if err != nil {
+        return nil, err
+    }
+    return json.Marshal(encoded)
+}
+
+/*
+UnmarshalJSON decodes public parameters encoded with MarshalJSON.
+*/
+func (p *SetParams) UnmarshalJSON(data []byte) error {
+    var (
+        encoded publicParamsJSON
+        params  SetParams
+        err     error
+    )
+    if err = json.Unmarshal(data, &encoded); err != nil {
+        return err
+    }
+    if params.H, params.Pubk, params.Signatures, err = encoded.decode(); err != nil {
+        return err
+    }
+    *p = params
+    return nil
+}
+
+/*
+MarshalBinary encodes the parameters as: U and L, written as 8 bytes in big-endian
+order, followed by the encoding of H, Pubk and the signatures used by SetParams.
+*/
+func (p *ProverParams) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    writeInt64(&buf, p.U, p.L)
+    err := writePublicParams(&buf, p.H, p.Pubk, p.Signatures)
+    return buf.Bytes(), err
+}
+
+/*
+UnmarshalBinary decodes parameters encoded with MarshalBinary.
+*/
+func (p *ProverParams) UnmarshalBinary(data []byte) error {
+    var (
+        params ProverParams
+        err    error
+    )
+    r := bytes.NewReader(data)
+    if params.U, err = readInt64(r); err != nil {
+        return err
+    }
+    if params.L, err = readInt64(r); err != nil {
+        return err
+    }
+    if params.H, params.Pubk, params.Signatures, err = readPublicParams(r); err != nil {
+        return err
+    }
+    if r.Len() != 0 {
+        return errTrailingBytes
+    }
+    *p = params
+    return nil
+}
+
+type proverParamsJSON struct {
+    publicParamsJSON
+    U, L int64
+}
+
+/*
+MarshalJSON encodes the parameters.
+*/
+func (p *ProverParams) MarshalJSON() ([]byte, error) {
+    encoded, err := encodePublicParams(p.H, p.Pubk, p.Signatures)
+    if err != nil {
+        return nil, err
+    }
+    return json.Marshal(proverParamsJSON{encoded, p.U, p.L})
+}
+
+/*
+UnmarshalJSON decodes parameters encoded with MarshalJSON.
+*/
+func (p *ProverParams) UnmarshalJSON(data []byte) error {
+    var (
+        encoded proverParamsJSON
+        params  ProverParams
+        err     error
+    )
+    if err = json.Unmarshal(data, &encoded); err != nil {
+        return err
+    }
+    if params.H, params.Pubk, params.Signatures, err = encoded.decode(); err != nil {
+        return err
+    }
+    params.U, params.L = encoded.U, encoded.L
+    *p = params
+    return nil
+}
+
+/*
+MarshalBinary encodes the generic range proof as: C, the length of the encoding of
+P1, P1 and P2.
+*/
+func (proof *RangeProof) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    if err := writeG2(&buf, proof.C); err != nil {
+        return nil, err
+    }
+    p1, err := proof.P1.MarshalBinary()
+    if err != nil {
+        return nil, err
+    }
+    p2, err := proof.P2.MarshalBinary()
+    if err != nil {
+        return nil, err
+    }
+    writeLength(&buf, len(p1))
+    buf.Write(p1)
+    buf.Write(p2)
+    return buf.Bytes(), nil
+}
+
+/*
+UnmarshalBinary decodes a generic range proof encoded with MarshalBinary.
+*/
+func (proof *RangeProof) UnmarshalBinary(data []byte) error {
+    var (
+        p   RangeProof
+        err error
+    )
+    r := bytes.NewReader(data)
+    if p.C, err = readG2(r); err != nil {
+        return err
+    }
+    n, err := readLength(r)
+    if err != nil {
+        return err
+    }
+    p1, err := readBytes(r, n)
+    if err != nil {
+        return err
+    }
+    if err = p.P1.UnmarshalBinary(p1); err != nil {
+        return err
+    }
+    p2, err := readBytes(r, r.Len())
+    if err != nil {
+        return err
+    }
+    if err = p.P2.UnmarshalBinary(p2); err != nil {
+        return err
+    }
+    *proof = p
+    return nil
+}
+
+/*
+MarshalBinary encodes the public view of the RangeProver as: A and B, written as 8
+bytes in big-endian order, followed by the encoding of Params. The private key is
+not encoded.
+*/
+func (rp *RangeProver) MarshalBinary() ([]byte, error) {
+    var buf bytes.Buffer
+    writeInt64(&buf, rp.A, rp.B)
+    params, err := rp.Params.MarshalBinary()
+    if err != nil {
+        return nil, err
+    }
+    buf.Write(params)
+    return buf.Bytes(), nil
+}
+
+/*
+UnmarshalBinary decodes a RangeProver encoded with MarshalBinary. The decoded
+RangeProver can prove and verify, but its key does not contain the private key of
+the signatures.
+*/
+func (rp *RangeProver) UnmarshalBinary(data []byte) error {
+    var (
+        p   RangeProver
+        err error
+    )
+    r := bytes.NewReader(data)
+    if p.A, err = readInt64(r); err != nil {
+        return err
+    }
+    if p.B, err = readInt64(r); err != nil {
+        return err
+    }
+    params, err := readBytes(r, r.Len())
+    if err != nil {
+        return err
+    }
+    if err = p.Params.UnmarshalBinary(params); err != nil {
+        return err
+    }
+    // u^l must be at least b - a and lower than the group order, as in NewRangeProver
+    if p.A >= p.B || p.Params.U < 2 || p.Params.L < 1 || p.Params.L > 256 {
+        return errors.New("inconsistent range parameters")
+    }
+    width := new(big.Int).Sub(big.NewInt(p.B), big.NewInt(p.A))
+    ul := new(big.Int).Exp(big.NewInt(p.Params.U), big.NewInt(p.Params.L), nil)
+    if ul.Cmp(width) < 0 || ul.Cmp(bn256.Order) >= 0 {
+        return errors.New("inconsistent range parameters")
+    }
+    p.Key = VerifierKey{
+        Keypair: bbsignatures.Keypair{Pubk: p.Params.Pubk},
+        H:       p.Params.H,
+        U:       p.Params.U,
+        L:       p.Params.L,
+    }
+    *rp = p
+    return nil
+}
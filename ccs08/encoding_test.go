@@ -0,0 +1,183 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ccs08
+
+import (
+    "crypto/rand"
+    "encoding/json"
+    "math/big"
+    "testing"
+
+    "github.com/ing-bank/zkrp/crypto/bn256"
+)
+
+/*
+Tests that set membership proofs and public parameters can be transmitted in
+binary form, and verified by the receiver.
+*/
+func TestSetBinaryEncodeDecode(t *testing.T) {
+    p, _ := SetupSet([]int64{12, 42, 61, 71})
+    data, err := p.Public().MarshalBinary()
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    var params SetParams
+    if err = params.UnmarshalBinary(data); err != nil {
+        t.Fatal("decode error:", err)
+    }
+    if params.privk != nil || len(params.Signatures) != 4 {
+        t.Errorf("Assert failure: decoded parameters should be public")
+    }
+
+    r, _ := rand.Int(rand.Reader, bn256.Order)
+    proof_out, _ := ProveSet(42, r, params)
+    data, err = proof_out.MarshalBinary()
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    var decoded SetProof
+    if err = decoded.UnmarshalBinary(data); err != nil {
+        t.Fatal("decode error:", err)
+    }
+    result, _ := VerifySet(&decoded, &p)
+    if result != true {
+        t.Errorf("Assert failure: expected true, actual: %t", result)
+    }
+    if decoded.UnmarshalBinary(data[:len(data)-1]) == nil || decoded.UnmarshalBinary(append(data, 0)) == nil {
+        t.Errorf("Assert failure: truncated or extended proof should be rejected")
+    }
+}
+
+/*
+Tests the JSON encodings of the set membership proofs and parameters.
+*/
+func TestSetJSONEncodeDecode(t *testing.T) {
+    p, _ := SetupSet([]int64{12, 42, 61, 71})
+    data, err := json.Marshal(&p)
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    var params SetParams
+    if err = json.Unmarshal(data, &params); err != nil {
+        t.Fatal("decode error:", err)
+    }
+    if params.privk != nil || len(params.Signatures) != 4 {
+        t.Errorf("Assert failure: decoded parameters should be public")
+    }
+
+    r, _ := rand.Int(rand.Reader, bn256.Order)
+    proof_out, _ := ProveSet(61, r, params)
+    data, err = json.Marshal(&proof_out)
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    var decoded SetProof
+    if err = json.Unmarshal(data, &decoded); err != nil {
+        t.Fatal("decode error:", err)
+    }
+    result, _ := VerifySet(&decoded, &params)
+    if result != true {
+        t.Errorf("Assert failure: expected true, actual: %t", result)
+    }
+}
+
+/*
+Tests the binary and JSON encodings of the range proofs.
+*/
+func TestULEncodeDecode(t *testing.T) {
+    p, vk, _ := SetupUL(10, 5)
+    r, _ := rand.Int(rand.Reader, bn256.Order)
+    proof_out, _ := ProveUL(new(big.Int).SetInt64(42176), r, p)
+
+    data, err := proof_out.MarshalBinary()
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    var decoded ProofUL
+    if err = decoded.UnmarshalBinary(data); err != nil {
+        t.Fatal("decode error:", err)
+    }
+    result, _ := VerifyUL(&decoded, &vk)
+    if result != true {
+        t.Errorf("Assert failure: expected true, actual: %t", result)
+    }
+
+    data, err = json.Marshal(&proof_out)
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    decoded = ProofUL{}
+    if err = json.Unmarshal(data, &decoded); err != nil {
+        t.Fatal("decode error:", err)
+    }
+    result, _ = VerifyUL(&decoded, &vk)
+    if result != true {
+        t.Errorf("Assert failure: expected true, actual: %t", result)
+    }
+}
+
+/*
+Tests that the prover parameters can be sent to the prover, and that the verifier
+does not need the private key.
+*/
+func TestProverParamsEncodeDecode(t *testing.T) {
+    pp, vk, _ := SetupUL(10, 5)
+    data, err := pp.MarshalBinary()
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    var decoded ProverParams
+    if err = decoded.UnmarshalBinary(data); err != nil {
+        t.Fatal("decode error:", err)
+    }
+    jsonData, err := json.Marshal(&pp)
+    if err != nil {
+        t.Fatal("encode error:", err)
+    }
+    var decodedJSON ProverParams
+    if err = json.Unmarshal(jsonData, &decodedJSON); err != nil {
+        t.Fatal("decode error:", err)
+    }
+    if decodedJSON.U != 10 || decodedJSON.L != 5 || len(decodedJSON.Signatures) != 10 {
+        t.Errorf("Assert failure: wrong decoded parameters")
+    }
+
+    vk.Keypair.Privk = nil
+    for _, p := range []ProverParams{decoded, decodedJSON} {
+        r, _ := rand.Int(rand.Reader, bn256.Order)
+        proof_out, _ := ProveUL(new(big.Int).SetInt64(42176), r, p)
+        result, _ := VerifyUL(&proof_out, &vk)
+        if result != true {
+            t.Errorf("Assert failure: expected true, actual: %t", result)
+        }
+    }
+}
+
+func TestDecodeInvalidSignatures(t *testing.T) {
+    p, _ := SetupSet([]int64{12, 42, 61, 71})
+    p.Signatures[12], p.Signatures[42] = p.Signatures[42], p.Signatures[12]
+    data, _ := p.Public().MarshalBinary()
+    var params SetParams
+    if err := params.UnmarshalBinary(data); err != ErrInvalidSignatures {
+        t.Errorf("Assert failure: expected ErrInvalidSignatures, actual: %v", err)
+    }
+    data, _ = json.Marshal(p.Public())
+    if err := json.Unmarshal(data, &params); err != ErrInvalidSignatures {
+        t.Errorf("Assert failure: expected ErrInvalidSignatures, actual: %v", err)
+    }
+}
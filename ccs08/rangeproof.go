@@ -0,0 +1,252 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ccs08
+
+import (
+    "crypto/rand"
+    "errors"
+    "io"
+    "math"
+    "math/big"
+
+    "github.com/ing-bank/zkrp/crypto/bn256"
+    . "github.com/ing-bank/zkrp/util"
+    "github.com/ing-bank/zkrp/util/bn"
+)
+
+/*
+DefaultU is the base u used by NewRangeProver when no option is given. It keeps the
+number of signatures small, at the cost of a larger l.
+*/
+const DefaultU int64 = 57
+
+/*
+Option configures the parameters u and l of a RangeProver.
+*/
+type Option func(*rangeConfig)
+
+type rangeConfig struct {
+    u, l    int64
+    optimal bool
+}
+
+/*
+WithU sets the base u, i.e. the number of signatures in the public parameters.
+*/
+func WithU(u int64) Option {
+    return func(c *rangeConfig) { c.u = u }
+}
+
+/*
+WithL sets the number of digits l. If u is not set, the smallest u such that
+u^l >= b - a is used.
+*/
+func WithL(l int64) Option {
+    return func(c *rangeConfig) { c.l = l }
+}
+
+/*
+WithOptimalU sets u to (b - a)/log(b - a), which minimizes the size of the proofs
+as explained in the paper. Setup computes u signatures, so this is only practical
+for small intervals.
+*/
+func WithOptimalU() Option {
+    return func(c *rangeConfig) { c.optimal = true }
+}
+
+/*
+RangeProver contains the parameters of the CCS08 range proofs for any interval
+[A, B). Params can be given to the provers, preferably through Public, while Key
+is only needed by the verifier.
+*/
+type RangeProver struct {
+    A, B   int64
+    Params ProverParams
+    Key    VerifierKey
+}
+
+/*
+RangeProof stores the generic ZKRP. C is the commitment to the secret x. P1 proves
+that x - B + u^l belongs to [0, u^l) and P2 proves that x - A belongs to [0, u^l),
+where the commitments of both proofs are derived from C by the verifier.
+*/
+type RangeProof struct {
+    C      *bn256.G2
+    P1, P2 ProofUL
+}
+
+/*
+ceilRoot returns the smallest u >= 2 such that u^l >= w.
+*/
+func ceilRoot(w *big.Int, l int64) int64 {
+    lo, hi := int64(2), int64(2)
+    for new(big.Int).Exp(big.NewInt(hi), big.NewInt(l), nil).Cmp(w) < 0 {
+        lo, hi = hi, 2*hi
+    }
+    for lo < hi {
+        mid := lo + (hi-lo)/2
+        if new(big.Int).Exp(big.NewInt(mid), big.NewInt(l), nil).Cmp(w) < 0 {
+            lo = mid + 1
+        } else {
+            hi = mid
+        }
+    }
+    return lo
+}
+
+/*
+NewRangeProver runs the setup of the CCS08 range proofs for the interval [a, b).
+By default u = DefaultU and l is the smallest integer such that u^l >= b - a.
+*/
+func NewRangeProver(a, b int64, options ...Option) (*RangeProver, error) {
+    var config rangeConfig
+    if a >= b {
+        return nil, errors.New("range start must be lower than range end")
+    }
+    for _, option := range options {
+        option(&config)
+    }
+    if config.u < 0 || config.u == 1 {
+        return nil, errors.New("u must be at least 2")
+    }
+    if config.l < 0 {
+        return nil, errors.New("l must be greater than zero")
+    }
+    width := new(big.Int).Sub(big.NewInt(b), big.NewInt(a))
+    u, l := config.u, config.l
+    if u == 0 && config.optimal {
+        w, _ := new(big.Float).SetInt(width).Float64()
+        u = int64(w / math.Log(w))
+    }
+    if u == 0 && l > 0 {
+        u = ceilRoot(width, l)
+    }
+    if u == 0 {
+        u = DefaultU
+    }
+    if u < 2 {
+        u = 2
+    }
+    if l == 0 {
+        for ul := big.NewInt(1); ul.Cmp(width) < 0; ul.Mul(ul, big.NewInt(u)) {
+            l = l + 1
+        }
+        if l == 0 {
+            l = 1
+        }
+    }
+    ul := new(big.Int).Exp(big.NewInt(u), big.NewInt(l), nil)
+    if ul.Cmp(width) < 0 {
+        return nil, errors.New("u^l must be greater than or equal to b - a")
+    }
+    if ul.Cmp(bn256.Order) >= 0 {
+        return nil, errors.New("u^l must be lower than the group order")
+    }
+
+    pp, vk, err := SetupUL(u, l)
+    if err != nil {
+        return nil, err
+    }
+    return &RangeProver{A: a, B: b, Params: pp, Key: vk}, nil
+}
+
+/*
+Public returns the parameters without the private key of the signatures, which
+can be given to the provers.
+*/
+func (rp *RangeProver) Public() *RangeProver {
+    public := *rp
+    public.Key.Keypair.Privk = nil
+    return &public
+}
+
+/*
+Commit returns the commitment to x with blinding factor r, i.e. the value C of a
+generic range proof.
+*/
+func (rp *RangeProver) Commit(x, r *big.Int) (*bn256.G2, error) {
+    return Commit(bn.Mod(x, bn256.Order), bn.Mod(r, bn256.Order), rp.Params.H)
+}
+
+/*
+shifts returns the values added to the secret in each proof: u^l - B and -A.
+*/
+func (rp *RangeProver) shifts() (*big.Int, *big.Int) {
+    shift1 := new(big.Int).Exp(big.NewInt(rp.Params.U), big.NewInt(rp.Params.L), nil)
+    shift1.Sub(shift1, big.NewInt(rp.B))
+    shift2 := new(big.Int).Neg(big.NewInt(rp.A))
+    return shift1, shift2
+}
+
+/*
+Prove computes the proof that x belongs to [A, B), for the commitment with
+blinding factor r. The blinding values are drawn from crypto/rand.
+*/
+func (rp *RangeProver) Prove(x, r *big.Int) (RangeProof, error) {
+    return rp.ProveWithReader(rand.Reader, x, r)
+}
+
+/*
+ProveWithReader computes the proof that x belongs to [A, B), drawing the blinding
+values from rnd.
+*/
+func (rp *RangeProver) ProveWithReader(rnd io.Reader, x, r *big.Int) (RangeProof, error) {
+    var (
+        proof RangeProof
+        err   error
+    )
+    if x.Cmp(big.NewInt(rp.A)) < 0 || x.Cmp(big.NewInt(rp.B)) >= 0 {
+        return proof, errors.New("secret does not belong to the interval [a, b)")
+    }
+    shift1, shift2 := rp.shifts()
+    if proof.C, err = rp.Commit(x, r); err != nil {
+        return proof, err
+    }
+    r = bn.Mod(r, bn256.Order)
+
+    // x - b + u^l
+    if proof.P1, err = ProveULWithReader(rnd, new(big.Int).Add(x, shift1), r, rp.Params); err != nil {
+        return proof, err
+    }
+    // x - a
+    if proof.P2, err = ProveULWithReader(rnd, new(big.Int).Add(x, shift2), r, rp.Params); err != nil {
+        return proof, err
+    }
+    return proof, nil
+}
+
+/*
+Verify checks that the secret committed in C belongs to [A, B). The commitments of
+both proofs are derived from C.
+*/
+func (rp *RangeProver) Verify(proof *RangeProof) (bool, error) {
+    if proof.C == nil {
+        return false, errors.New("invalid commitment")
+    }
+    shift1, shift2 := rp.shifts()
+    p1 := proof.P1
+    p1.C = new(bn256.G2).Add(proof.C, new(bn256.G2).ScalarBaseMult(bn.Mod(shift1, bn256.Order)))
+    p2 := proof.P2
+    p2.C = new(bn256.G2).Add(proof.C, new(bn256.G2).ScalarBaseMult(bn.Mod(shift2, bn256.Order)))
+
+    ok, err := VerifyUL(&p1, &rp.Key)
+    if !ok || err != nil {
+        return false, err
+    }
+    return VerifyUL(&p2, &rp.Key)
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ccs08
+
+import (
+    "errors"
+    "math/big"
+
+    "github.com/ing-bank/zkrp"
+    "github.com/ing-bank/zkrp/crypto/bn256"
+)
+
+/*
+SchemeName is the name of the CCS08 range proofs in the zkrp registry. The
+parameters are computed by NewRangeProver with the default options.
+*/
+const SchemeName = "ccs08"
+
+func init() {
+    zkrp.Register(scheme{})
+}
+
+/*
+scheme implements zkrp.Scheme using NewRangeProver.
+*/
+type scheme struct{}
+
+func (scheme) Name() string {
+    return SchemeName
+}
+
+/*
+Setup returns the public view of the RangeProver to the provers, while the verifier
+key keeps the private key of the signatures.
+*/
+func (scheme) Setup(a, b int64) (zkrp.ProverParams, zkrp.VerifierKey, error) {
+    rp, err := NewRangeProver(a, b)
+    if err != nil {
+        return nil, nil, err
+    }
+    return proverParams{rp.Public()}, verifierKey{rp}, nil
+}
+
+/*
+UnmarshalVerifierKey decodes a RangeProver encoded with RangeProver.MarshalBinary,
+which does not contain the private key.
+*/
+func (scheme) UnmarshalVerifierKey(data []byte) (zkrp.VerifierKey, error) {
+    rp := new(RangeProver)
+    if err := rp.UnmarshalBinary(data); err != nil {
+        return nil, err
+    }
+    return verifierKey{rp}, nil
+}
+
+/*
+commitment implements zkrp.Commitment for the points of G2.
+*/
+type commitment struct {
+    C *bn256.G2
+}
+
+func (c commitment) MarshalBinary() ([]byte, error) {
+    return c.C.MarshalCompressed(), nil
+}
+
+/*
+proverParams implements zkrp.ProverParams for the public view of a RangeProver.
+*/
+type proverParams struct {
+    rp *RangeProver
+}
+
+func (p proverParams) Interval() (int64, int64) {
+    return p.rp.A, p.rp.B
+}
+
+func (p proverParams) Commit(x, r *big.Int) (zkrp.Commitment, error) {
+    C, err := p.rp.Commit(x, r)
+    if err != nil {
+        return nil, err
+    }
+    return commitment{C}, nil
+}
+
+func (p proverParams) Prove(x, r *big.Int) (zkrp.Proof, error) {
+    proof, err := p.rp.Prove(x, r)
+    if err != nil {
+        return nil, err
+    }
+    return &proof, nil
+}
+
+/*
+verifierKey implements zkrp.VerifierKey for the RangeProver built by the verifier.
+*/
+type verifierKey struct {
+    rp *RangeProver
+}
+
+func (k verifierKey) Interval() (int64, int64) {
+    return k.rp.A, k.rp.B
+}
+
+func (k verifierKey) Commit(x, r *big.Int) (zkrp.Commitment, error) {
+    return proverParams{k.rp}.Commit(x, r)
+}
+
+func (k verifierKey) Verify(proof zkrp.Proof) (bool, error) {
+    rp, ok := proof.(*RangeProof)
+    if !ok {
+        return false, zkrp.ErrWrongProofType
+    }
+    return k.rp.Verify(rp)
+}
+
+func (k verifierKey) UnmarshalProof(data []byte) (zkrp.Proof, error) {
+    proof := new(RangeProof)
+    if err := proof.UnmarshalBinary(data); err != nil {
+        return nil, err
+    }
+    return proof, nil
+}
+
+func (k verifierKey) UnmarshalCommitment(data []byte) (zkrp.Commitment, error) {
+    C, err := decodeG2(data)
+    if err != nil {
+        return nil, err
+    }
+    return commitment{C}, nil
+}
+
+func (k verifierKey) AddCommitments(a, b zkrp.Commitment) (zkrp.Commitment, error) {
+    ca, ok1 := a.(commitment)
+    cb, ok2 := b.(commitment)
+    if !ok1 || !ok2 {
+        return nil, errors.New("commitment was computed by another scheme")
+    }
+    return commitment{new(bn256.G2).Add(ca.C, cb.C)}, nil
+}
+
+/*
+MarshalBinary encodes the public view of the RangeProver.
+*/
+func (k verifierKey) MarshalBinary() ([]byte, error) {
+    return k.rp.MarshalBinary()
+}
+
+/*
+Commitment returns the commitment C to the secret.
+*/
+func (proof *RangeProof) Commitment() zkrp.Commitment {
+    return commitment{proof.C}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	crand "crypto/rand"
 	"encoding/json"
 	"fmt"
 	"math/big"
@@ -10,9 +12,9 @@ import (
 	"sync"
 	"time"
 
-	"github.com/ing-bank/zkrp/bulletproofs"
-	"github.com/ing-bank/zkrp/crypto/p256"
-	"github.com/ing-bank/zkrp/util"
+	"github.com/ing-bank/zkrp"
+	_ "github.com/ing-bank/zkrp/bulletproofs"
+	_ "github.com/ing-bank/zkrp/ccs08"
 )
 
 type System struct {
@@ -21,42 +23,49 @@ type System struct {
 }
 
 type User struct {
-	gamma    int64
-	delta    int64
-	nUsers   int
-	idx      int
-	reading  int
-	r        *big.Int
-	commits1 map[int][]byte
-	commits2 map[int][]byte
-	proofs   map[int][]byte
-	sumProof []byte
+	gamma     int64
+	delta     int64
+	nUsers    int
+	idx       int
+	reading   int
+	r         *big.Int
+	commits   map[int][]byte
+	proofs    map[int][]byte
+	sumCommit []byte
+	sumProof  []byte
+	key       zkrp.VerifierKey
+	sumKey    zkrp.VerifierKey
 }
 
 type UserJSON struct {
-	Gamma    int64
-	Delta    int64
-	NUsers   int
-	Idx      int
-	Reading  int
-	R        *big.Int
-	Commits1 map[int][]byte
-	Commits2 map[int][]byte
-	Proofs   map[int][]byte
-	SumProof []byte
+	Scheme    string
+	Gamma     int64
+	Delta     int64
+	NUsers    int
+	Idx       int
+	Reading   int
+	R         *big.Int
+	Commits   map[int][]byte
+	Proofs    map[int][]byte
+	SumCommit []byte
+	SumProof  []byte
+	Key       []byte
+	SumKey    []byte
 }
 
 type Company struct {
-	gamma    int64
-	delta    int64
-	nUsers   int
-	readings map[int]int
-	sum      int
-	rs       map[int]*big.Int
-	commits1 map[int][]byte
-	commits2 map[int][]byte
-	proofs   map[int][]byte
-	sumProof []byte
+	gamma     int64
+	delta     int64
+	nUsers    int
+	readings  map[int]int
+	sum       int
+	rs        map[int]*big.Int
+	commits   map[int][]byte
+	proofs    map[int][]byte
+	sumCommit []byte
+	sumProof  []byte
+	params    zkrp.ProverParams
+	sumParams zkrp.ProverParams
 }
 
 func (s *System) drawReadings(maxN int) {
@@ -80,8 +89,7 @@ func (u *User) shareReading(c *Company) {
 func (c *Company) processReadings() {
 	c.sum = 0
 	c.proofs = make(map[int][]byte)
-	c.commits1 = make(map[int][]byte)
-	c.commits2 = make(map[int][]byte)
+	c.commits = make(map[int][]byte)
 	sumR := big.NewInt(int64(0))
 	for i := 0; i < c.nUsers; i++ {
 		c.sum = c.sum + c.readings[i]
@@ -94,105 +102,56 @@ func (c *Company) processReadings() {
 		go c.generateProof(i, wg)
 	}
 
-	psum, _ := bulletproofs.SetupGeneric(c.gamma, c.delta*int64(c.nUsers))
-	sumProof, _ := bulletproofs.ProveGeneric(big.NewInt(int64(c.sum)), psum, sumR)
-	c.sumProof, _ = json.Marshal(sumProof)
+	sumCommit, err := c.sumParams.Commit(big.NewInt(int64(c.sum)), sumR)
+	check(err)
+	c.sumCommit, _ = sumCommit.MarshalBinary()
+	// the proof fails if the sum does not belong to [gamma, nUsers * delta)
+	c.sumProof = nil
+	if sumProof, err := c.sumParams.Prove(big.NewInt(int64(c.sum)), sumR); err == nil {
+		c.sumProof, _ = sumProof.MarshalBinary()
+	}
 
 	wg.Wait()
-
-	// // sanity check
-
-	// p, _ := bulletproofs.SetupGeneric(0, c.delta)
-	// eta, _ := util.CommitG1(big.NewInt(int64(10)), big.NewInt(int64(10)), p.BP2.H)
-	// zeta, _ := util.CommitG1(big.NewInt(int64(10-c.gamma)), big.NewInt(int64(10)), p.BP2.H)
-	// sumV2 := new(p256.P256).Add(sumProof.P2.V, eta)
-	// var (
-	// 	cm    *p256.P256
-	// 	cStar *p256.P256
-	// )
-	// _ = json.Unmarshal(c.commits2[0], &cStar)
-	// for i := 1; i < c.nUsers; i++ {
-	// 	_ = json.Unmarshal(c.commits2[i], &cm)
-	// 	cStar = new(p256.P256).Add(cStar, cm)
-	// }
-	// //    _ = json.Unmarshal(c.commits2[0], &cStar)
-	// //    _ = json.Unmarshal(c.commits2[1], &cm)
-	// //    cStar = new(p256.P256).Add(cStar, cm)
-	// cStar = new(p256.P256).Add(cStar, zeta)
-
-	// fmt.Println(sumV2.Equals(cStar))
 }
 
 var proofMtx sync.Mutex
 
 func (c *Company) generateProof(idx int, wg *sync.WaitGroup) {
 	defer wg.Done()
-	p, _ := bulletproofs.SetupGeneric(0, c.delta)
-	proof, _ := bulletproofs.ProveGeneric(big.NewInt(int64(c.readings[idx])), p, c.rs[idx])
+	proof, err := c.params.Prove(big.NewInt(int64(c.readings[idx])), c.rs[idx])
+	check(err)
 
 	proofMtx.Lock()
 	defer proofMtx.Unlock()
-	c.proofs[idx], _ = json.Marshal(proof)
-	c.commits1[idx], _ = json.Marshal(proof.P1.V)
-	c.commits2[idx], _ = json.Marshal(proof.P2.V)
+	c.proofs[idx], _ = proof.MarshalBinary()
+	c.commits[idx], _ = proof.Commitment().MarshalBinary()
 }
 
-//func (c *Company) processReadingsMaliciously() {
-//    c.sum = 0
-//    c.proofs = make(map[int][]byte)
-//    c.commits1 = make(map[int][]byte)
-//    c.commits2 = make(map[int][]byte)
-//    c.sumR = big.NewInt(int64(0))
-//    p, _ := bulletproofs.SetupGeneric(0, c.d)
-//    for i:=0;i<c.nUsers;i++ {
-//        c.sum = c.sum + c.readings[i]
-//        proof1, _ := bulletproofs.ProveGeneric(big.NewInt(int64(c.readings[i])), p, c.rs[i])
-//        proof2, _ := bulletproofs.ProveGeneric(big.NewInt(int64(c.d-1)), p, c.rs[i])
-//        proof2.P1.V = proof1.P1.V
-//        proof2.P2.V = proof1.P2.V
-//        c.proofs[i], _ = json.Marshal(proof2)
-//        c.commits1[i], _ = json.Marshal(proof2.P1.V)
-//        c.commits2[i], _ = json.Marshal(proof2.P2.V)
-//        rand.Seed(c.rs[i])
-//        c.sumR.Add(c.sumR, big.NewInt(int64(rand.Int())))
-//    }
-//}
-
 func (s *System) shareProofData() {
 	s.company.shareProofData(s.users[0])
-	// for i := 0; i < s.company.nUsers; i++ {
-	// 	s.company.shareProofData(s.users[i])
-	// }
 }
 
 func (c *Company) shareProofData(u *User) {
 	u.proofs = make(map[int][]byte)
-	u.commits1 = make(map[int][]byte)
-	u.commits2 = make(map[int][]byte)
+	u.commits = make(map[int][]byte)
 	for key, value := range c.proofs {
 		u.proofs[key] = value
 	}
-	for key, value := range c.commits1 {
-		u.commits1[key] = value
-	}
-	for key, value := range c.commits2 {
-		u.commits2[key] = value
+	for key, value := range c.commits {
+		u.commits[key] = value
 	}
+	u.sumCommit = c.sumCommit
 	u.sumProof = c.sumProof
 }
 
 func (u *User) checkCommitment() {
-	var (
-		commit1 *p256.P256
-		commit2 *p256.P256
-	)
-	params, _ := bulletproofs.SetupGeneric(0, u.delta)
-	V1, _ := util.CommitG1(big.NewInt(int64(u.reading)-u.delta+bulletproofs.MAX_RANGE_END), u.r, params.BP1.H) // note: params.BP1.H = params.BP2.H
-	V2, _ := util.CommitG1(big.NewInt(int64(u.reading)), u.r, params.BP2.H)
-	_ = json.Unmarshal(u.commits1[u.idx], &commit1)
-	_ = json.Unmarshal(u.commits2[u.idx], &commit2)
-
-	check := V1.Equals(commit1) && V2.Equals(commit2)
+	commit, err := u.key.Commit(big.NewInt(int64(u.reading)), u.r)
+	if err != nil {
+		fmt.Println("check 1 for user", u.idx, "FAILED:", err)
+		return
+	}
+
+	check := equalCommitments(commit, u.commits[u.idx])
 	if check {
 		fmt.Println("check 1 for user", u.idx, "succeeded")
 	} else {
@@ -201,58 +160,45 @@ func (u *User) checkCommitment() {
 }
 
 func (u *User) checkSumProof() {
-	var (
-		sumProof bulletproofs.ProofBPRP
-		commit1  *p256.P256
-		commit2  *p256.P256
-		Cstar1   *p256.P256
-		Cstar2   *p256.P256
-	)
-	p, _ := bulletproofs.SetupGeneric(u.gamma, u.delta*int64(u.nUsers))
-	_ = json.Unmarshal(u.sumProof, &sumProof)
-	_ = json.Unmarshal(u.commits1[0], &Cstar1)
-	_ = json.Unmarshal(u.commits2[0], &Cstar2)
-
-	// it's impossible (due to nature of elliptic curves or bug in zkrp code?) to commit(x,r,p) using x = 0 or r = 0, or to compute c(x,r,p) + c(x,r,p)
-	// to get around this, we need to add a non-zero dummy to both commitments
-	dummy := int64(10)
-
-	eta1, _ := util.CommitG1(big.NewInt(int64(dummy-u.delta*int64(u.nUsers)+bulletproofs.MAX_RANGE_END*int64(u.nUsers))), big.NewInt(int64(dummy)), p.BP2.H)
-	zeta1, _ := util.CommitG1(big.NewInt(int64(dummy-u.delta*int64(u.nUsers)+bulletproofs.MAX_RANGE_END)), big.NewInt(int64(dummy)), p.BP2.H)
-	eta2, _ := util.CommitG1(big.NewInt(int64(dummy)), big.NewInt(int64(dummy)), p.BP2.H)
-	zeta2, _ := util.CommitG1(big.NewInt(int64(dummy-u.gamma)), big.NewInt(int64(dummy)), p.BP2.H)
-
-	for i := 1; i < len(u.commits2); i++ {
-		_ = json.Unmarshal(u.commits1[i], &commit1)
-		_ = json.Unmarshal(u.commits2[i], &commit2)
-		Cstar1 = new(p256.P256).Add(Cstar1, commit1)
-		Cstar2 = new(p256.P256).Add(Cstar2, commit2)
+	var sum zkrp.Commitment
+	for i := 0; i < len(u.commits); i++ {
+		commit, err := u.key.UnmarshalCommitment(u.commits[i])
+		if err != nil {
+			fmt.Println("check 2 for user", u.idx, "FAILED: invalid commitment", i)
+			return
+		}
+		if i == 0 {
+			sum = commit
+		} else if sum, err = u.key.AddCommitments(sum, commit); err != nil {
+			fmt.Println("check 2 for user", u.idx, "FAILED:", err)
+			return
+		}
 	}
 
-	sumV1 := new(p256.P256).Add(sumProof.P1.V, eta1)
-	Cstar1 = new(p256.P256).Add(Cstar1, zeta1)
-	sumV2 := new(p256.P256).Add(sumProof.P2.V, eta2)
-	Cstar2 = new(p256.P256).Add(Cstar2, zeta2)
-
-	ok1 := sumV1.Equals(Cstar1)
+	ok1 := sum != nil && equalCommitments(sum, u.sumCommit)
 	if !ok1 {
-		fmt.Println("failure in check 2 for user", u.idx, ": commitment 1 did not match sum")
+		fmt.Println("failure in check 2 for user", u.idx, ": commitment did not match sum")
 	}
 
-	ok2 := sumV2.Equals(Cstar2)
-	if !ok2 {
-		fmt.Println("failure in check 2 for user", u.idx, ": commitment 2 did not match sum")
+	// the company can only prove the sum if it belongs to [gamma, nUsers * delta)
+	ok2 := false
+	if len(u.sumProof) != 0 {
+		sumProof, err := u.sumKey.UnmarshalProof(u.sumProof)
+		if err != nil {
+			fmt.Println("check 2 for user", u.idx, "FAILED: invalid sum proof:", err)
+			return
+		}
+		ok2, _ = u.sumKey.Verify(sumProof)
+		ok2 = ok2 && equalCommitments(sumProof.Commitment(), u.sumCommit)
 	}
-
-	ok3, _ := sumProof.Verify()
-	if !ok3 {
+	if !ok2 {
 		fmt.Println("range proof failed in check 2 for user", u.idx)
 	}
 
-	if ok1 && ok2 && ok3 {
+	if ok1 && ok2 {
 		fmt.Println("check 2 for user", u.idx, "succeeded, can charge peak rate")
 	} else {
-		if ok1 && ok2 {
+		if ok1 {
 			fmt.Println("check 2 for user", u.idx, "succeeded, but CANNOT charge peak rate")
 		} else {
 			fmt.Println("check 2 for user", u.idx, "FAILED")
@@ -261,42 +207,27 @@ func (u *User) checkSumProof() {
 }
 
 func (u *User) checkRangeProofs() {
-	var mtx sync.Mutex
-	var wg sync.WaitGroup
 	noFailures := true
+	proofs := make([]zkrp.Proof, len(u.proofs))
 	for i := 0; i < len(u.proofs); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			var (
-				proof   bulletproofs.ProofBPRP
-				commit1 *p256.P256
-				commit2 *p256.P256
-			)
-			_ = json.Unmarshal(u.proofs[i], &proof)
-			_ = json.Unmarshal(u.commits1[i], &commit1)
-			_ = json.Unmarshal(u.commits2[i], &commit2)
-
-			ok1 := proof.P1.V.Equals(commit1)
-			if !ok1 {
-				fmt.Println("failure in check 3 for user", u.idx, ": commitment 1 did not match")
-			}
-			ok2 := proof.P2.V.Equals(commit2)
-			if !ok2 {
-				fmt.Println("failure in check 3 for user", u.idx, ": commitment 2 did not match")
-			}
-			ok3, _ := proof.Verify()
-			if !ok3 {
-				fmt.Println("failure in check 3 for user", u.idx, ": invalid proof")
-			}
-			mtx.Lock()
-			defer mtx.Unlock()
-			if noFailures {
-				noFailures = noFailures && ok1 && ok2 && ok3
-			}
-		}(i)
+		proof, err := u.key.UnmarshalProof(u.proofs[i])
+		if err != nil {
+			fmt.Println("check 3 for user", u.idx, "FAILED: invalid proof:", err)
+			return
+		}
+
+		ok1 := equalCommitments(proof.Commitment(), u.commits[i])
+		if !ok1 {
+			fmt.Println("failure in check 3 for user", u.idx, ": commitment did not match")
+		}
+		noFailures = noFailures && ok1
+		proofs[i] = proof
 	}
-	wg.Wait()
+	ok2, _ := zkrp.BatchVerify(u.key, proofs)
+	if !ok2 {
+		fmt.Println("failure in check 3 for user", u.idx, ": invalid proof")
+	}
+	noFailures = noFailures && ok2
 
 	if noFailures {
 		fmt.Println("check 3 for user", u.idx, "succeeded")
@@ -321,44 +252,54 @@ func (u *User) checkProofs() {
 	u.checkRangeProofs()
 }
 
-func initialize(n int, gamma int64, delta int64) System {
+// equalCommitments returns true if the commitment is encoded as data
+func equalCommitments(c zkrp.Commitment, data []byte) bool {
+	encoded, err := c.MarshalBinary()
+	return err == nil && bytes.Equal(encoded, data)
+}
+
+// initialize runs the setup of the range proofs of the scheme with the given
+// name on behalf of the users, who keep the verifier keys
+func initialize(scheme string, n int, gamma int64, delta int64) System {
+	params, key, err := zkrp.Setup(scheme, 0, delta)
+	check(err)
+	sumParams, sumKey, err := zkrp.Setup(scheme, gamma, delta*int64(n))
+	check(err)
 	users := make(map[int]*User)
 	rs := make(map[int]*big.Int)
 	for i := 0; i < n; i++ {
-		r := big.NewInt(int64(rand.Int()))
-		users[i] = &User{gamma, delta, n, i, 0, r, nil, nil, nil, nil}
+		r, err := crand.Int(crand.Reader, maxR)
+		check(err)
+		users[i] = &User{gamma, delta, n, i, 0, r, nil, nil, nil, nil, key, sumKey}
 		rs[i] = r
 	}
-	company := Company{gamma, delta, n, nil, 0, rs, nil, nil, nil, nil}
+	company := Company{gamma, delta, n, nil, 0, rs, nil, nil, nil, nil, params, sumParams}
 	return System{users, company}
 }
 
-//func Sanity() {
-//    pa, _ := bulletproofs.SetupGeneric(0, 20)
-//    pb, _ := bulletproofs.SetupGeneric(0, 20)
-//    pc, _ := bulletproofs.SetupGeneric(10, 20)
-//    proofa, _ := bulletproofs.ProveGeneric(big.NewInt(int64(5)),  pa, big.NewInt(int64(1200)))
-//    proofb, _ := bulletproofs.ProveGeneric(big.NewInt(int64(10)),  pb, big.NewInt(int64(1200)))
-//    proofc, _ := bulletproofs.ProveGeneric(big.NewInt(int64(25)), pc, big.NewInt(int64(2400)))
-//
-//    commit1a, _ := util.CommitG1(big.NewInt(int64(5) - 20 + bulletproofs.MAX_RANGE_END), big.NewInt(int64(1200)), pa.BP2.H)
-//    commit2a, _ := util.CommitG1(big.NewInt(int64(5)), big.NewInt(int64(1200)), pa.BP2.H)
-//    commit1b, _ := util.CommitG1(big.NewInt(int64(10) - 20 + bulletproofs.MAX_RANGE_END), big.NewInt(int64(1200)), pb.BP2.H)
-//    commit2b, _ := util.CommitG1(big.NewInt(int64(15)), big.NewInt(int64(600)), pb.BP2.H)
-//    commit2bsum, _ := util.CommitG1(big.NewInt(-5), big.NewInt(int64(600)), pb.BP2.H)
-//    commit2b.Add(commit2b, commit2bsum)
-//
-//    commitabsum := new(p256.P256).Add(commit2a, commit2b)
-//
-//    fmt.Println(proofa.P1.V.Equals(commit1a))
-//    fmt.Println(proofa.P2.V.Equals(commit2a))
-//    fmt.Println(proofb.P1.V.Equals(commit1b))
-//    fmt.Println(proofb.P2.V.Equals(commit2b))
-//    fmt.Println(proofc.P2.V.Equals(commitabsum))
-//}
+// maxR bounds the blinding factors, which are reduced modulo the order of the
+// group of the scheme
+var maxR = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// schemeName returns the name of the scheme given after the arguments of the
+// command, or bulletproofs by default
+func schemeName(i int) string {
+	if len(os.Args) > i {
+		return os.Args[i]
+	}
+	return "bulletproofs"
+}
+
+// checkScheme returns an error if a scheme name is given after the arguments of
+// the command and differs from the scheme stored in user.json
+func checkScheme(i int, stored string) error {
+	if len(os.Args) > i && os.Args[i] != stored {
+		return fmt.Errorf("user.json was created with scheme %q, not %q", stored, os.Args[i])
+	}
+	return nil
+}
 
 func main() {
-	//    Sanity()
 	if len(os.Args) < 2 {
 		return
 	}
@@ -367,7 +308,8 @@ func main() {
 		nUsers, err := strconv.Atoi(os.Args[2])
 		check(err)
 		startTime := time.Now().UnixNano()
-		system := initialize(nUsers, 500, 120)
+		scheme := schemeName(3)
+		system := initialize(scheme, nUsers, 500, 120)
 		system.drawReadings(100)
 		system.shareReadings()
 		shareReadingsTime := time.Now().UnixNano()
@@ -377,17 +319,24 @@ func main() {
 		fmt.Println("sharing:", float64(shareReadingsTime-startTime)/1000000000, "seconds")
 		fmt.Println("processing:", float64(processReadingsTime-shareReadingsTime)/1000000000, "seconds")
 
+		key, err := system.users[0].key.MarshalBinary()
+		check(err)
+		sumKey, err := system.users[0].sumKey.MarshalBinary()
+		check(err)
 		uj := UserJSON{
-			Gamma:    system.users[0].gamma,
-			Delta:    system.users[0].delta,
-			NUsers:   system.users[0].nUsers,
-			Idx:      system.users[0].idx,
-			Reading:  system.users[0].reading,
-			R:        system.users[0].r,
-			Commits1: system.users[0].commits1,
-			Commits2: system.users[0].commits2,
-			Proofs:   system.users[0].proofs,
-			SumProof: system.users[0].sumProof,
+			Scheme:    scheme,
+			Gamma:     system.users[0].gamma,
+			Delta:     system.users[0].delta,
+			NUsers:    system.users[0].nUsers,
+			Idx:       system.users[0].idx,
+			Reading:   system.users[0].reading,
+			R:         system.users[0].r,
+			Commits:   system.users[0].commits,
+			Proofs:    system.users[0].proofs,
+			SumCommit: system.users[0].sumCommit,
+			SumProof:  system.users[0].sumProof,
+			Key:       key,
+			SumKey:    sumKey,
 		}
 		f, _ := os.Create("user.json")
 		defer f.Close()
@@ -399,17 +348,24 @@ func main() {
 		defer f.Close()
 		d := json.NewDecoder(f)
 		d.Decode(&uj)
+		check(checkScheme(2, uj.Scheme))
+		key, err := zkrp.UnmarshalVerifierKey(uj.Scheme, uj.Key)
+		check(err)
+		sumKey, err := zkrp.UnmarshalVerifierKey(uj.Scheme, uj.SumKey)
+		check(err)
 		user := User{
-			gamma:    uj.Gamma,
-			delta:    uj.Delta,
-			nUsers:   uj.NUsers,
-			idx:      uj.Idx,
-			reading:  uj.Reading,
-			r:        uj.R,
-			commits1: uj.Commits1,
-			commits2: uj.Commits2,
-			proofs:   uj.Proofs,
-			sumProof: uj.SumProof,
+			gamma:     uj.Gamma,
+			delta:     uj.Delta,
+			nUsers:    uj.NUsers,
+			idx:       uj.Idx,
+			reading:   uj.Reading,
+			r:         uj.R,
+			commits:   uj.Commits,
+			proofs:    uj.Proofs,
+			sumCommit: uj.SumCommit,
+			sumProof:  uj.SumProof,
+			key:       key,
+			sumKey:    sumKey,
 		}
 		startTime := time.Now().UnixNano()
 		user.checkProofs()
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	crand "crypto/rand"
 	"encoding/json"
 	"fmt"
 	"math/big"
@@ -9,10 +11,10 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/ing-bank/zkrp/bulletproofs"
-	"github.com/ing-bank/zkrp/crypto/p256"
+	"github.com/ing-bank/zkrp"
+	_ "github.com/ing-bank/zkrp/bulletproofs"
+	_ "github.com/ing-bank/zkrp/ccs08"
 	"github.com/ing-bank/zkrp/merkle"
-	"github.com/ing-bank/zkrp/util"
 )
 
 type System struct {
@@ -29,9 +31,12 @@ type User struct {
 	r        *big.Int
 	path     *merkle.Path
 	sumProof []byte
+	params   *merkle.Params
+	sumKey   zkrp.VerifierKey
 }
 
 type UserJSON struct {
+	Scheme   string
 	Gamma    int64
 	Delta    int64
 	NUsers   int
@@ -40,17 +45,21 @@ type UserJSON struct {
 	R        *big.Int
 	Path     *merkle.Path
 	SumProof []byte
+	Keys     map[int][]byte
+	SumKey   []byte
 }
 
 type Company struct {
-	gamma    int64
-	delta    int64
-	nUsers   int
-	readings map[int]int
-	sum      int
-	rs       map[int]*big.Int
-	treeRoot *merkle.Node
-	sumProof []byte
+	gamma     int64
+	delta     int64
+	nUsers    int
+	readings  map[int]int
+	sum       int
+	rs        map[int]*big.Int
+	treeRoot  *merkle.Node
+	sumProof  []byte
+	params    *merkle.Params
+	sumParams zkrp.ProverParams
 }
 
 func (s *System) drawReadings(maxN int) {
@@ -73,16 +82,18 @@ func (u *User) shareReading(c *Company) {
 
 func (c *Company) processReadings() {
 	c.sum = 0
-	c.treeRoot = new(merkle.Node).BuildTree(c.readings, c.rs, c.delta)
+	root, err := new(merkle.Node).BuildTree(c.readings, c.rs, c.params)
+	check(err)
+	c.treeRoot = root
 	sumR := big.NewInt(int64(0)) // big.NewInt(int64(c.treeRoot.GetNumLeaves() - c.nUsers)) // because all dummies get r = 1
 	for i := 0; i < c.nUsers; i++ {
 		c.sum = c.sum + c.readings[i]
 		sumR = new(big.Int).Add(sumR, c.rs[i])
 	}
 	fmt.Println("sum:", c.sum)
-	psum, _ := bulletproofs.SetupGeneric(c.gamma, c.delta*int64(c.nUsers))
-	proof, _ := bulletproofs.ProveGeneric(big.NewInt(int64(c.sum)), psum, sumR)
-	c.sumProof, _ = json.Marshal(proof)
+	if proof, err := c.sumParams.Prove(big.NewInt(int64(c.sum)), sumR); err == nil {
+		c.sumProof, _ = proof.MarshalBinary()
+	}
 }
 
 func (s *System) shareProofData() {
@@ -99,18 +110,18 @@ func (s *System) shareProofData() {
 }
 
 func (u *User) checkCommitment() {
-	var (
-		commit1 *p256.P256
-		commit2 *p256.P256
-	)
-
-	params, _ := bulletproofs.SetupGeneric(0, u.delta)
-	V1, _ := util.CommitG1(big.NewInt(int64(u.reading)-u.delta+bulletproofs.MAX_RANGE_END), u.r, params.BP1.H)
-	V2, _ := util.CommitG1(big.NewInt(int64(u.reading)), u.r, params.BP2.H)
-	_ = json.Unmarshal(u.path.Core[0].C1, &commit1)
-	_ = json.Unmarshal(u.path.Core[0].C2, &commit2)
-
-	check := V1.Equals(commit1) && V2.Equals(commit2)
+	key, err := u.params.Key(1)
+	if err != nil {
+		fmt.Println("check 1 for user", u.idx, "FAILED:", err)
+		return
+	}
+	commit, err := key.Commit(big.NewInt(int64(u.reading)), u.r)
+	if err != nil {
+		fmt.Println("check 1 for user", u.idx, "FAILED:", err)
+		return
+	}
+
+	check := equalCommitments(commit, u.path.Core[0].C)
 	if check {
 		fmt.Println("check 1 for user", u.idx, "succeeded")
 	} else {
@@ -119,55 +130,38 @@ func (u *User) checkCommitment() {
 }
 
 func (u *User) checkSumProof() {
-	var (
-		sumProof bulletproofs.ProofBPRP
-		rootV1   *p256.P256
-		rootV2   *p256.P256
-	)
-
-	p, _ := bulletproofs.SetupGeneric(0, u.delta)
 	root := u.path.Core[len(u.path.Core)-1]
 
-	_ = json.Unmarshal(u.sumProof, &sumProof)
-	_ = json.Unmarshal(root.C1, &rootV1)
-	_ = json.Unmarshal(root.C2, &rootV2)
-
-	//  rootV1 should equal sum(x1) - n * delta + max
-	//  proofV1 should equal sum1 - n * delta + max, so same
-	//  rootV2 should equal sum(x1)
-	//  proofV2 should equal sum1 - gamma
-	//  need dummies to compare
-	dummy := int64(10)
-	eta2, _ := util.CommitG1(big.NewInt(int64(dummy+u.gamma)), big.NewInt(int64(dummy)), p.BP2.H)
-	zeta2, _ := util.CommitG1(big.NewInt(int64(dummy)), big.NewInt(int64(dummy)), p.BP2.H)
-
-	adjProofV2 := new(p256.P256).Add(sumProof.P2.V, eta2)
-	adjRootV2 := new(p256.P256).Add(rootV2, zeta2)
-
 	ok1 := u.nUsers == root.L
 	if !ok1 {
 		fmt.Println("failure in check 2 for user", u.idx, ": #users incorrect")
 	}
 
-	ok2 := sumProof.P1.V.Equals(rootV1)
-	if !ok2 {
-		fmt.Println("failure in check 1 for user", u.idx, ": commitment 1 did not match sum")
-	}
+	// the company can only prove the sum if it belongs to [gamma, nUsers * delta)
+	ok2, ok3 := true, false
+	if len(u.sumProof) != 0 {
+		sumProof, err := u.sumKey.UnmarshalProof(u.sumProof)
+		if err != nil {
+			fmt.Println("check 2 for user", u.idx, "FAILED: invalid sum proof:", err)
+			return
+		}
 
-	ok3 := adjProofV2.Equals(adjRootV2)
-	if !ok3 {
-		fmt.Println("failure in check 2 for user", u.idx, ": commitment 2 did not match sum")
-	}
+		//  the sum proof should commit to the same value as the root
+		ok2 = equalCommitments(sumProof.Commitment(), root.C)
+		if !ok2 {
+			fmt.Println("failure in check 2 for user", u.idx, ": commitment did not match sum")
+		}
 
-	ok4, _ := sumProof.Verify()
-	if !ok4 {
+		ok3, _ = u.sumKey.Verify(sumProof)
+	}
+	if !ok3 {
 		fmt.Println("range proof failed in check 2 for user", u.idx)
 	}
 
-	if ok1 && ok2 && ok3 && ok4 {
+	if ok1 && ok2 && ok3 {
 		fmt.Println("check 2 for user", u.idx, "succeeded, can charge peak rate")
 	} else {
-		if ok1 && ok2 && ok3 {
+		if ok1 && ok2 {
 			fmt.Println("check 2 for user", u.idx, "succeeded, but CANNOT charge peak rate")
 		} else {
 			fmt.Println("check 2 for user", u.idx, "FAILED")
@@ -176,11 +170,11 @@ func (u *User) checkSumProof() {
 }
 
 func (u *User) checkRangeProofs() {
-	ok0 := u.path.VerifyStructure(u.delta)
+	ok0 := u.path.VerifyStructure(u.params)
 	if !ok0 {
 		fmt.Println("failure in check 3 for user", u.idx, " : not a valid path")
 	}
-	ok123 := u.path.VerifyProofs()
+	ok123 := u.path.VerifyProofs(u.params)
 	if !ok123 {
 		fmt.Println("failure in check 3 for user", u.idx, " : proofs failed")
 	}
@@ -208,18 +202,52 @@ func (u *User) checkProofs() {
 	u.checkRangeProofs()
 }
 
-func initialize(n int, gamma int64, delta int64) System {
+// equalCommitments returns true if the commitment is encoded as data
+func equalCommitments(c zkrp.Commitment, data []byte) bool {
+	encoded, err := c.MarshalBinary()
+	return err == nil && bytes.Equal(encoded, data)
+}
+
+// initialize runs the setup of the range proofs of the scheme with the given
+// name on behalf of the users, who keep the verifier keys
+func initialize(scheme string, n int, gamma int64, delta int64) System {
+	params := merkle.NewParams(scheme, delta)
+	sumParams, sumKey, err := zkrp.Setup(scheme, gamma, delta*int64(n))
+	check(err)
 	users := make(map[int]*User)
 	rs := make(map[int]*big.Int)
 	for i := 0; i < n; i++ {
-		r := big.NewInt(int64(rand.Int()))
-		users[i] = &User{gamma, delta, n, i, 0, r, nil, nil}
+		r, err := crand.Int(crand.Reader, maxR)
+		check(err)
+		users[i] = &User{gamma, delta, n, i, 0, r, nil, nil, params, sumKey}
 		rs[i] = r
 	}
-	company := Company{gamma, delta, n, nil, 0, rs, nil, nil}
+	company := Company{gamma, delta, n, nil, 0, rs, nil, nil, params, sumParams}
 	return System{users, company}
 }
 
+// maxR bounds the blinding factors, which are reduced modulo the order of the
+// group of the scheme
+var maxR = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// schemeName returns the name of the scheme given after the arguments of the
+// command, or bulletproofs by default
+func schemeName(i int) string {
+	if len(os.Args) > i {
+		return os.Args[i]
+	}
+	return "bulletproofs"
+}
+
+// checkScheme returns an error if a scheme name is given after the arguments of
+// the command and differs from the scheme stored in user.json
+func checkScheme(i int, stored string) error {
+	if len(os.Args) > i && os.Args[i] != stored {
+		return fmt.Errorf("user.json was created with scheme %q, not %q", stored, os.Args[i])
+	}
+	return nil
+}
+
 func main() {
 	if len(os.Args) < 2 {
 		return
@@ -229,7 +257,8 @@ func main() {
 		startTime := time.Now().UnixNano()
 		nUsers, err := strconv.Atoi(os.Args[2])
 		check(err)
-		system := initialize(nUsers, 400, 120)
+		scheme := schemeName(3)
+		system := initialize(scheme, nUsers, 400, 120)
 		system.drawReadings(100)
 		system.shareReadings()
 		shareReadingsTime := time.Now().UnixNano()
@@ -239,7 +268,12 @@ func main() {
 		fmt.Println("sharing:", float64(shareReadingsTime-startTime)/1000000000, "seconds")
 		fmt.Println("processing:", float64(processReadingsTime-shareReadingsTime)/1000000000, "seconds")
 
+		keys, err := system.users[0].params.VerifierKeys()
+		check(err)
+		sumKey, err := system.users[0].sumKey.MarshalBinary()
+		check(err)
 		uj := UserJSON{
+			Scheme:   scheme,
 			Gamma:    system.users[0].gamma,
 			Delta:    system.users[0].delta,
 			NUsers:   system.users[0].nUsers,
@@ -248,6 +282,8 @@ func main() {
 			R:        system.users[0].r,
 			Path:     system.users[0].path,
 			SumProof: system.users[0].sumProof,
+			Keys:     keys,
+			SumKey:   sumKey,
 		}
 		f, _ := os.Create("user.json")
 		defer f.Close()
@@ -260,6 +296,11 @@ func main() {
 		e := json.NewDecoder(f)
 		e.Decode(&uj)
 
+		check(checkScheme(2, uj.Scheme))
+		params, err := merkle.LoadParams(uj.Scheme, uj.Delta, uj.Keys)
+		check(err)
+		sumKey, err := zkrp.UnmarshalVerifierKey(uj.Scheme, uj.SumKey)
+		check(err)
 		user := User{
 			gamma:    uj.Gamma,
 			delta:    uj.Delta,
@@ -269,6 +310,8 @@ func main() {
 			r:        uj.R,
 			path:     uj.Path,
 			sumProof: uj.SumProof,
+			params:   params,
+			sumKey:   sumKey,
 		}
 		startTime := time.Now().UnixNano()
 		user.checkProofs()
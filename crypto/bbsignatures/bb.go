@@ -33,42 +33,48 @@ import (
     "github.com/ing-bank/zkrp/util/bn"
 )
 
+/*
+Keypair contains the private key x and the public key y = g1^x.
+*/
 type Keypair struct {
     Pubk  *bn256.G1
     Privk *big.Int
 }
 
 /*
-keygen is responsible for the key generation.
+Keygen is responsible for the key generation.
 */
 func Keygen() (Keypair, error) {
     var (
-        kp  Keypair
-        e   error
-        res bool
+        kp Keypair
+        e  error
     )
     kp.Privk, e = rand.Int(rand.Reader, bn256.Order)
     if e != nil {
         return kp, e
     }
-    kp.Pubk, res = new(bn256.G1).Unmarshal(new(bn256.G1).ScalarBaseMult(kp.Privk).Marshal())
-    if res {
+    kp.Pubk, e = new(bn256.G1).Unmarshal(new(bn256.G1).ScalarBaseMult(kp.Privk).Marshal())
+    if e != nil {
         return kp, errors.New("Could not compute scalar multiplication.")
     }
-    return kp, e
+    return kp, nil
 }
 
 /*
-sign receives as input a message and a private key and outputs a digital signature.
+Sign receives as input a message and a private key and outputs a digital signature,
+sig = g2^(1/(x+m)).
 */
 func Sign(m *big.Int, privk *big.Int) (*bn256.G2, error) {
     var (
-        res       bool
+        e         error
         signature *bn256.G2
     )
-    inv := bn.ModInverse(bn.Mod(bn.Add(m, privk), bn256.Order), bn256.Order)
-    signature, res = new(bn256.G2).Unmarshal(new(bn256.G2).ScalarBaseMult(inv).Marshal())
-    if res {
+    inv := new(big.Int).ModInverse(bn.Mod(bn.Add(m, privk), bn256.Order), bn256.Order)
+    if inv == nil {
+        return nil, errors.New("message can not be signed with this key")
+    }
+    signature, e = new(bn256.G2).Unmarshal(new(bn256.G2).ScalarBaseMult(inv).Marshal())
+    if e == nil {
         return signature, nil
     } else {
         return nil, errors.New("Error while computing signature.")
@@ -76,31 +82,64 @@ func Sign(m *big.Int, privk *big.Int) (*bn256.G2, error) {
 }
 
 /*
-verify receives as input the digital signature, the message and the public key. It outputs
-true if and only if the signature is valid.
+Verify receives as input the digital signature, the message and the public key. It outputs
+true if and only if the signature is valid, i.e. e(y.g1^m, sig) = e(g1, g2).
 */
-func verify(signature *bn256.G2, m *big.Int, pubk *bn256.G1) (bool, error) {
-    // e(y.g^m, sig) = e(g1,g2)
-    var (
-        gm     *bn256.G1
-        e, res bool
-    )
-    // g^m
-    gm, e = new(bn256.G1).Unmarshal(new(bn256.G1).ScalarBaseMult(m).Marshal())
+func Verify(signature *bn256.G2, m *big.Int, pubk *bn256.G1) (bool, error) {
+    if signature == nil || m == nil || pubk == nil {
+        return false, errors.New("invalid input")
+    }
     // y.g^m
-    gm = gm.Add(gm, pubk)
-    // e(y.g^m, sig)
-    p1 := bn256.Pair(gm, signature)
-    // e(g1,g2)
-    g1 := new(bn256.G1).ScalarBaseMult(new(big.Int).SetInt64(1))
+    gm := new(bn256.G1).ScalarBaseMult(bn.Mod(m, bn256.Order))
+    gm.Add(gm, pubk)
+    // e(y.g^m, sig).e(g1, g2)^-1 == 1 ?
+    minusG1 := new(bn256.G1).ScalarBaseMult(new(big.Int).Sub(bn256.Order, big.NewInt(1)))
     g2 := new(bn256.G2).ScalarBaseMult(new(big.Int).SetInt64(1))
-    p2 := bn256.Pair(g1, g2)
-    // p1 == p2?
-    p2 = p2.Neg(p2)
-    p1 = p1.Add(p1, p2)
-    res = p1.IsOne()
-    if e {
-        return res, nil
+    if gm.IsZero() || signature.IsZero() {
+        return false, nil
+    }
+    return bn256.PairingCheck([]*bn256.G1{gm, minusG1}, []*bn256.G2{signature, g2}), nil
+}
+
+/*
+BatchVerify checks many signatures under the same public key. The equations
+e(y.g1^m_i, sig_i) = e(g1, g2) are combined using random 128-bit coefficients d_i:
+    prod e(d_i.(y.g1^m_i), sig_i) . e(g1, g2)^(-sum d_i) == 1
+which is computed with one Miller loop per signature and a single final
+exponentiation. A batch containing an invalid signature is accepted with
+probability at most 2^-128.
+*/
+func BatchVerify(signatures []*bn256.G2, messages []*big.Int, pubk *bn256.G1) (bool, error) {
+    if len(signatures) != len(messages) {
+        return false, errors.New("the number of signatures and messages must be equal")
+    }
+    if pubk == nil {
+        return false, errors.New("invalid public key")
+    }
+    bound := new(big.Int).Lsh(big.NewInt(1), 128)
+    sum := new(big.Int)
+    a := make([]*bn256.G1, 0, len(signatures)+1)
+    b := make([]*bn256.G2, 0, len(signatures)+1)
+    for i, signature := range signatures {
+        if signature == nil || messages[i] == nil {
+            return false, errors.New("invalid input")
+        }
+        d, err := rand.Int(rand.Reader, bound)
+        if err != nil {
+            return false, err
+        }
+        d.Add(d, big.NewInt(1))
+        sum.Add(sum, d)
+        // d_i.(y.g^m_i)
+        gm := new(bn256.G1).ScalarBaseMult(bn.Mod(messages[i], bn256.Order))
+        gm.Add(gm, pubk)
+        if gm.IsZero() || signature.IsZero() {
+            return false, nil
+        }
+        a = append(a, gm.ScalarMult(gm, d))
+        b = append(b, signature)
     }
-    return false, errors.New("Error while computing signature.")
+    a = append(a, new(bn256.G1).ScalarBaseMult(bn.Sub(bn256.Order, bn.Mod(sum, bn256.Order))))
+    b = append(b, new(bn256.G2).ScalarBaseMult(new(big.Int).SetInt64(1)))
+    return bn256.PairingCheck(a, b), nil
 }
@@ -18,16 +18,75 @@
 package bbsignatures
 
 import (
+    "bytes"
     "math/big"
     "testing"
+
+    "github.com/ing-bank/zkrp/crypto/bn256"
 )
 
 func TestKeyGen(t *testing.T) {
     kp, _ := Keygen()
     signature, _ := Sign(big.NewInt(42), kp.Privk)
-    res, _ := verify(signature, big.NewInt(42), kp.Pubk)
+    res, _ := Verify(signature, big.NewInt(42), kp.Pubk)
     if res != true {
         t.Errorf("Assert failure: expected true, actual: %t", res)
         t.Fail()
     }
 }
+
+func TestVerifyWrongMessage(t *testing.T) {
+    kp, err := Keygen()
+    if err != nil {
+        t.Fatalf("Keygen error: %s", err)
+    }
+    signature, _ := Sign(big.NewInt(42), kp.Privk)
+    res, _ := Verify(signature, big.NewInt(43), kp.Pubk)
+    if res != false {
+        t.Errorf("Assert failure: expected false, actual: %t", res)
+    }
+}
+
+func TestBatchVerify(t *testing.T) {
+    kp, _ := Keygen()
+    signatures := make([]*bn256.G2, 10)
+    messages := make([]*big.Int, 10)
+    for i := range signatures {
+        messages[i] = big.NewInt(int64(i))
+        signatures[i], _ = Sign(messages[i], kp.Privk)
+    }
+    res, _ := BatchVerify(signatures, messages, kp.Pubk)
+    if res != true {
+        t.Errorf("Assert failure: expected true, actual: %t", res)
+    }
+    signatures[3], signatures[4] = signatures[4], signatures[3]
+    res, _ = BatchVerify(signatures, messages, kp.Pubk)
+    if res != false {
+        t.Errorf("Assert failure: expected false, actual: %t", res)
+    }
+}
+
+func TestEncodeDecode(t *testing.T) {
+    kp, _ := Keygen()
+    data, _ := kp.MarshalBinary()
+    var decoded Keypair
+    if err := decoded.UnmarshalBinary(data); err != nil {
+        t.Fatalf("decode error: %s", err)
+    }
+    pubkData, _ := MarshalPublicKey(kp.Pubk)
+    pubk, err := UnmarshalPublicKey(pubkData)
+    if err != nil || !bytes.Equal(pubk.Marshal(), decoded.Pubk.Marshal()) {
+        t.Errorf("Assert failure: public keys should be equal")
+    }
+
+    signature, _ := Sign(big.NewInt(42), decoded.Privk)
+    sigData, _ := MarshalSignature(signature)
+    signature, err = UnmarshalSignature(sigData)
+    if err != nil {
+        t.Fatalf("decode error: %s", err)
+    }
+    res, _ := Verify(signature, big.NewInt(42), pubk)
+    if res != true {
+        t.Errorf("Assert failure: expected true, actual: %t", res)
+    }
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bbsignatures
+
+import (
+    "errors"
+    "math/big"
+
+    "github.com/ing-bank/zkrp/crypto/bn256"
+)
+
+const (
+    // PublicKeySize is the length in bytes of an encoded public key.
+    PublicKeySize = 64
+    // SignatureSize is the length in bytes of an encoded signature.
+    SignatureSize = 128
+    // PrivateKeySize is the length in bytes of an encoded private key.
+    PrivateKeySize = 32
+)
+
+/*
+MarshalPublicKey encodes the public key using bn256.G1.Marshal.
+*/
+func MarshalPublicKey(pubk *bn256.G1) ([]byte, error) {
+    if pubk == nil {
+        return nil, errors.New("can not encode nil public key")
+    }
+    return pubk.Marshal(), nil
+}
+
+/*
+UnmarshalPublicKey decodes a public key encoded with MarshalPublicKey. The point at
+infinity is rejected with bn256.ErrInfinity.
+*/
+func UnmarshalPublicKey(data []byte) (*bn256.G1, error) {
+    pubk, err := new(bn256.G1).Unmarshal(data)
+    if err != nil {
+        return nil, err
+    }
+    if pubk.IsZero() {
+        return nil, bn256.ErrInfinity
+    }
+    return pubk, nil
+}
+
+/*
+MarshalSignature encodes the signature using bn256.G2.Marshal.
+*/
+func MarshalSignature(signature *bn256.G2) ([]byte, error) {
+    if signature == nil {
+        return nil, errors.New("can not encode nil signature")
+    }
+    return signature.Marshal(), nil
+}
+
+/*
+UnmarshalSignature decodes a signature encoded with MarshalSignature. The point at
+infinity is rejected with bn256.ErrInfinity.
+*/
+func UnmarshalSignature(data []byte) (*bn256.G2, error) {
+    signature, err := new(bn256.G2).Unmarshal(data)
+    if err != nil {
+        return nil, err
+    }
+    if signature.IsZero() {
+        return nil, bn256.ErrInfinity
+    }
+    return signature, nil
+}
+
+/*
+MarshalBinary encodes the private key as 32 bytes in big-endian order. The public
+key is recomputed by UnmarshalBinary.
+*/
+func (kp *Keypair) MarshalBinary() ([]byte, error) {
+    if kp.Privk == nil || kp.Privk.Sign() <= 0 || kp.Privk.Cmp(bn256.Order) >= 0 {
+        return nil, errors.New("invalid private key")
+    }
+    data := make([]byte, PrivateKeySize)
+    privk := kp.Privk.Bytes()
+    copy(data[PrivateKeySize-len(privk):], privk)
+    return data, nil
+}
+
+/*
+UnmarshalBinary decodes a key pair encoded with MarshalBinary.
+*/
+func (kp *Keypair) UnmarshalBinary(data []byte) error {
+    if len(data) != PrivateKeySize {
+        return errors.New("invalid private key length")
+    }
+    privk := new(big.Int).SetBytes(data)
+    if privk.Sign() == 0 || privk.Cmp(bn256.Order) >= 0 {
+        return errors.New("invalid private key")
+    }
+    kp.Privk = privk
+    kp.Pubk = new(bn256.G1).ScalarBaseMult(privk)
+    return nil
+}
@@ -31,10 +31,24 @@ package bn256
 
 import (
     "crypto/rand"
+    "errors"
     "io"
     "math/big"
 )
 
+/*
+Errors returned when decoding untrusted group elements. Coordinates must be reduced
+modulo P, points must satisfy the curve equation, and the points of G2 and the
+elements of GT must belong to the subgroup of order Order. The cofactor of G1 is 1.
+*/
+var (
+    ErrInvalidEncoding = errors.New("bn256: invalid encoding")
+    ErrNotOnCurve      = errors.New("bn256: point is not on the curve")
+    ErrNotInSubgroup   = errors.New("bn256: element is not in the subgroup of order Order")
+    // ErrInfinity is returned by callers that do not accept the point at infinity.
+    ErrInfinity = errors.New("bn256: unexpected point at infinity")
+)
+
 /*
 Comments:
 - This implementation is not constant time, which means that it is vulnerable
@@ -158,38 +172,41 @@ func (n *G1) Marshal() []byte {
 }
 
 // Unmarshal sets e to the result of converting the output of Marshal back into
-// a group element and then returns e.
-func (e *G1) Unmarshal(m []byte) (*G1, bool) {
+// a group element and then returns e. It returns ErrInvalidEncoding or ErrNotOnCurve
+// if m is not the encoding of a point of G1.
+func (e *G1) Unmarshal(m []byte) (*G1, error) {
     // Each value is a 256-bit number.
     const numBytes = 256 / 8
 
     if len(m) != 2*numBytes {
-        return nil, false
+        return nil, ErrInvalidEncoding
     }
 
-    if e.p == nil {
-        e.p = newCurvePoint(nil)
+    p := newCurvePoint(nil)
+    var ok1, ok2 bool
+    if p.x, ok1 = readCoordinate(m[0*numBytes : 1*numBytes]); !ok1 {
+        return nil, ErrInvalidEncoding
+    }
+    if p.y, ok2 = readCoordinate(m[1*numBytes : 2*numBytes]); !ok2 {
+        return nil, ErrInvalidEncoding
     }
 
-    e.p.x.SetBytes(m[0*numBytes : 1*numBytes])
-    e.p.y.SetBytes(m[1*numBytes : 2*numBytes])
-
-    if (e.p.x.Sign() == 0 && e.p.y.Sign() == 0) ||
-        (e.p.x.String() == "0" && e.p.y.String() == "1") {
+    if p.x.Sign() == 0 && (p.y.Sign() == 0 || p.y.Cmp(big.NewInt(1)) == 0) {
         // This is the point at infinity.
-        e.p.y.SetInt64(1)
-        e.p.z.SetInt64(0)
-        e.p.t.SetInt64(0)
+        p.y.SetInt64(1)
+        p.z.SetInt64(0)
+        p.t.SetInt64(0)
     } else {
-        e.p.z.SetInt64(1)
-        e.p.t.SetInt64(1)
+        p.z.SetInt64(1)
+        p.t.SetInt64(1)
 
-        if !e.p.IsOnCurve() {
-            return nil, false
+        if !p.IsOnCurve() {
+            return nil, ErrNotOnCurve
         }
     }
 
-    return e, true
+    e.p = p
+    return e, nil
 }
 
 // G2 is an abstract cyclic group. The zero value is suitable for use as the
@@ -291,8 +308,14 @@ func (e *G2) Neg(a *G2) *G2 {
     return e
 }
 
-// Marshal converts n into a byte slice.
+// Marshal converts n into a byte slice. The point at infinity is encoded as zeros.
 func (n *G2) Marshal() []byte {
+    // Each value is a 256-bit number.
+    const numBytes = 256 / 8
+
+    if n.p.IsInfinity() {
+        return make([]byte, numBytes*4)
+    }
     n.p.MakeAffine(nil)
 
     xxBytes := new(big.Int).Mod(n.p.x.x, P).Bytes()
@@ -300,9 +323,6 @@ func (n *G2) Marshal() []byte {
     yxBytes := new(big.Int).Mod(n.p.y.x, P).Bytes()
     yyBytes := new(big.Int).Mod(n.p.y.y, P).Bytes()
 
-    // Each value is a 256-bit number.
-    const numBytes = 256 / 8
-
     ret := make([]byte, numBytes*4)
     copy(ret[1*numBytes-len(xxBytes):], xxBytes)
     copy(ret[2*numBytes-len(xyBytes):], xyBytes)
@@ -313,42 +333,43 @@ func (n *G2) Marshal() []byte {
 }
 
 // Unmarshal sets e to the result of converting the output of Marshal back into
-// a group element and then returns e.
-func (e *G2) Unmarshal(m []byte) (*G2, bool) {
+// a group element and then returns e. It returns ErrInvalidEncoding, ErrNotOnCurve or
+// ErrNotInSubgroup if m is not the encoding of a point of G2.
+func (e *G2) Unmarshal(m []byte) (*G2, error) {
     // Each value is a 256-bit number.
     const numBytes = 256 / 8
 
     if len(m) != 4*numBytes {
-        return nil, false
+        return nil, ErrInvalidEncoding
     }
 
-    if e.p == nil {
-        e.p = newTwistPoint(nil)
+    p := newTwistPoint(nil)
+    for i, k := range []**big.Int{&p.x.x, &p.x.y, &p.y.x, &p.y.y} {
+        var ok bool
+        if *k, ok = readCoordinate(m[i*numBytes : (i+1)*numBytes]); !ok {
+            return nil, ErrInvalidEncoding
+        }
     }
 
-    e.p.x.x.SetBytes(m[0*numBytes : 1*numBytes])
-    e.p.x.y.SetBytes(m[1*numBytes : 2*numBytes])
-    e.p.y.x.SetBytes(m[2*numBytes : 3*numBytes])
-    e.p.y.y.SetBytes(m[3*numBytes : 4*numBytes])
-
-    if e.p.x.x.Sign() == 0 &&
-        e.p.x.y.Sign() == 0 &&
-        e.p.y.x.Sign() == 0 &&
-        e.p.y.y.Sign() == 0 {
+    if p.x.IsZero() && p.y.IsZero() {
         // This is the point at infinity.
-        e.p.y.SetOne()
-        e.p.z.SetZero()
-        e.p.t.SetZero()
+        p.y.SetOne()
+        p.z.SetZero()
+        p.t.SetZero()
     } else {
-        e.p.z.SetOne()
-        e.p.t.SetOne()
+        p.z.SetOne()
+        p.t.SetOne()
 
-        if !e.p.IsOnCurve() {
-            return nil, false
+        if !p.IsOnCurve() {
+            return nil, ErrNotOnCurve
+        }
+        if !p.IsInSubgroup() {
+            return nil, ErrNotInSubgroup
         }
     }
 
-    return e, true
+    e.p = p
+    return e, nil
 }
 
 // GT is an abstract cyclic group. The zero value is suitable for use as the
@@ -469,33 +490,55 @@ func (n *GT) Marshal() []byte {
 }
 
 // Unmarshal sets e to the result of converting the output of Marshal back into
-// a group element and then returns e.
-func (e *GT) Unmarshal(m []byte) (*GT, bool) {
+// a group element and then returns e. It returns ErrInvalidEncoding or ErrNotInSubgroup
+// if m is not the encoding of an element of GT. The subgroup check costs an
+// exponentiation by a 127-bit integer, i.e. about half of a pairing, so the elements
+// of GT should only be decoded where they are needed, e.g. the bbsignatures package
+// only decodes points of G₁ and G₂.
+func (e *GT) Unmarshal(m []byte) (*GT, error) {
     // Each value is a 256-bit number.
     const numBytes = 256 / 8
 
     if len(m) != 12*numBytes {
-        return nil, false
+        return nil, ErrInvalidEncoding
     }
 
-    if e.p == nil {
-        e.p = newGFp12(nil)
+    p := newGFp12(nil)
+    coordinates := []**big.Int{
+        &p.x.x.x, &p.x.x.y, &p.x.y.x, &p.x.y.y, &p.x.z.x, &p.x.z.y,
+        &p.y.x.x, &p.y.x.y, &p.y.y.x, &p.y.y.y, &p.y.z.x, &p.y.z.y,
+    }
+    for i, k := range coordinates {
+        var ok bool
+        if *k, ok = readCoordinate(m[i*numBytes : (i+1)*numBytes]); !ok {
+            return nil, ErrInvalidEncoding
+        }
     }
 
-    e.p.x.x.x.SetBytes(m[0*numBytes : 1*numBytes])
-    e.p.x.x.y.SetBytes(m[1*numBytes : 2*numBytes])
-    e.p.x.y.x.SetBytes(m[2*numBytes : 3*numBytes])
-    e.p.x.y.y.SetBytes(m[3*numBytes : 4*numBytes])
-    e.p.x.z.x.SetBytes(m[4*numBytes : 5*numBytes])
-    e.p.x.z.y.SetBytes(m[5*numBytes : 6*numBytes])
-    e.p.y.x.x.SetBytes(m[6*numBytes : 7*numBytes])
-    e.p.y.x.y.SetBytes(m[7*numBytes : 8*numBytes])
-    e.p.y.y.x.SetBytes(m[8*numBytes : 9*numBytes])
-    e.p.y.y.y.SetBytes(m[9*numBytes : 10*numBytes])
-    e.p.y.z.x.SetBytes(m[10*numBytes : 11*numBytes])
-    e.p.y.z.y.SetBytes(m[11*numBytes : 12*numBytes])
+    if !inGT(p, new(bnPool)) {
+        return nil, ErrNotInSubgroup
+    }
 
-    return e, true
+    e.p = p
+    return e, nil
+}
+
+// inGT returns true if a belongs to GT, i.e. if a ≠ 0 and a^Order = 1. Since
+// Order = P - 6u², this holds if and only if a^P = a^(6u²), which only needs the
+// Frobenius map and an exponent of 127 bits, instead of the 254 bits of Order.
+func inGT(a *gfP12, pool *bnPool) bool {
+    if a.IsZero() {
+        return false
+    }
+    lhs := newGFp12(pool).Frobenius(a, pool)
+    rhs := newGFp12(pool).Exp(a, sixuSquared, pool)
+    rhs.Invert(rhs, pool)
+    t := newGFp12(pool).Mul(lhs, rhs, pool)
+    ok := t.IsOne()
+    lhs.Put(pool)
+    rhs.Put(pool)
+    t.Put(pool)
+    return ok
 }
 
 // Pair calculates an Optimal Ate pairing.
@@ -503,10 +546,34 @@ func Pair(g1 *G1, g2 *G2) *GT {
     return &GT{optimalAte(g2.p, g1.p, new(bnPool))}
 }
 
+// PairBatch calculates the product of the Optimal Ate pairings e(g1s[i], g2s[i]).
+// The Miller loops are multiplied together and a single final exponentiation is
+// computed, which is much faster than multiplying the results of Pair. It panics
+// if g1s and g2s do not have the same length.
+func PairBatch(g1s []*G1, g2s []*G2) *GT {
+    if len(g1s) != len(g2s) {
+        panic("bn256: number of G1 and G2 points must be equal")
+    }
+    pool := new(bnPool)
+    acc := multiMiller(g1s, g2s, pool)
+    ret := finalExponentiation(acc, pool)
+    acc.Put(pool)
+    return &GT{ret}
+}
+
 // PairingCheck calculates the Optimal Ate pairing for a set of points.
 func PairingCheck(a []*G1, b []*G2) bool {
     pool := new(bnPool)
+    acc := multiMiller(a, b, pool)
+    ret := finalExponentiation(acc, pool)
+    acc.Put(pool)
 
+    return ret.IsOne()
+}
+
+// multiMiller returns the product of the Miller loops of the pairs (a[i], b[i]),
+// skipping the pairs that contain the point at infinity.
+func multiMiller(a []*G1, b []*G2, pool *bnPool) *gfP12 {
     acc := newGFp12(pool)
     acc.SetOne()
 
@@ -514,12 +581,11 @@ func PairingCheck(a []*G1, b []*G2) bool {
         if a[i].p.IsInfinity() || b[i].p.IsInfinity() {
             continue
         }
-        acc.Mul(acc, miller(b[i].p, a[i].p, pool), pool)
+        e := miller(b[i].p, a[i].p, pool)
+        acc.Mul(acc, e, pool)
+        e.Put(pool)
     }
-    ret := finalExponentiation(acc, pool)
-    acc.Put(pool)
-
-    return ret.IsOne()
+    return acc
 }
 
 // bnPool implements a tiny cache of *big.Int objects that's used to reduce the
@@ -20,6 +20,7 @@ package bn256
 import (
     "bytes"
     "crypto/rand"
+    "encoding/hex"
     "math/big"
     "testing"
 )
@@ -232,15 +233,15 @@ func TestBilinearity(t *testing.T) {
 func TestG1Marshal(t *testing.T) {
     g := new(G1).ScalarBaseMult(new(big.Int).SetInt64(1))
     form := g.Marshal()
-    _, ok := new(G1).Unmarshal(form)
-    if !ok {
+    _, err := new(G1).Unmarshal(form)
+    if err != nil {
         t.Fatalf("failed to unmarshal")
     }
 
     g.ScalarBaseMult(Order)
     form = g.Marshal()
-    g2, ok := new(G1).Unmarshal(form)
-    if !ok {
+    g2, err := new(G1).Unmarshal(form)
+    if err != nil {
         t.Fatalf("failed to unmarshal ∞")
     }
     if !g2.p.IsInfinity() {
@@ -251,20 +252,30 @@ func TestG1Marshal(t *testing.T) {
 func TestG2Marshal(t *testing.T) {
     g := new(G2).ScalarBaseMult(new(big.Int).SetInt64(1))
     form := g.Marshal()
-    _, ok := new(G2).Unmarshal(form)
-    if !ok {
+    _, err := new(G2).Unmarshal(form)
+    if err != nil {
         t.Fatalf("failed to unmarshal")
     }
 
     g.ScalarBaseMult(Order)
     form = g.Marshal()
-    g2, ok := new(G2).Unmarshal(form)
-    if !ok {
+    g2, err := new(G2).Unmarshal(form)
+    if err != nil {
         t.Fatalf("failed to unmarshal ∞")
     }
     if !g2.p.IsInfinity() {
         t.Fatalf("∞ unmarshaled incorrectly")
     }
+
+    g = new(G2).SetInfinity()
+    form = g.Marshal()
+    if !g.p.IsInfinity() {
+        t.Fatalf("∞ modified by Marshal")
+    }
+    g2, err = new(G2).Unmarshal(form)
+    if err != nil || !g2.p.IsInfinity() {
+        t.Fatalf("∞ unmarshaled incorrectly")
+    }
 }
 
 func TestG1Identity(t *testing.T) {
@@ -310,8 +321,284 @@ func TestTripartiteDiffieHellman(t *testing.T) {
     }
 }
 
+func TestPairBatch(t *testing.T) {
+    a, _ := rand.Int(rand.Reader, Order)
+    b, _ := rand.Int(rand.Reader, Order)
+    c, _ := rand.Int(rand.Reader, Order)
+
+    pa := new(G1).ScalarBaseMult(a)
+    pb := new(G1).ScalarBaseMult(b)
+    qb := new(G2).ScalarBaseMult(b)
+    qc := new(G2).ScalarBaseMult(c)
+
+    expected := new(GT).Add(Pair(pa, qb), Pair(pb, qc))
+    expected.Add(expected, Pair(pa, new(G2).SetInfinity()))
+    e := PairBatch([]*G1{pa, pb, pa}, []*G2{qb, qc, new(G2).SetInfinity()})
+    if !bytes.Equal(e.Marshal(), expected.Marshal()) {
+        t.Errorf("PairBatch should be equal to the product of the pairings")
+    }
+    if !PairBatch(nil, nil).IsOne() {
+        t.Errorf("empty PairBatch should be equal to one")
+    }
+}
+
+func TestCompressed(t *testing.T) {
+    for i := 0; i < 10; i++ {
+        k, _ := rand.Int(rand.Reader, Order)
+        a := new(G1).ScalarBaseMult(k)
+        b := new(G2).ScalarBaseMult(k)
+        for _, n := range []*big.Int{k, new(big.Int).Neg(k)} {
+            a.ScalarBaseMult(n)
+            b.ScalarBaseMult(n)
+            a2, err := new(G1).UnmarshalCompressed(a.MarshalCompressed())
+            if err != nil || !bytes.Equal(a2.Marshal(), a.Marshal()) {
+                t.Fatalf("G1 compressed encoding failed for %d", n)
+            }
+            b2, err := new(G2).UnmarshalCompressed(b.MarshalCompressed())
+            if err != nil || !bytes.Equal(b2.Marshal(), b.Marshal()) {
+                t.Fatalf("G2 compressed encoding failed for %d", n)
+            }
+        }
+    }
+
+    a, err := new(G1).UnmarshalCompressed(new(G1).SetInfinity().MarshalCompressed())
+    if err != nil || !a.IsZero() {
+        t.Errorf("G1 point at infinity should be decoded")
+    }
+    b, err := new(G2).UnmarshalCompressed(new(G2).SetInfinity().MarshalCompressed())
+    if err != nil || !b.IsZero() {
+        t.Errorf("G2 point at infinity should be decoded")
+    }
+
+    m := new(G1).ScalarBaseMult(big.NewInt(7)).MarshalCompressed()
+    m[0] = 0x04
+    if _, err := new(G1).UnmarshalCompressed(m); err != ErrInvalidEncoding {
+        t.Errorf("invalid flag should be rejected")
+    }
+    m = make([]byte, G1CompressedSize)
+    m[0] = flagEven
+    copy(m[1:], P.Bytes())
+    if _, err := new(G1).UnmarshalCompressed(m); err != ErrInvalidEncoding {
+        t.Errorf("coordinate equal to P should be rejected")
+    }
+}
+
+func TestCompressedG2Subgroup(t *testing.T) {
+    // a point of the twist which is not multiplied by the cofactor
+    for i := int64(1); ; i++ {
+        x := fieldElement(i)
+        y, ok := recoverY(g2Map, x, 0)
+        if !ok {
+            continue
+        }
+        p := &twistPoint{x, y, fieldElement(1), fieldElement(1)}
+        m := (&G2{p}).MarshalCompressed()
+        if _, err := new(G2).UnmarshalCompressed(m); err != ErrNotInSubgroup {
+            t.Errorf("point outside of the subgroup should be rejected")
+        }
+        if _, err := new(G2).Unmarshal((&G2{p}).Marshal()); err != ErrNotInSubgroup {
+            t.Errorf("point outside of the subgroup should be rejected")
+        }
+        p.Mul(p, g2Cofactor, nil)
+        if _, err := new(G2).UnmarshalCompressed((&G2{p}).MarshalCompressed()); err != nil {
+            t.Errorf("point of the subgroup should be accepted")
+        }
+        return
+    }
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+    m := make([]byte, 64)
+    m[31], m[63] = 1, 1
+    if _, err := new(G1).Unmarshal(m); err != ErrNotOnCurve {
+        t.Errorf("point (1, 1) should be rejected, got %v", err)
+    }
+    m = new(G1).ScalarBaseMult(big.NewInt(3)).Marshal()
+    copy(m[:32], P.Bytes())
+    if _, err := new(G1).Unmarshal(m); err != ErrInvalidEncoding {
+        t.Errorf("coordinate equal to P should be rejected, got %v", err)
+    }
+
+    gt := Pair(&G1{curveGen}, &G2{twistGen})
+    m = gt.Marshal()
+    if _, err := new(GT).Unmarshal(m); err != nil {
+        t.Errorf("element of GT should be accepted, got %v", err)
+    }
+    m[len(m)-1]++
+    if _, err := new(GT).Unmarshal(m); err != ErrNotInSubgroup {
+        t.Errorf("element outside of GT should be rejected, got %v", err)
+    }
+    if _, err := new(GT).Unmarshal(m[1:]); err != ErrInvalidEncoding {
+        t.Errorf("truncated element should be rejected, got %v", err)
+    }
+    if _, err := new(GT).Unmarshal(make([]byte, len(m))); err != ErrNotInSubgroup {
+        t.Errorf("zero should be rejected, got %v", err)
+    }
+}
+
+func TestInGT(t *testing.T) {
+    pool := new(bnPool)
+    gt := Pair(&G1{curveGen}, &G2{twistGen})
+    m := gt.Marshal()
+    m[len(m)-1]++
+    f := newGFp12(nil)
+    coordinates := []**big.Int{
+        &f.x.x.x, &f.x.x.y, &f.x.y.x, &f.x.y.y, &f.x.z.x, &f.x.z.y,
+        &f.y.x.x, &f.y.x.y, &f.y.y.x, &f.y.y.y, &f.y.z.x, &f.y.z.y,
+    }
+    for i, k := range coordinates {
+        *k = new(big.Int).SetBytes(m[i*32 : (i+1)*32])
+    }
+    // f^((p⁶-1)(p²+1)) belongs to the cyclotomic subgroup, which is larger than GT
+    p2 := new(big.Int).Mul(P, P)
+    p6 := new(big.Int).Exp(P, big.NewInt(6), nil)
+    cyclotomic := new(big.Int).Mul(new(big.Int).Sub(p6, big.NewInt(1)), new(big.Int).Add(p2, big.NewInt(1)))
+    elements := []*gfP12{gt.p, f, newGFp12(nil).Exp(f, cyclotomic, pool), newGFp12(nil).SetOne()}
+    for i, e := range elements {
+        expected := newGFp12(nil).Exp(e, Order, pool).IsOne()
+        if inGT(e, pool) != expected {
+            t.Errorf("membership of element %d should be %v", i, expected)
+        }
+    }
+}
+
+func BenchmarkUnmarshalGT(b *testing.B) {
+    m := Pair(&G1{curveGen}, &G2{twistGen}).Marshal()
+    for i := 0; i < b.N; i++ {
+        if _, err := new(GT).Unmarshal(m); err != nil {
+            b.Fatal(err)
+        }
+    }
+}
+
+func BenchmarkPairBatch(b *testing.B) {
+    g1s := []*G1{{curveGen}, {curveGen}, {curveGen}}
+    g2s := []*G2{{twistGen}, {twistGen}, {twistGen}}
+    for i := 0; i < b.N; i++ {
+        PairBatch(g1s, g2s)
+    }
+}
+
 func BenchmarkPairing(b *testing.B) {
     for i := 0; i < b.N; i++ {
         Pair(&G1{curveGen}, &G2{twistGen})
     }
 }
+
+func TestHashToG1(t *testing.T) {
+    dst := []byte("QUUX-V01-CS02-with-" + HashToG1Suite)
+    a, err := HashToG1(dst, []byte("abc"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !a.p.IsOnCurve() {
+        t.Errorf("Assert failure: hash is not on the curve")
+    }
+    b, _ := HashToG1(dst, []byte("abc"))
+    if !bytes.Equal(a.Marshal(), b.Marshal()) {
+        t.Errorf("Assert failure: hash should be deterministic")
+    }
+    c, _ := HashToG1(dst, []byte(""))
+    if bytes.Equal(a.Marshal(), c.Marshal()) {
+        t.Errorf("Assert failure: different messages should be hashed to different points")
+    }
+}
+
+func TestHashToG2(t *testing.T) {
+    dst := []byte("QUUX-V01-CS02-with-" + HashToG2Suite)
+    a, err := HashToG2(dst, []byte("abc"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !a.p.IsOnCurve() {
+        t.Errorf("Assert failure: hash is not on the twist")
+    }
+    if !new(G2).ScalarMult(a, Order).IsZero() {
+        t.Errorf("Assert failure: hash is not in G2")
+    }
+    b, _ := HashToG2(dst, []byte("abc"))
+    if !bytes.Equal(a.Marshal(), b.Marshal()) {
+        t.Errorf("Assert failure: hash should be deterministic")
+    }
+    c, _ := HashToG2(dst, []byte(""))
+    if bytes.Equal(a.Marshal(), c.Marshal()) {
+        t.Errorf("Assert failure: different messages should be hashed to different points")
+    }
+}
+
+/*
+The vectors are the ones of the suites BN254G1_XMD:SHA-256_SVDW_RO_ and
+BN254G2_XMD:SHA-256_SVDW_RO_ published by gnark-crypto v0.13.0, in
+ecc/bn254/hash_vectors_test.go, written as the Marshal encodings of the points.
+*/
+func TestHashToG1Vectors(t *testing.T) {
+    dst := []byte("QUUX-V01-CS02-with-" + HashToG1Suite)
+    vectors := []struct {
+        msg      string
+        expected string
+    }{
+        {"", "0a976ab906170db1f9638d376514dbf8c42aef256a54bbd48521f20749e59e86" +
+            "02925ead66b9e68bfc309b014398640ab55f6619ab59bc1fab2210ad4c4d53d5"},
+        {"abc", "23f717bee89b1003957139f193e6be7da1df5f1374b26a4643b0378b5baf53d1" +
+            "04142f826b71ee574452dbc47e05bc3e1a647478403a7ba38b7b93948f4e151d"},
+        {"abcdef0123456789", "187dbf1c3c89aceceef254d6548d7163fdfa43084145f92c4c91c85c21442d4a" +
+            "0abd99d5b0000910b56058f9cc3b0ab0a22d47cf27615f588924fac1e5c63b4d"},
+    }
+    for _, v := range vectors {
+        h, err := HashToG1(dst, []byte(v.msg))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if hex.EncodeToString(h.Marshal()) != v.expected {
+            t.Errorf("Assert failure: HashToG1(%q) = %x, expected %s", v.msg, h.Marshal(), v.expected)
+        }
+    }
+}
+
+func TestHashToG2Vectors(t *testing.T) {
+    dst := []byte("QUUX-V01-CS02-with-" + HashToG2Suite)
+    vectors := []struct {
+        msg      string
+        expected string
+    }{
+        {"", "1747d950a6f23c16156e2171bce95d1189b04148ad12628869ed21c96a8c9335" +
+            "1192005a0f121921a6d5629946199e4b27ff8ee4d6dd4f9581dc550ade851300" +
+            "2c9755350ca363ef2cf541005437221c5740086c2e909b71d075152484e845f4" +
+            "0498f6bb5ac309a07d9a8b88e6ff4b8de0d5f27a075830e1eb0e68ea318201d8"},
+        {"abc", "0b5db3ca7e8ef5edf3a33dfc3242357fbccead98099c3eb564b3d9d13cba4efd" +
+            "16c88b54eec9af86a41569608cd0f60aab43464e52ce7e6e298bf584b94fccd2" +
+            "22d02d2da7f288545ff8789e789902245ab08c6b1d253561eec789ec2c1bd630" +
+            "1c42ba524cb74db8e2c680449746c028f7bea923f245e69f89256af2d6c5f3ac"},
+        {"abcdef0123456789", "2a8a360585b6b05996ef69c3c09b2c6fb17afe2b1e944f07559c53178eabf171" +
+            "1435fd84aa43c699230e371f6fea3545ce7e053cbbb06a320296a2b81efddc70" +
+            "142f08e2441ec431defc24621b73cfe0252d19b243cb55b84bdeb85de039207a" +
+            "2820188dcdc13ffdca31694942418afa1d6dfaaf259d012fab4da52b0f592e38"},
+    }
+    for _, v := range vectors {
+        h, err := HashToG2(dst, []byte(v.msg))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if hex.EncodeToString(h.Marshal()) != v.expected {
+            t.Errorf("Assert failure: HashToG2(%q) = %x, expected %s", v.msg, h.Marshal(), v.expected)
+        }
+    }
+}
+
+func TestSVDWSqrt(t *testing.T) {
+    for _, m := range []*svdwMap{g1Map, g2Map} {
+        for k := int64(1); k < 20; k++ {
+            a := m.f.mul(fieldElement(k), fieldElement(k+1))
+            if !m.f.isSquare(a) {
+                continue
+            }
+            r := m.f.sqrt(a)
+            if !m.f.mul(r, r).Equals(a) {
+                t.Errorf("Assert failure: wrong square root of %s", a)
+            }
+        }
+        if !m.f.isSquare(m.g(m.c2)) && !m.f.isSquare(m.g(m.Z)) {
+            t.Errorf("Assert failure: invalid Z")
+        }
+    }
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bn256
+
+import (
+    "math/big"
+)
+
+/*
+The compressed encodings contain a flag byte followed by the x coordinate of the
+point in affine form. The flag is 0x02 + sgn0(y), where sgn0 is the sign defined in
+RFC 9380, and y is recovered from the curve equation when decoding. The point at
+infinity is encoded with a flag and coordinate equal to zero.
+*/
+const (
+    // G1CompressedSize is the length in bytes of MarshalCompressed for G1.
+    G1CompressedSize = 1 + numBytes
+    // G2CompressedSize is the length in bytes of MarshalCompressed for G2.
+    G2CompressedSize = 1 + 2*numBytes
+)
+
+// Each coordinate is a 256-bit number.
+const numBytes = 256 / 8
+
+const (
+    flagInfinity = 0x00
+    flagEven     = 0x02
+    flagOdd      = 0x03
+)
+
+func putCoordinate(dst []byte, k *big.Int) {
+    kBytes := new(big.Int).Mod(k, P).Bytes()
+    copy(dst[numBytes-len(kBytes):numBytes], kBytes)
+}
+
+/*
+readCoordinate returns the coordinate encoded in data, which must be lower than P.
+*/
+func readCoordinate(data []byte) (*big.Int, bool) {
+    k := new(big.Int).SetBytes(data)
+    return k, k.Cmp(P) < 0
+}
+
+func isZeroBytes(data []byte) bool {
+    for _, b := range data {
+        if b != 0 {
+            return false
+        }
+    }
+    return true
+}
+
+/*
+recoverY returns the y coordinate of the point of the curve y² = x³ + B of the map m
+with the given x and sign, or false if x is not the coordinate of a point.
+*/
+func recoverY(m *svdwMap, x *gfP2, sign uint) (*gfP2, bool) {
+    gx := m.g(x)
+    if !m.f.isSquare(gx) {
+        return nil, false
+    }
+    y := m.f.sqrt(gx)
+    if !m.f.mul(y, y).Equals(gx) {
+        return nil, false
+    }
+    if m.f.sgn0(y) != sign {
+        y = fieldNeg(y)
+    }
+    return y, true
+}
+
+// MarshalCompressed converts e to a byte slice of G1CompressedSize bytes.
+func (e *G1) MarshalCompressed() []byte {
+    ret := make([]byte, G1CompressedSize)
+    if e.p.IsInfinity() {
+        return ret
+    }
+    e.p.MakeAffine(nil)
+    y := new(big.Int).Mod(e.p.y, P)
+    ret[0] = flagEven + byte(y.Bit(0))
+    putCoordinate(ret[1:], e.p.x)
+    return ret
+}
+
+// UnmarshalCompressed sets e to the result of converting the output of
+// MarshalCompressed back into a group element and then returns e. The cofactor of
+// G1 is 1, so every point of the curve belongs to the group.
+func (e *G1) UnmarshalCompressed(m []byte) (*G1, error) {
+    if len(m) != G1CompressedSize {
+        return nil, ErrInvalidEncoding
+    }
+    if e.p == nil {
+        e.p = newCurvePoint(nil)
+    }
+    if m[0] == flagInfinity {
+        if !isZeroBytes(m[1:]) {
+            return nil, ErrInvalidEncoding
+        }
+        // This is the point at infinity.
+        e.p.x.SetInt64(0)
+        e.p.y.SetInt64(1)
+        e.p.z.SetInt64(0)
+        e.p.t.SetInt64(0)
+        return e, nil
+    }
+    if m[0] != flagEven && m[0] != flagOdd {
+        return nil, ErrInvalidEncoding
+    }
+    x, ok := readCoordinate(m[1:])
+    if !ok {
+        return nil, ErrInvalidEncoding
+    }
+    y, ok := recoverY(g1Map, &gfP2{new(big.Int), x}, uint(m[0]&1))
+    if !ok {
+        return nil, ErrNotOnCurve
+    }
+    e.p.x.Set(x)
+    e.p.y.Set(y.y)
+    e.p.z.SetInt64(1)
+    e.p.t.SetInt64(1)
+    return e, nil
+}
+
+// MarshalCompressed converts e to a byte slice of G2CompressedSize bytes. The
+// coordinate x = x.x.i + x.y is written in the same order as Marshal.
+func (e *G2) MarshalCompressed() []byte {
+    ret := make([]byte, G2CompressedSize)
+    if e.p.IsInfinity() {
+        return ret
+    }
+    e.p.MakeAffine(nil)
+    y := &gfP2{new(big.Int).Mod(e.p.y.x, P), new(big.Int).Mod(e.p.y.y, P)}
+    ret[0] = flagEven + byte(g2Map.f.sgn0(y))
+    putCoordinate(ret[1:], e.p.x.x)
+    putCoordinate(ret[1+numBytes:], e.p.x.y)
+    return ret
+}
+
+// UnmarshalCompressed sets e to the result of converting the output of
+// MarshalCompressed back into a group element and then returns e. It checks that
+// the point belongs to the subgroup of order Order of the twist.
+func (e *G2) UnmarshalCompressed(m []byte) (*G2, error) {
+    if len(m) != G2CompressedSize {
+        return nil, ErrInvalidEncoding
+    }
+    if e.p == nil {
+        e.p = newTwistPoint(nil)
+    }
+    if m[0] == flagInfinity {
+        if !isZeroBytes(m[1:]) {
+            return nil, ErrInvalidEncoding
+        }
+        // This is the point at infinity.
+        e.p.x.SetZero()
+        e.p.y.SetOne()
+        e.p.z.SetZero()
+        e.p.t.SetZero()
+        return e, nil
+    }
+    if m[0] != flagEven && m[0] != flagOdd {
+        return nil, ErrInvalidEncoding
+    }
+    xx, ok := readCoordinate(m[1 : 1+numBytes])
+    if !ok {
+        return nil, ErrInvalidEncoding
+    }
+    xy, ok := readCoordinate(m[1+numBytes:])
+    if !ok {
+        return nil, ErrInvalidEncoding
+    }
+    x := &gfP2{xx, xy}
+    y, ok := recoverY(g2Map, x, uint(m[0]&1))
+    if !ok {
+        return nil, ErrNotOnCurve
+    }
+    p := &twistPoint{x, y, fieldElement(1), fieldElement(1)}
+    if !p.IsInSubgroup() {
+        return nil, ErrNotInSubgroup
+    }
+    e.p.Set(p)
+    return e, nil
+}
@@ -30,6 +30,9 @@ var P = intconversion.BigFromBase10("2188824287183927522224640574525727508869631
 // Order is the number of elements in both G₁ and G₂: 36u⁴+36u³+18u²+6u+1.
 var Order = intconversion.BigFromBase10("21888242871839275222246405745257275088548364400416034343698204186575808495617")
 
+// sixuSquared is 6u², which is the trace of Frobenius minus one: P - Order.
+var sixuSquared = intconversion.BigFromBase10("147946756881789318990833708069417712966")
+
 // xiToPMinus1Over6 is ξ^((p-1)/6) where ξ = i+9.
 var xiToPMinus1Over6 = &gfP2{intconversion.BigFromBase10("16469823323077808223889137241176536799009286646108169935659301613961712198316"), intconversion.BigFromBase10("8376118865763821496583973867626364092589906065868298776909617916018768340080")}
 
@@ -0,0 +1,296 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package bn256
+
+import (
+    "math/big"
+
+    "github.com/ing-bank/zkrp/crypto/hashtocurve"
+)
+
+/*
+RFC 9380 does not specify suites for this curve, so the suites below follow its
+generic construction: hash_to_field with expand_message_xmd and SHA-256 (L = 48),
+the Shallue-van de Woestijne map from section 6.6.1, with Z chosen by the algorithm
+find_z_svdw from appendix H.1, and the random oracle encoding hash_to_curve. The
+elements of GF(p²) are written c0 + c1.i, and the cofactor of G2 is cleared with the
+endomorphism of the twist (see clearCofactor). These choices are the ones of gnark-crypto
+for the same suite names, so both implementations hash to the same points.
+*/
+const (
+    HashToG1Suite = "BN254G1_XMD:SHA-256_SVDW_RO_"
+    HashToG2Suite = "BN254G2_XMD:SHA-256_SVDW_RO_"
+)
+
+/*
+svdwField contains the operations of GF(p) or GF(p²) that depend on the field. The
+elements of both fields are represented by gfP2 values, where the elements of GF(p)
+have x = 0. All the results are reduced.
+*/
+type svdwField interface {
+    mul(a, b *gfP2) *gfP2
+    // inv returns 1/a, or 0 if a = 0
+    inv(a *gfP2) *gfP2
+    isSquare(a *gfP2) bool
+    // sqrt returns a square root of a, which must be a square
+    sqrt(a *gfP2) *gfP2
+    sgn0(a *gfP2) uint
+}
+
+func fieldElement(k int64) *gfP2 {
+    e := &gfP2{new(big.Int), big.NewInt(k)}
+    e.Minimal()
+    return e
+}
+
+func fieldAdd(a, b *gfP2) *gfP2 {
+    e := newGFp2(nil).Add(a, b)
+    e.Minimal()
+    return e
+}
+
+func fieldSub(a, b *gfP2) *gfP2 {
+    e := newGFp2(nil).Sub(a, b)
+    e.Minimal()
+    return e
+}
+
+func fieldNeg(a *gfP2) *gfP2 {
+    e := newGFp2(nil).Negative(a)
+    e.Minimal()
+    return e
+}
+
+/*
+gfPField is GF(p).
+*/
+type gfPField struct{}
+
+func (gfPField) mul(a, b *gfP2) *gfP2 {
+    y := new(big.Int).Mul(a.y, b.y)
+    return &gfP2{new(big.Int), y.Mod(y, P)}
+}
+
+func (gfPField) inv(a *gfP2) *gfP2 {
+    if a.IsZero() {
+        return fieldElement(0)
+    }
+    return &gfP2{new(big.Int), new(big.Int).ModInverse(a.y, P)}
+}
+
+func (gfPField) isSquare(a *gfP2) bool {
+    return big.Jacobi(a.y, P) >= 0
+}
+
+func (gfPField) sqrt(a *gfP2) *gfP2 {
+    return &gfP2{new(big.Int), new(big.Int).ModSqrt(a.y, P)}
+}
+
+func (gfPField) sgn0(a *gfP2) uint {
+    return a.y.Bit(0)
+}
+
+/*
+gfP2Field is GF(p²) = GF(p)[i]/(i² + 1).
+*/
+type gfP2Field struct{}
+
+func (gfP2Field) mul(a, b *gfP2) *gfP2 {
+    e := newGFp2(nil).Mul(a, b, nil)
+    e.Minimal()
+    return e
+}
+
+func (gfP2Field) inv(a *gfP2) *gfP2 {
+    if a.IsZero() {
+        return fieldElement(0)
+    }
+    e := newGFp2(nil).Invert(a, nil)
+    e.Minimal()
+    return e
+}
+
+func (gfP2Field) isSquare(a *gfP2) bool {
+    // a is a square if and only if its norm is a square in GF(p)
+    norm := new(big.Int).Mul(a.x, a.x)
+    norm.Add(norm, new(big.Int).Mul(a.y, a.y))
+    return big.Jacobi(norm.Mod(norm, P), P) >= 0
+}
+
+/*
+sqrt implements the algorithm 9 from "Square root computation over even extension
+fields", Adj and Rodríguez-Henríquez, for p = 3 mod 4.
+*/
+func (f gfP2Field) sqrt(a *gfP2) *gfP2 {
+    if a.IsZero() {
+        return fieldElement(0)
+    }
+    exp := new(big.Int).Sub(P, big.NewInt(3))
+    exp.Rsh(exp, 2)
+    a1 := newGFp2(nil).Exp(a, exp, nil)
+    a1.Minimal()
+    alpha := f.mul(f.mul(a1, a1), a)
+    x0 := f.mul(a1, a)
+    minusOne := fieldElement(-1)
+    if alpha.Equals(minusOne) {
+        // x = i.x0
+        return fieldNeg(&gfP2{new(big.Int).Neg(x0.y), x0.x})
+    }
+    exp.Sub(P, big.NewInt(1))
+    exp.Rsh(exp, 1)
+    b := newGFp2(nil).Exp(fieldAdd(alpha, fieldElement(1)), exp, nil)
+    b.Minimal()
+    return f.mul(b, x0)
+}
+
+func (gfP2Field) sgn0(a *gfP2) uint {
+    // c0 = y and c1 = x
+    sign0 := a.y.Bit(0)
+    if a.y.Sign() == 0 {
+        return a.x.Bit(0)
+    }
+    return sign0
+}
+
+/*
+svdwMap contains the constants of the Shallue-van de Woestijne map for the curve
+y² = x³ + B over the field f.
+*/
+type svdwMap struct {
+    f              svdwField
+    B              *gfP2
+    Z              *gfP2
+    c1, c2, c3, c4 *gfP2
+}
+
+func (m *svdwMap) g(x *gfP2) *gfP2 {
+    return fieldAdd(m.f.mul(m.f.mul(x, x), x), m.B)
+}
+
+func newSVDWMap(f svdwField, B *gfP2) *svdwMap {
+    m := &svdwMap{f: f, B: B}
+    three := fieldElement(3)
+    four := fieldElement(4)
+
+    // find_z_svdw, RFC 9380 appendix H.1, with A = 0
+    h := func(Z *gfP2) *gfP2 {
+        num := fieldNeg(f.mul(three, f.mul(Z, Z)))
+        return f.mul(num, f.inv(f.mul(four, m.g(Z))))
+    }
+    for ctr := int64(1); m.Z == nil; ctr++ {
+        for _, Z := range []*gfP2{fieldElement(ctr), fieldElement(-ctr)} {
+            gZ := m.g(Z)
+            if gZ.IsZero() || h(Z).IsZero() || !f.isSquare(h(Z)) {
+                continue
+            }
+            if f.isSquare(gZ) || f.isSquare(f.mul(m.g(fieldNeg(Z)), f.inv(fieldElement(2)))) {
+                m.Z = Z
+                break
+            }
+        }
+    }
+
+    Z := m.Z
+    threeZ2 := f.mul(three, f.mul(Z, Z))
+    m.c1 = m.g(Z)
+    m.c2 = f.mul(fieldNeg(Z), f.inv(fieldElement(2)))
+    m.c3 = f.sqrt(fieldNeg(f.mul(m.c1, threeZ2)))
+    if f.sgn0(m.c3) != 0 {
+        m.c3 = fieldNeg(m.c3)
+    }
+    m.c4 = f.mul(fieldNeg(f.mul(four, m.c1)), f.inv(threeZ2))
+    return m
+}
+
+/*
+mapToCurve implements the Shallue-van de Woestijne method from RFC 9380 section
+6.6.1 and returns the affine coordinates of the point.
+*/
+func (m *svdwMap) mapToCurve(u *gfP2) (*gfP2, *gfP2) {
+    f := m.f
+    one := fieldElement(1)
+    tv1 := f.mul(f.mul(u, u), m.c1)
+    tv2 := fieldAdd(one, tv1)
+    tv1 = fieldSub(one, tv1)
+    tv3 := f.inv(f.mul(tv1, tv2))
+    tv4 := f.mul(f.mul(f.mul(u, tv1), tv3), m.c3)
+
+    x := fieldSub(m.c2, tv4)
+    if !f.isSquare(m.g(x)) {
+        x = fieldAdd(m.c2, tv4)
+        if !f.isSquare(m.g(x)) {
+            x3 := f.mul(f.mul(tv2, tv2), tv3)
+            x = fieldAdd(f.mul(f.mul(x3, x3), m.c4), m.Z)
+        }
+    }
+    y := f.sqrt(m.g(x))
+    if f.sgn0(u) != f.sgn0(y) {
+        y = fieldNeg(y)
+    }
+    return x, y
+}
+
+var (
+    g1Map = newSVDWMap(gfPField{}, fieldElement(3))
+    g2Map = newSVDWMap(gfP2Field{}, twistB)
+)
+
+/*
+HashToG1 hashes msg to a point of G1, for the domain separation tag dst, following
+the suite HashToG1Suite.
+*/
+func HashToG1(dst, msg []byte) (*G1, error) {
+    u, err := hashtocurve.HashToField(msg, dst, P, 2, 1)
+    if err != nil {
+        return nil, err
+    }
+    pool := new(bnPool)
+    Q := make([]*curvePoint, 2)
+    for i := range Q {
+        x, y := g1Map.mapToCurve(&gfP2{new(big.Int), u[i][0]})
+        Q[i] = &curvePoint{x.y, y.y, big.NewInt(1), big.NewInt(1)}
+    }
+    // the cofactor of G1 is 1
+    R := newCurvePoint(pool)
+    R.Add(Q[0], Q[1], pool)
+    R.MakeAffine(pool)
+    return &G1{R}, nil
+}
+
+/*
+HashToG2 hashes msg to a point of G2, for the domain separation tag dst, following
+the suite HashToG2Suite.
+*/
+func HashToG2(dst, msg []byte) (*G2, error) {
+    u, err := hashtocurve.HashToField(msg, dst, P, 2, 2)
+    if err != nil {
+        return nil, err
+    }
+    pool := new(bnPool)
+    Q := make([]*twistPoint, 2)
+    for i := range Q {
+        // u = c0 + c1.i
+        x, y := g2Map.mapToCurve(&gfP2{u[i][1], u[i][0]})
+        Q[i] = &twistPoint{x, y, fieldElement(1), fieldElement(1)}
+    }
+    R := newTwistPoint(pool)
+    R.Add(Q[0], Q[1], pool)
+    R.clearCofactor(R, pool)
+    R.MakeAffine(pool)
+    return &G2{R}, nil
+}
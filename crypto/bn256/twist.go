@@ -107,6 +107,15 @@ func (c *twistPoint) IsOnCurve() bool {
     return yy.x.Sign() == 0 && yy.y.Sign() == 0
 }
 
+// g2Cofactor is the cofactor of G2 in the twist: 2p - Order.
+var g2Cofactor = new(big.Int).Sub(new(big.Int).Lsh(P, 1), Order)
+
+// IsInSubgroup returns true iff Order.c is the point at infinity, i.e. c belongs to
+// G2. The twist has the cofactor 2p - Order.
+func (c *twistPoint) IsInSubgroup() bool {
+    return newTwistPoint(nil).Mul(c, Order, nil).IsInfinity()
+}
+
 func (c *twistPoint) SetInfinity() {
     c.z.SetZero()
 }
@@ -252,6 +261,13 @@ func (c *twistPoint) MakeAffine(pool *bnPool) *twistPoint {
         return c
     }
 
+    if c.IsInfinity() {
+        c.x.SetZero()
+        c.y.SetOne()
+        c.t.SetZero()
+        return c
+    }
+
     zInv := newGFp2(pool).Invert(c.z, pool)
     t := newGFp2(pool).Mul(c.y, zInv, pool)
     zInv2 := newGFp2(pool).Square(zInv, pool)
@@ -270,8 +286,48 @@ func (c *twistPoint) MakeAffine(pool *bnPool) *twistPoint {
 
 func (c *twistPoint) Negative(a *twistPoint, pool *bnPool) {
     c.x.Set(a.x)
-    c.y.SetZero()
-    c.y.Sub(c.y, a.y)
+    // a may be equal to c
+    c.y.Negative(a.y)
     c.z.Set(a.z)
     c.t.SetZero()
 }
+
+// Frobenius sets c to ψ(a), the p-power Frobenius endomorphism carried to the twist:
+// (x, y) is mapped to (x̄.ξ^((p-1)/3), ȳ.ξ^((p-1)/2)), see optimalAte. Since the
+// conjugation is a field automorphism, it is applied to the Jacobian coordinates.
+func (c *twistPoint) Frobenius(a *twistPoint, pool *bnPool) *twistPoint {
+    c.x.Conjugate(a.x)
+    c.x.Mul(c.x, xiToPMinus1Over3, pool)
+    c.y.Conjugate(a.y)
+    c.y.Mul(c.y, xiToPMinus1Over2, pool)
+    c.z.Conjugate(a.z)
+    c.t.Conjugate(a.t)
+    return c
+}
+
+// clearCofactor sets c to [u]a + ψ([3u]a) + ψ²([u]a) + ψ³(a), which is a multiple of
+// a that belongs to G₂, following Fuentes-Castañeda, Knapp and Rodríguez-Henríquez,
+// "Faster hashing to G2", section 6.1. It is much cheaper than the multiplication by
+// g2Cofactor, and it is the map used by the other implementations of the suite
+// HashToG2Suite.
+func (c *twistPoint) clearCofactor(a *twistPoint, pool *bnPool) *twistPoint {
+    ua := newTwistPoint(pool).Mul(a, u, pool)
+    t := newTwistPoint(pool)
+    t.Double(ua, pool)
+    u3a := newTwistPoint(pool)
+    u3a.Add(t, ua, pool)
+    u3a.Frobenius(u3a, pool)
+
+    sum := newTwistPoint(pool)
+    sum.Add(ua, u3a, pool)
+    t.Frobenius(ua, pool).Frobenius(t, pool)
+    u3a.Add(sum, t, pool)
+    t.Frobenius(a, pool).Frobenius(t, pool).Frobenius(t, pool)
+    c.Add(u3a, t, pool)
+
+    ua.Put(pool)
+    t.Put(pool)
+    u3a.Put(pool)
+    sum.Put(pool)
+    return c
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+/*
+Package commitment implements Pedersen commitments C = G^x.H^r over secp256k1,
+where G is the base point of the curve and H is a generator with no known discrete
+logarithm relation to G, such as the H of the bulletproofs parameters.
+
+Pedersen commitments are additively homomorphic: the product of the commitments to
+x1 and x2 is a commitment to x1 + x2, with blinding factor r1 + r2. The Opening type
+tracks the committed values, so that both sides can be updated together:
+
+    total := c1.Add(c2).Add(c3)
+    opening := o1.Add(o2).Add(o3)
+    ok := total.Open(opening.X, opening.R)
+
+In this package the group law is written additively, as in the names of the methods.
+All the commitments that are combined must use the same generator H.
+*/
+package commitment
+
+import (
+    "math/big"
+
+    "github.com/ing-bank/zkrp/crypto/p256"
+)
+
+/*
+Pedersen is a commitment G^x.H^r.
+*/
+type Pedersen struct {
+    // C is the commitment.
+    C *p256.P256
+    // H is the generator used for the blinding factor.
+    H *p256.P256
+}
+
+/*
+Opening contains the committed value X and the blinding factor R of a commitment.
+*/
+type Opening struct {
+    X *big.Int
+    R *big.Int
+}
+
+/*
+Commit computes the commitment G^x.H^r.
+*/
+func Commit(x, r *big.Int, H *p256.P256) *Pedersen {
+    Gx := new(p256.P256).ScalarBaseMult(x)
+    Hr := new(p256.P256).ScalarMult(H, r)
+    return &Pedersen{C: new(p256.P256).Add(Gx, Hr), H: H}
+}
+
+/*
+FromPoint wraps a commitment C computed with the generator H, for instance one that
+was received over the network. H may be nil if the commitment is not opened.
+*/
+func FromPoint(C, H *p256.P256) *Pedersen {
+    return &Pedersen{C: C, H: H}
+}
+
+/*
+Sum returns the sum of the commitments. It returns nil if no commitment is given.
+*/
+func Sum(cs ...*Pedersen) *Pedersen {
+    if len(cs) == 0 {
+        return nil
+    }
+    sum := cs[0]
+    for _, c := range cs[1:] {
+        sum = sum.Add(c)
+    }
+    return sum
+}
+
+/*
+Add returns the commitment to the sum of the values committed in c and d.
+*/
+func (c *Pedersen) Add(d *Pedersen) *Pedersen {
+    return &Pedersen{C: new(p256.P256).Add(c.C, d.C), H: c.H}
+}
+
+/*
+Sub returns the commitment to the difference of the values committed in c and d.
+*/
+func (c *Pedersen) Sub(d *Pedersen) *Pedersen {
+    minusD := new(p256.P256).Neg(d.C)
+    return &Pedersen{C: new(p256.P256).Add(c.C, minusD), H: c.H}
+}
+
+/*
+AddConstant returns the commitment to x + v, where x is the value committed in c.
+The blinding factor is not changed.
+*/
+func (c *Pedersen) AddConstant(v *big.Int) *Pedersen {
+    Gv := new(p256.P256).ScalarBaseMult(v)
+    return &Pedersen{C: new(p256.P256).Add(c.C, Gv), H: c.H}
+}
+
+/*
+ScalarMul returns the commitment to k.x with blinding factor k.r, where x and r are
+the value and the blinding factor of c.
+*/
+func (c *Pedersen) ScalarMul(k *big.Int) *Pedersen {
+    return &Pedersen{C: new(p256.P256).ScalarMult(c.C, k), H: c.H}
+}
+
+/*
+Equals returns true if and only if c and d are the same commitment.
+*/
+func (c *Pedersen) Equals(d *Pedersen) bool {
+    if c == nil || d == nil || c.C == nil || d.C == nil {
+        return false
+    }
+    return c.C.Equals(d.C)
+}
+
+/*
+IsSumOf returns true if and only if c is equal to the sum of the commitments cs, that
+is, if c commits to the sum of the values committed in cs.
+*/
+func (c *Pedersen) IsSumOf(cs ...*Pedersen) bool {
+    for _, d := range cs {
+        if d == nil || d.C == nil {
+            return false
+        }
+    }
+    return c.Equals(Sum(cs...))
+}
+
+/*
+Open returns true if and only if c is the commitment to x with blinding factor r.
+*/
+func (c *Pedersen) Open(x, r *big.Int) bool {
+    if c == nil || c.H == nil {
+        return false
+    }
+    return c.Equals(Commit(x, r, c.H))
+}
+
+/*
+Commit computes the commitment to the opening.
+*/
+func (o Opening) Commit(H *p256.P256) *Pedersen {
+    return Commit(o.X, o.R, H)
+}
+
+/*
+Add returns the opening of the sum of the commitments opened by o and q.
+*/
+func (o Opening) Add(q Opening) Opening {
+    return Opening{X: new(big.Int).Add(o.X, q.X), R: new(big.Int).Add(o.R, q.R)}
+}
+
+/*
+Sub returns the opening of the difference of the commitments opened by o and q.
+*/
+func (o Opening) Sub(q Opening) Opening {
+    return Opening{X: new(big.Int).Sub(o.X, q.X), R: new(big.Int).Sub(o.R, q.R)}
+}
+
+/*
+AddConstant returns the opening of Pedersen.AddConstant(v).
+*/
+func (o Opening) AddConstant(v *big.Int) Opening {
+    return Opening{X: new(big.Int).Add(o.X, v), R: new(big.Int).Set(o.R)}
+}
+
+/*
+ScalarMul returns the opening of Pedersen.ScalarMul(k).
+*/
+func (o Opening) ScalarMul(k *big.Int) Opening {
+    return Opening{X: new(big.Int).Mul(o.X, k), R: new(big.Int).Mul(o.R, k)}
+}
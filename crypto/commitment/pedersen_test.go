@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commitment
+
+import (
+    "math/big"
+    "testing"
+
+    "github.com/ing-bank/zkrp/crypto/p256"
+)
+
+func generatorH(t *testing.T) *p256.P256 {
+    H, err := p256.MapToGroup("commitment test")
+    if err != nil {
+        t.Fatal(err)
+    }
+    return H
+}
+
+func TestOpen(t *testing.T) {
+    H := generatorH(t)
+    c := Commit(big.NewInt(42), big.NewInt(7), H)
+    if !c.Open(big.NewInt(42), big.NewInt(7)) {
+        t.Errorf("Assert failure: commitment should open to (42, 7)")
+    }
+    if c.Open(big.NewInt(43), big.NewInt(7)) || c.Open(big.NewInt(42), big.NewInt(8)) {
+        t.Errorf("Assert failure: commitment should not open to other values")
+    }
+}
+
+func TestHomomorphism(t *testing.T) {
+    H := generatorH(t)
+    o1 := Opening{X: big.NewInt(5), R: big.NewInt(100)}
+    o2 := Opening{X: big.NewInt(0), R: big.NewInt(0)}
+    o3 := Opening{X: big.NewInt(5), R: big.NewInt(100)}
+    c1, c2, c3 := o1.Commit(H), o2.Commit(H), o3.Commit(H)
+
+    total := o1.Add(o2).Add(o3)
+    if !Sum(c1, c2, c3).Open(total.X, total.R) {
+        t.Errorf("Assert failure: sum of commitments should open to the sum of openings")
+    }
+    if !total.Commit(H).IsSumOf(c1, c2, c3) {
+        t.Errorf("Assert failure: total should be the sum of the commitments")
+    }
+    if total.Commit(H).IsSumOf(c1, c2) {
+        t.Errorf("Assert failure: total should not be the sum of a subset of the commitments")
+    }
+
+    diff := o1.Sub(o3)
+    if !c1.Sub(c3).Open(diff.X, diff.R) || !c1.Sub(c3).C.IsZero() {
+        t.Errorf("Assert failure: difference of equal commitments should commit to zero")
+    }
+
+    shifted := o1.AddConstant(big.NewInt(-20)).ScalarMul(big.NewInt(3))
+    if !c1.AddConstant(big.NewInt(-20)).ScalarMul(big.NewInt(3)).Open(shifted.X, shifted.R) {
+        t.Errorf("Assert failure: AddConstant and ScalarMul should match the opening")
+    }
+}
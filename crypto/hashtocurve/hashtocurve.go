@@ -0,0 +1,110 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+/*
+Package hashtocurve implements the curve independent parts of RFC 9380, Hashing to
+Elliptic Curves (https://www.rfc-editor.org/rfc/rfc9380): expand_message_xmd using
+SHA-256 and hash_to_field. The maps to the curves are implemented by the packages
+p256 (secp256k1) and bn256 (G1 and G2).
+*/
+package hashtocurve
+
+import (
+    "crypto/sha256"
+    "errors"
+    "math/big"
+)
+
+// SecurityLevel is the target security level k of the suites, in bits.
+const SecurityLevel = 128
+
+const (
+    hashSize  = sha256.Size      // b_in_bytes
+    blockSize = sha256.BlockSize // s_in_bytes
+)
+
+/*
+ExpandMessageXMD implements expand_message_xmd from section 5.3.1 of RFC 9380 with
+SHA-256. It returns length uniformly random bytes derived from msg, for the domain
+separation tag dst.
+*/
+func ExpandMessageXMD(msg, dst []byte, length int) ([]byte, error) {
+    ell := (length + hashSize - 1) / hashSize
+    if length <= 0 || ell > 255 || length > 65535 {
+        return nil, errors.New("invalid length for expand_message_xmd")
+    }
+    if len(dst) > 255 {
+        // section 5.3.3: oversized domain separation tags are hashed
+        digest := sha256.New()
+        digest.Write([]byte("H2C-OVERSIZE-DST-"))
+        digest.Write(dst)
+        dst = digest.Sum(nil)
+    }
+    dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+
+    digest := sha256.New()
+    digest.Write(make([]byte, blockSize))
+    digest.Write(msg)
+    digest.Write([]byte{byte(length >> 8), byte(length), 0})
+    digest.Write(dstPrime)
+    b0 := digest.Sum(nil)
+
+    digest.Reset()
+    digest.Write(b0)
+    digest.Write([]byte{1})
+    digest.Write(dstPrime)
+    bi := digest.Sum(nil)
+
+    uniform := make([]byte, 0, ell*hashSize)
+    uniform = append(uniform, bi...)
+    for i := 2; i <= ell; i++ {
+        xored := make([]byte, hashSize)
+        for j := range xored {
+            xored[j] = b0[j] ^ bi[j]
+        }
+        digest.Reset()
+        digest.Write(xored)
+        digest.Write([]byte{byte(i)})
+        digest.Write(dstPrime)
+        bi = digest.Sum(nil)
+        uniform = append(uniform, bi...)
+    }
+    return uniform[:length], nil
+}
+
+/*
+HashToField implements hash_to_field from section 5.2 of RFC 9380. It returns count
+elements of the extension of degree m of the field of integers modulo p, each one
+given by its m coordinates.
+*/
+func HashToField(msg, dst []byte, p *big.Int, count, m int) ([][]*big.Int, error) {
+    L := (p.BitLen() + SecurityLevel + 7) / 8
+    uniform, err := ExpandMessageXMD(msg, dst, count*m*L)
+    if err != nil {
+        return nil, err
+    }
+    u := make([][]*big.Int, count)
+    for i := range u {
+        u[i] = make([]*big.Int, m)
+        for j := range u[i] {
+            offset := L * (j + i*m)
+            e := new(big.Int).SetBytes(uniform[offset : offset+L])
+            u[i][j] = e.Mod(e, p)
+        }
+    }
+    return u, nil
+}
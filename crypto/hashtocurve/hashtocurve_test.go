@@ -0,0 +1,57 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package hashtocurve
+
+import (
+    "encoding/hex"
+    "testing"
+)
+
+// Test vectors from RFC 9380, appendix K.1.
+func TestExpandMessageXMD(t *testing.T) {
+    dst := []byte("QUUX-V01-CS02-with-expander-SHA256-128")
+    vectors := []struct {
+        msg      string
+        expected string
+    }{
+        {"", "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+        {"abc", "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+    }
+    for _, v := range vectors {
+        out, err := ExpandMessageXMD([]byte(v.msg), dst, 32)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if hex.EncodeToString(out) != v.expected {
+            t.Errorf("Assert failure: expand_message_xmd(%q) = %x, expected %s", v.msg, out, v.expected)
+        }
+    }
+}
+
+func TestExpandMessageXMDLength(t *testing.T) {
+    dst := []byte("test")
+    for _, n := range []int{1, 31, 32, 33, 100, 255 * 32} {
+        out, err := ExpandMessageXMD([]byte("msg"), dst, n)
+        if err != nil || len(out) != n {
+            t.Errorf("Assert failure: expected %d bytes", n)
+        }
+    }
+    if _, err := ExpandMessageXMD([]byte("msg"), dst, 255*32+1); err == nil {
+        t.Errorf("Assert failure: too long output should be rejected")
+    }
+}
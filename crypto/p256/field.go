@@ -0,0 +1,332 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package p256
+
+import (
+    "encoding/binary"
+    "math/big"
+    "math/bits"
+)
+
+/*
+Field elements are integers modulo P = 2^256 - 2^32 - 977, represented by four
+64-bit limbs in little-endian order and always fully reduced. Since 2^256 = R mod P
+with R = 2^32 + 977, the products are reduced by folding their upper half,
+multiplied by R, into their lower half. The running time and the memory accesses of
+the operations do not depend on the values of their inputs, except for the
+conversions from and to big.Int. The conditions are given as 0 or 1 in a uint64.
+*/
+type fieldElement [4]uint64
+
+// fieldR is 2^256 mod P.
+const fieldR = 0x1000003d1
+
+var (
+    fieldOne = fieldElement{1}
+    // fieldInvExp is P-2, since a^(P-2) is the inverse of a by Fermat's little theorem.
+    fieldInvExp = limbsFromHex("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2d")
+    // fieldSqrtExp is (P+1)/4, since a^((P+1)/4) is a square root of a if P = 3 mod 4.
+    fieldSqrtExp = limbsFromHex("3fffffffffffffffffffffffffffffffffffffffffffffffffffffffbfffff0c")
+)
+
+/*
+limbsFromHex returns the limbs of a constant of at most 256 bits given in hexadecimal.
+*/
+func limbsFromHex(s string) [4]uint64 {
+    k, ok := new(big.Int).SetString(s, 16)
+    if !ok || k.BitLen() > 256 {
+        panic("p256: invalid constant " + s)
+    }
+    return limbsFromBig(k)
+}
+
+/*
+limbsFromBig returns the limbs of k, which must be in [0, 2^256).
+*/
+func limbsFromBig(k *big.Int) [4]uint64 {
+    var b [32]byte
+    k.FillBytes(b[:])
+    return limbsFromBytes(&b)
+}
+
+func limbsFromBytes(b *[32]byte) [4]uint64 {
+    var l [4]uint64
+    for i := range l {
+        l[i] = binary.BigEndian.Uint64(b[24-8*i:])
+    }
+    return l
+}
+
+func limbsToBytes(l *[4]uint64) [32]byte {
+    var b [32]byte
+    for i := range l {
+        binary.BigEndian.PutUint64(b[24-8*i:], l[i])
+    }
+    return b
+}
+
+func limbsToBig(l *[4]uint64) *big.Int {
+    b := limbsToBytes(l)
+    return new(big.Int).SetBytes(b[:])
+}
+
+/*
+mulAdd returns a.b + c + d, which fits in 128 bits, as its upper and lower limbs.
+*/
+func mulAdd(a, b, c, d uint64) (uint64, uint64) {
+    hi, lo := bits.Mul64(a, b)
+    var carry uint64
+    lo, carry = bits.Add64(lo, c, 0)
+    hi += carry
+    lo, carry = bits.Add64(lo, d, 0)
+    hi += carry
+    return hi, lo
+}
+
+/*
+mulWide returns the 512-bit product of a and b.
+*/
+func mulWide(a, b *[4]uint64) [8]uint64 {
+    var t [8]uint64
+    var c uint64
+    c, t[0] = mulAdd(a[0], b[0], 0, 0)
+    c, t[1] = mulAdd(a[0], b[1], 0, c)
+    c, t[2] = mulAdd(a[0], b[2], 0, c)
+    t[4], t[3] = mulAdd(a[0], b[3], 0, c)
+
+    c, t[1] = mulAdd(a[1], b[0], t[1], 0)
+    c, t[2] = mulAdd(a[1], b[1], t[2], c)
+    c, t[3] = mulAdd(a[1], b[2], t[3], c)
+    t[5], t[4] = mulAdd(a[1], b[3], t[4], c)
+
+    c, t[2] = mulAdd(a[2], b[0], t[2], 0)
+    c, t[3] = mulAdd(a[2], b[1], t[3], c)
+    c, t[4] = mulAdd(a[2], b[2], t[4], c)
+    t[6], t[5] = mulAdd(a[2], b[3], t[5], c)
+
+    c, t[3] = mulAdd(a[3], b[0], t[3], 0)
+    c, t[4] = mulAdd(a[3], b[1], t[4], c)
+    c, t[5] = mulAdd(a[3], b[2], t[5], c)
+    t[7], t[6] = mulAdd(a[3], b[3], t[6], c)
+    return t
+}
+
+/*
+ctEqual returns 1 if a = b and 0 otherwise.
+*/
+func ctEqual(a, b uint64) uint64 {
+    x := a ^ b
+    return 1 ^ (x|-x)>>63
+}
+
+/*
+setBig sets z to k mod P.
+*/
+func (z *fieldElement) setBig(k *big.Int) *fieldElement {
+    if k.Sign() < 0 || k.Cmp(CURVE.P) >= 0 {
+        k = new(big.Int).Mod(k, CURVE.P)
+    }
+    *z = limbsFromBig(k)
+    return z
+}
+
+func (z *fieldElement) big() *big.Int {
+    return limbsToBig((*[4]uint64)(z))
+}
+
+/*
+setBytes sets z to the big-endian integer b and returns 1, or returns 0 and leaves z
+unchanged if the integer is not lower than P.
+*/
+func (z *fieldElement) setBytes(b *[32]byte) uint64 {
+    a := fieldElement(limbsFromBytes(b))
+    // a + R overflows if and only if a >= P
+    _, c := bits.Add64(a[0], fieldR, 0)
+    _, c = bits.Add64(a[1], 0, c)
+    _, c = bits.Add64(a[2], 0, c)
+    _, c = bits.Add64(a[3], 0, c)
+    z.sel(z, &a, c)
+    return 1 ^ c
+}
+
+/*
+sel sets z to a if cond is 1, or to b if cond is 0.
+*/
+func (z *fieldElement) sel(a, b *fieldElement, cond uint64) *fieldElement {
+    mask := -cond
+    for i := range z {
+        z[i] = b[i] ^ (mask & (a[i] ^ b[i]))
+    }
+    return z
+}
+
+func (z *fieldElement) isZero() uint64 {
+    return ctEqual(z[0]|z[1]|z[2]|z[3], 0)
+}
+
+func (z *fieldElement) equal(a *fieldElement) uint64 {
+    return ctEqual((z[0]^a[0])|(z[1]^a[1])|(z[2]^a[2])|(z[3]^a[3]), 0)
+}
+
+func (z *fieldElement) isOdd() uint64 {
+    return z[0] & 1
+}
+
+/*
+reduce sets z to carry.2^256 + a modulo P, which must be lower than 2P.
+*/
+func (z *fieldElement) reduce(a *fieldElement, carry uint64) *fieldElement {
+    var b fieldElement
+    var c uint64
+    // a + R overflows if and only if a >= P
+    b[0], c = bits.Add64(a[0], fieldR, 0)
+    b[1], c = bits.Add64(a[1], 0, c)
+    b[2], c = bits.Add64(a[2], 0, c)
+    b[3], c = bits.Add64(a[3], 0, c)
+    return z.sel(&b, a, carry|c)
+}
+
+func (z *fieldElement) add(a, b *fieldElement) *fieldElement {
+    var s fieldElement
+    var c uint64
+    s[0], c = bits.Add64(a[0], b[0], 0)
+    s[1], c = bits.Add64(a[1], b[1], c)
+    s[2], c = bits.Add64(a[2], b[2], c)
+    s[3], c = bits.Add64(a[3], b[3], c)
+    return z.reduce(&s, c)
+}
+
+func (z *fieldElement) sub(a, b *fieldElement) *fieldElement {
+    var d fieldElement
+    var borrow uint64
+    d[0], borrow = bits.Sub64(a[0], b[0], 0)
+    d[1], borrow = bits.Sub64(a[1], b[1], borrow)
+    d[2], borrow = bits.Sub64(a[2], b[2], borrow)
+    d[3], borrow = bits.Sub64(a[3], b[3], borrow)
+    // a - b + 2^256 is greater than R when it is negative, so adding P cannot borrow
+    d[0], borrow = bits.Sub64(d[0], fieldR&-borrow, 0)
+    d[1], borrow = bits.Sub64(d[1], 0, borrow)
+    d[2], borrow = bits.Sub64(d[2], 0, borrow)
+    d[3], _ = bits.Sub64(d[3], 0, borrow)
+    *z = d
+    return z
+}
+
+func (z *fieldElement) neg(a *fieldElement) *fieldElement {
+    return z.sub(&fieldElement{}, a)
+}
+
+func (z *fieldElement) mul(a, b *fieldElement) *fieldElement {
+    t := mulWide((*[4]uint64)(a), (*[4]uint64)(b))
+    return z.reduceWide(&t)
+}
+
+func (z *fieldElement) square(a *fieldElement) *fieldElement {
+    return z.mul(a, a)
+}
+
+/*
+reduceWide sets z to t modulo P.
+*/
+func (z *fieldElement) reduceWide(t *[8]uint64) *fieldElement {
+    // t[0..3] + t[4..7].R, whose top limb is lower than 2^34
+    var r fieldElement
+    var top uint64
+    top, r[0] = mulAdd(t[4], fieldR, t[0], 0)
+    top, r[1] = mulAdd(t[5], fieldR, t[1], top)
+    top, r[2] = mulAdd(t[6], fieldR, t[2], top)
+    top, r[3] = mulAdd(t[7], fieldR, t[3], top)
+    // fold the top limb, which may overflow 2^256 once more
+    hi, lo := bits.Mul64(top, fieldR)
+    var c uint64
+    r[0], c = bits.Add64(r[0], lo, 0)
+    r[1], c = bits.Add64(r[1], hi, c)
+    r[2], c = bits.Add64(r[2], 0, c)
+    r[3], c = bits.Add64(r[3], 0, c)
+    // after an overflow r is lower than 2^67, so adding R cannot overflow again
+    r[0], c = bits.Add64(r[0], fieldR&-c, 0)
+    r[1], c = bits.Add64(r[1], 0, c)
+    r[2], c = bits.Add64(r[2], 0, c)
+    r[3], _ = bits.Add64(r[3], 0, c)
+    return z.reduce(&r, 0)
+}
+
+/*
+exp sets z to a^e, with a fixed window of 4 bits. The exponent is public, only the
+base is secret.
+*/
+func (z *fieldElement) exp(a *fieldElement, e *[4]uint64) *fieldElement {
+    var table [16]fieldElement
+    table[0] = fieldOne
+    for i := 1; i < len(table); i++ {
+        table[i].mul(&table[i-1], a)
+    }
+    r := fieldOne
+    for i := 63; i >= 0; i-- {
+        for j := 0; j < 4; j++ {
+            r.square(&r)
+        }
+        r.mul(&r, &table[e[i/16]>>(uint(i%16)*4)&15])
+    }
+    *z = r
+    return z
+}
+
+/*
+inv sets z to the inverse of a, or to 0 if a is 0.
+*/
+func (z *fieldElement) inv(a *fieldElement) *fieldElement {
+    return z.exp(a, &fieldInvExp)
+}
+
+/*
+sqrt sets z to a square root of a and returns 1, or returns 0 if a is not a square,
+in which case z is undefined.
+*/
+func (z *fieldElement) sqrt(a *fieldElement) uint64 {
+    var r, r2 fieldElement
+    r.exp(a, &fieldSqrtExp)
+    r2.square(&r)
+    *z = r
+    return r2.equal(a)
+}
+
+/*
+batchInvert replaces every element by its inverse with a single field inversion,
+using Montgomery's trick. The elements must not be zero.
+*/
+func batchInvert(elements []fieldElement) {
+    if len(elements) == 0 {
+        return
+    }
+    // prefix[i] = elements[0]...elements[i]
+    prefix := make([]fieldElement, len(elements))
+    prefix[0] = elements[0]
+    for i := 1; i < len(elements); i++ {
+        prefix[i].mul(&prefix[i-1], &elements[i])
+    }
+    var inv fieldElement
+    inv.inv(&prefix[len(prefix)-1])
+    for i := len(elements) - 1; i > 0; i-- {
+        var e fieldElement
+        e.mul(&inv, &prefix[i-1])
+        inv.mul(&inv, &elements[i])
+        elements[i] = e
+    }
+    elements[0] = inv
+}
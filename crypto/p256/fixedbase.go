@@ -0,0 +1,203 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package p256
+
+import (
+    "sync"
+)
+
+/*
+Fixed-base tables contain the multiples j.2^(w.i).P of a base P, for 1 <= j <= 2^(w-1)
+and every window i of w bits. The scalar is recoded with signed digits in
+[-2^(w-1), 2^(w-1)], so that k.P is the sum of one entry, or of its opposite, per
+non-zero digit and no doubling is needed. The tables are registered by the
+coordinates of the base, which means that every point equal to a precomputed base
+uses its table, whichever parameters it comes from. The entries are in affine
+coordinates, which makes the additions cheaper, and they are all read for every
+digit, so that the multiplication runs in constant time.
+*/
+
+// fixedBaseWindow is the width in bits of the windows of the fixed-base tables.
+const fixedBaseWindow = 4
+
+// fixedBaseWindows is the number of windows of a scalar modulo the order.
+const fixedBaseWindows = 256 / fixedBaseWindow
+
+type fixedBaseTable [fixedBaseWindows][1 << (fixedBaseWindow - 1)]affinePoint
+
+// maxFixedBases bounds the number of tables, which take 32 KiB each.
+const maxFixedBases = 1024
+
+var (
+    fixedBasesMu sync.RWMutex
+    fixedBases   = make(map[string]*fixedBaseTable)
+
+    generatorOnce sync.Once
+)
+
+/*
+fixedBaseKey returns the key of the table of p, which must be in affine coordinates.
+*/
+func fixedBaseKey(p *P256) string {
+    key := make([]byte, 64)
+    p.X.FillBytes(key[:32])
+    p.Y.FillBytes(key[32:])
+    return string(key)
+}
+
+/*
+Precompute builds the fixed-base tables of the given points, which speeds up all
+their subsequent scalar multiplications, including the ones of MultiScalarMult, by
+a large constant factor. The tables are only used by the points in affine
+coordinates, so the caller should normalize the points it keeps, see BatchNormalize;
+the given points are not modified, which makes Precompute safe to call on points
+that are shared with other goroutines. Each table takes about as long as five scalar
+multiplications to build and it is kept for the lifetime of the program, so it should
+only be used for the generators of a scheme. At most maxFixedBases tables are kept
+and the points past that limit are left without a table. The points that already
+have a table, or that are the point at infinity, are skipped.
+*/
+func Precompute(points ...*P256) {
+    copies := make([]*P256, 0, len(points))
+    for _, p := range points {
+        if p != nil {
+            copies = append(copies, p.Copy())
+        }
+    }
+    BatchNormalize(copies...)
+    var bases []*P256
+    seen := make(map[string]bool)
+    fixedBasesMu.RLock()
+    for _, p := range copies {
+        if p.IsZero() || len(fixedBases)+len(bases) >= maxFixedBases {
+            continue
+        }
+        key := fixedBaseKey(p)
+        if fixedBases[key] != nil || seen[key] {
+            continue
+        }
+        seen[key] = true
+        bases = append(bases, p)
+    }
+    fixedBasesMu.RUnlock()
+    if len(bases) == 0 {
+        return
+    }
+    tables := newFixedBaseTables(bases)
+    fixedBasesMu.Lock()
+    for i, p := range bases {
+        if len(fixedBases) >= maxFixedBases {
+            break
+        }
+        fixedBases[fixedBaseKey(p)] = tables[i]
+    }
+    fixedBasesMu.Unlock()
+}
+
+/*
+newFixedBaseTables computes the tables of the given bases, which must not be the
+point at infinity, and converts all their entries to affine coordinates at once.
+*/
+func newFixedBaseTables(bases []*P256) []*fixedBaseTable {
+    const rowSize = 1 << (fixedBaseWindow - 1)
+    entries := make([]point, 0, len(bases)*fixedBaseWindows*rowSize)
+    for _, p := range bases {
+        // base = 2^(w.i).p
+        base := p.point()
+        for i := 0; i < fixedBaseWindows; i++ {
+            row := len(entries)
+            entries = append(entries, base)
+            for j := 1; j < rowSize; j++ {
+                var e point
+                entries = append(entries, *e.add(&entries[len(entries)-1], &base))
+            }
+            base.double(&entries[row+rowSize-1])
+        }
+    }
+    affine := batchToAffine(entries)
+    tables := make([]*fixedBaseTable, len(bases))
+    for t := range tables {
+        tables[t] = new(fixedBaseTable)
+        for i := range tables[t] {
+            copy(tables[t][i][:], affine[(t*fixedBaseWindows+i)*rowSize:])
+        }
+    }
+    return tables
+}
+
+/*
+lookupTable returns the fixed-base table of p, or nil if p has none. Only the points
+in affine coordinates are looked up, since the bases are normalized by Precompute.
+*/
+func lookupTable(p *P256) *fixedBaseTable {
+    if p.proj != nil || p.IsZero() {
+        return nil
+    }
+    fixedBasesMu.RLock()
+    table := fixedBases[fixedBaseKey(p)]
+    fixedBasesMu.RUnlock()
+    return table
+}
+
+/*
+generator returns the generator of the group, whose table is built on the first call.
+*/
+func generator() *P256 {
+    g := &P256{X: CURVE.Gx, Y: CURVE.Gy}
+    generatorOnce.Do(func() {
+        Precompute(g)
+    })
+    return g
+}
+
+/*
+mul sets r to k.P, where P is the base of the table, in constant time. The scalar is
+replaced by its opposite when it is greater than N/2, negating the result, so that it
+is lower than 2^255 and the last digit does not carry.
+*/
+func (table *fixedBaseTable) mul(r *point, k *scalar) {
+    const half = 1 << (fixedBaseWindow - 1)
+    neg := k.isHigh()
+    var kk scalar
+    kk.condNeg(k, neg)
+    var acc, sum point
+    acc.setInfinity()
+    carry := uint64(0)
+    for i := range table {
+        d := kk.window(i*fixedBaseWindow, fixedBaseWindow) + carry
+        // the digits above half are replaced by d - 2^w, with a carry to the next window
+        carry = (d + half - 1) >> fixedBaseWindow
+        d -= carry << fixedBaseWindow
+        // the sign and the absolute value of d, as a two's complement integer
+        sign := d >> 63
+        abs := (d ^ -sign) + sign
+        var e affinePoint
+        for j := range table[i] {
+            cond := ctEqual(uint64(j+1), abs)
+            e.x.sel(&table[i][j].x, &e.x, cond)
+            e.y.sel(&table[i][j].y, &e.y, cond)
+        }
+        var y fieldElement
+        y.neg(&e.y)
+        e.y.sel(&y, &e.y, sign^neg)
+        // e is meaningless when d = 0, and acc is then kept unchanged
+        sum.addAffine(&acc, &e)
+        acc.sel(&acc, &sum, ctEqual(abs, 0))
+    }
+    *r = acc
+}
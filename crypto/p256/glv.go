@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package p256
+
+/*
+secp256k1 has the endomorphism φ(x, y) = (β.x, y), where β is a cube root of unity
+modulo P, which acts on the group as the multiplication by λ, a cube root of unity
+modulo N. Following Gallant, Lambert and Vanstone, a scalar k is split into
+k = k1 + k2.λ mod N, where k1 and k2 have at most 128 bits once their sign is moved
+to the point, so that k.P = k1.P + k2.φ(P) only needs half of the doublings. The
+constants and the decomposition are the ones of libsecp256k1, see
+secp256k1_scalar_split_lambda: k2 = c1.(-b1) + c2.(-b2), with c1 and c2 the rounded
+products of k with g1 = round(2^384.b2/N) and g2 = round(2^384.(-b1)/N), and
+k1 = k - k2.λ.
+*/
+var (
+    glvBeta   = fieldElement(limbsFromHex("7ae96a2b657c07106e64479eac3434e99cf0497512f58995c1396c28719501ee"))
+    glvLambda = scalar(limbsFromHex("5363ad4cc05c30e0a5261c028812645a122e22ea20816678df02967c1b23bd72"))
+
+    glvMinusB1 = scalar(limbsFromHex("e4437ed6010e88286f547fa90abfe4c3"))
+    glvMinusB2 = scalar(limbsFromHex("fffffffffffffffffffffffffffffffe8a280ac50774346dd765cda83db1562c"))
+    glvG1      = scalar(limbsFromHex("3086d221a7d46bcde86c90e49284eb153daa8a1471e8ca7fe893209a45dbb031"))
+    glvG2      = scalar(limbsFromHex("e4437ed6010e88286f547fa90abfe4c4221208ac9df506c61571b4ae8ac47f71"))
+
+    glvMinusLambda = *new(scalar).neg(&glvLambda)
+)
+
+// glvWindow is the width in bits of the windows of scalarMult.
+const glvWindow = 4
+
+// glvBits is the maximum length in bits of the halves of a split scalar.
+const glvBits = 128
+
+/*
+endomorphism sets r to φ(p) = λ.p.
+*/
+func (r *point) endomorphism(p *point) *point {
+    r.x.mul(&p.x, &glvBeta)
+    r.y, r.z = p.y, p.z
+    return r
+}
+
+/*
+splitLambda returns k1 and k2 lower than 2^128 and their signs neg1 and neg2, such
+that k = (-1)^neg1.k1 + (-1)^neg2.k2.λ mod N.
+*/
+func (k *scalar) splitLambda() (k1, k2 scalar, neg1, neg2 uint64) {
+    c1 := mulShift384(k, &glvG1)
+    c2 := mulShift384(k, &glvG2)
+    c1.mul(&c1, &glvMinusB1)
+    c2.mul(&c2, &glvMinusB2)
+    k2.add(&c1, &c2)
+    k1.mul(&k2, &glvMinusLambda)
+    k1.add(&k1, k)
+    neg1 = k1.isHigh()
+    neg2 = k2.isHigh()
+    k1.condNeg(&k1, neg1)
+    k2.condNeg(&k2, neg2)
+    return k1, k2, neg1, neg2
+}
+
+/*
+scalarMult sets r to k.p in constant time, with the endomorphism and a fixed window
+of glvWindow bits over both halves of the scalar. p may be the point at infinity.
+*/
+func (r *point) scalarMult(p *point, k *scalar) *point {
+    k1, k2, neg1, neg2 := k.splitLambda()
+    // t1[i] = i.(-1)^neg1.p and t2[i] = i.(-1)^neg2.φ(p)
+    var t1, t2 [1 << glvWindow]point
+    t1[0].setInfinity()
+    t1[1].condNeg(p, neg1)
+    for i := 2; i < len(t1); i++ {
+        t1[i].add(&t1[i-1], &t1[1])
+    }
+    for i := range t2 {
+        t2[i].endomorphism(&t1[i])
+        t2[i].condNeg(&t2[i], neg1^neg2)
+    }
+    var acc, e point
+    acc.setInfinity()
+    for i := glvBits - glvWindow; i >= 0; i -= glvWindow {
+        for j := 0; j < glvWindow; j++ {
+            acc.double(&acc)
+        }
+        e.lookup(t1[:], k1.window(i, glvWindow))
+        acc.add(&acc, &e)
+        e.lookup(t2[:], k2.window(i, glvWindow))
+        acc.add(&acc, &e)
+    }
+    *r = acc
+    return r
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package p256
+
+import (
+    "math/big"
+
+    "github.com/ing-bank/zkrp/crypto/hashtocurve"
+)
+
+// HashToCurveSuite is the RFC 9380 suite implemented by HashToCurve.
+const HashToCurveSuite = "secp256k1_XMD:SHA-256_SSWU_RO_"
+
+func hexToBig(s string) *big.Int {
+    k, _ := new(big.Int).SetString(s, 16)
+    return k
+}
+
+/*
+Parameters of the curve E': y^2 = x^3 + A'.x + B', which is 3-isogenous to
+secp256k1, and of the isogeny map, from RFC 9380 section 8.7 and appendix E.1.
+*/
+var (
+    sswuA = hexToBig("3f8731abdd661adca08a5558f0f5d272e953d363cb6f0e5d405447c01a444533")
+    sswuB = big.NewInt(1771)
+    sswuZ = big.NewInt(-11)
+
+    isoXNum = []*big.Int{
+        hexToBig("8e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38daaaaa8c7"),
+        hexToBig("07d3d4c80bc321d5b9f315cea7fd44c5d595d2fc0bf63b92dfff1044f17c6581"),
+        hexToBig("534c328d23f234e6e2a413deca25caece4506144037c40314ecbd0b53d9dd262"),
+        hexToBig("8e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38daaaaa88c"),
+    }
+    isoXDen = []*big.Int{
+        hexToBig("d35771193d94918a9ca34ccbb7b640dd86cd409542f8487d9fe6b745781eb49b"),
+        hexToBig("edadc6f64383dc1df7c4b2d51b54225406d36b641f5e41bbc52a56612a8c6d14"),
+        big.NewInt(1),
+    }
+    isoYNum = []*big.Int{
+        hexToBig("4bda12f684bda12f684bda12f684bda12f684bda12f684bda12f684b8e38e23c"),
+        hexToBig("c75e0c32d5cb7c0fa9d0a54b12a0a6d5647ab046d686da6fdffc90fc201d71a3"),
+        hexToBig("29a6194691f91a73715209ef6512e576722830a201be2018a765e85a9ecee931"),
+        hexToBig("2f684bda12f684bda12f684bda12f684bda12f684bda12f684bda12f38e38d84"),
+    }
+    isoYDen = []*big.Int{
+        hexToBig("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffff93b"),
+        hexToBig("7a06534bb8bdb49fd5e9e6632722c2989467c1bfc8e8d978dfb425d2685c2573"),
+        hexToBig("6484aa716545ca2cf3a70c3fa8fe337e0a3d21162f0d6299a7bf8192bfd2a76f"),
+        big.NewInt(1),
+    }
+)
+
+/*
+HashToCurve hashes msg to a point of secp256k1 following the suite
+secp256k1_XMD:SHA-256_SSWU_RO_ from RFC 9380, for the domain separation tag dst.
+The discrete logarithm of the output with respect to any other point is unknown,
+so it can be used to derive generators in a way that anyone can reproduce.
+*/
+func HashToCurve(dst, msg []byte) (*P256, error) {
+    u, err := hashtocurve.HashToField(msg, dst, CURVE.P, 2, 1)
+    if err != nil {
+        return nil, err
+    }
+    Q0 := mapToCurve(u[0][0])
+    Q1 := mapToCurve(u[1][0])
+    // the cofactor of secp256k1 is 1
+    return new(P256).Add(Q0, Q1).Normalize(), nil
+}
+
+/*
+mapToCurve maps a field element to secp256k1, using the simplified SWU map to E'
+followed by the isogeny map.
+*/
+func mapToCurve(u *big.Int) *P256 {
+    x, y := mapToCurveSSWU(u)
+    return isoMap(x, y)
+}
+
+/*
+mapToCurveSSWU implements the simplified Shallue-van de Woestijne-Ulas method from
+RFC 9380 section 6.6.2, for the curve E'.
+*/
+func mapToCurveSSWU(u *big.Int) (*big.Int, *big.Int) {
+    p := CURVE.P
+    mod := func(k *big.Int) *big.Int { return k.Mod(k, p) }
+    g := func(x *big.Int) *big.Int {
+        // x^3 + A'.x + B'
+        gx := new(big.Int).Mul(x, x)
+        gx.Add(gx, sswuA)
+        gx.Mul(gx, x)
+        gx.Add(gx, sswuB)
+        return mod(gx)
+    }
+
+    // tv1 = 1 / (Z^2.u^4 + Z.u^2)
+    zu2 := mod(new(big.Int).Mul(u, u))
+    zu2 = mod(zu2.Mul(zu2, sswuZ))
+    tv1 := new(big.Int).Mul(zu2, zu2)
+    tv1 = mod(tv1.Add(tv1, zu2))
+
+    var x1 *big.Int
+    if tv1.Sign() == 0 {
+        // x1 = B' / (Z.A')
+        x1 = mod(new(big.Int).Mul(sswuZ, sswuA))
+        x1.ModInverse(x1, p)
+        x1 = mod(x1.Mul(x1, sswuB))
+    } else {
+        // x1 = (-B' / A').(1 + tv1)
+        tv1.ModInverse(tv1, p)
+        x1 = new(big.Int).ModInverse(sswuA, p)
+        x1.Mul(x1, sswuB)
+        x1.Neg(x1)
+        x1 = mod(x1.Mul(x1, tv1.Add(tv1, big.NewInt(1))))
+    }
+
+    x := x1
+    y := new(big.Int).ModSqrt(g(x1), p)
+    if y == nil {
+        // x2 = Z.u^2.x1
+        x = mod(new(big.Int).Mul(zu2, x1))
+        y = new(big.Int).ModSqrt(g(x), p)
+    }
+    if u.Bit(0) != y.Bit(0) {
+        y = mod(y.Neg(y))
+    }
+    return x, y
+}
+
+/*
+isoMap maps a point of E' to secp256k1, see RFC 9380 appendix E.1.
+*/
+func isoMap(x, y *big.Int) *P256 {
+    p := CURVE.P
+    poly := func(k []*big.Int) *big.Int {
+        // Horner's method
+        res := new(big.Int).Set(k[len(k)-1])
+        for i := len(k) - 2; i >= 0; i-- {
+            res.Mul(res, x)
+            res.Add(res, k[i])
+            res.Mod(res, p)
+        }
+        return res
+    }
+    xDen := poly(isoXDen)
+    yDen := poly(isoYDen)
+    if xDen.Sign() == 0 || yDen.Sign() == 0 {
+        // exceptional case of the isogeny
+        return new(P256).SetInfinity()
+    }
+    xOut := poly(isoXNum)
+    xOut.Mul(xOut, xDen.ModInverse(xDen, p))
+    xOut.Mod(xOut, p)
+    yOut := poly(isoYNum)
+    yOut.Mul(yOut, yDen.ModInverse(yDen, p))
+    yOut.Mul(yOut, y)
+    yOut.Mod(yOut, p)
+    return &P256{X: xOut, Y: yOut}
+}
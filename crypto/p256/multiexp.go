@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package p256
+
+import (
+    "math/big"
+)
+
+/*
+Multi-scalar multiplication computes k[0].P[0] + ... + k[n-1].P[n-1] sharing the
+doublings between all the terms. Every term is first split with the endomorphism,
+see scalarMult, into two terms whose scalars have 128 bits, which halves the number
+of doublings and of windows. Straus' method keeps a table of small multiples of
+each point and adds one of them per window, which is the fastest for a few terms.
+Pippenger's method sorts the points into buckets according to the window of their
+scalar, so the cost per term does not depend on the table size, and it is faster
+when there are many terms. Below that number of terms, the terms whose point has a
+fixed-base table, see Precompute, only need one addition per window and no doubling.
+Unlike ScalarMult, the multi-scalar multiplication does not run in constant time.
+*/
+
+// strausWindow is the width in bits of the window used by Straus' method.
+const strausWindow = 4
+
+// pippengerThreshold is the number of terms from which Pippenger's method is used.
+const pippengerThreshold = 64
+
+/*
+MultiScalarMult returns the sum of scalars[i].points[i]. The scalars may be zero or
+negative, and they are reduced modulo the order of the group. It panics if points
+and scalars do not have the same length.
+*/
+func MultiScalarMult(points []*P256, scalars []*big.Int) *P256 {
+    if len(points) != len(scalars) {
+        panic("p256: number of points and scalars must be equal")
+    }
+    // Pippenger's method is cheaper than the fixed-base tables for many terms
+    useTables := len(points) < pippengerThreshold
+    ps := make([]point, 0, 2*len(points))
+    ks := make([]scalar, 0, 2*len(points))
+    var acc point
+    acc.setInfinity()
+    for i, p := range points {
+        if p.IsZero() {
+            continue
+        }
+        var k scalar
+        if k.setBig(scalars[i]).isZero() == 1 {
+            continue
+        }
+        if table := lookupTable(p); useTables && table != nil {
+            var r point
+            table.mul(&r, &k)
+            acc.add(&acc, &r)
+            continue
+        }
+        k1, k2, neg1, neg2 := k.splitLambda()
+        var q, phi point
+        q = p.point()
+        phi.endomorphism(&q)
+        ps = append(ps, *q.condNeg(&q, neg1), *phi.condNeg(&phi, neg2))
+        ks = append(ks, k1, k2)
+    }
+    if len(ps) < pippengerThreshold {
+        acc.add(&acc, straus(ps, ks))
+    } else {
+        acc.add(&acc, pippenger(ps, ks))
+    }
+    return new(P256).setPoint(&acc)
+}
+
+/*
+maxBitLen returns the length in bits of the largest scalar rounded up to a multiple
+of c.
+*/
+func maxBitLen(scalars []scalar, c int) int {
+    max := 0
+    for i := range scalars {
+        if n := scalars[i].bitLen(); n > max {
+            max = n
+        }
+    }
+    return (max + c - 1) / c * c
+}
+
+/*
+straus computes the multi-scalar multiplication with a fixed window of
+strausWindow bits.
+*/
+func straus(points []point, scalars []scalar) *point {
+    // tables[t][i] = (i+1).points[t]
+    tables := make([][1<<strausWindow - 1]point, len(points))
+    for t := range points {
+        tables[t][0] = points[t]
+        for i := 1; i < len(tables[t]); i++ {
+            tables[t][i].add(&tables[t][i-1], &points[t])
+        }
+    }
+    acc := new(point).setInfinity()
+    top := maxBitLen(scalars, strausWindow)
+    for i := top - strausWindow; i >= 0; i -= strausWindow {
+        for j := 0; j < strausWindow; j++ {
+            acc.double(acc)
+        }
+        for t := range scalars {
+            if w := scalars[t].window(i, strausWindow); w != 0 {
+                acc.add(acc, &tables[t][w-1])
+            }
+        }
+    }
+    return acc
+}
+
+/*
+pippengerWindow returns the width in bits of the windows that minimizes the number
+of additions of Pippenger's method for n terms of 128 bits, i.e. (128/c).(n + 2^(c+1)).
+*/
+func pippengerWindow(n int) int {
+    best, cost := 1, -1
+    for c := 1; c <= 16; c++ {
+        k := (glvBits + c - 1) / c * (n + 1<<uint(c+1))
+        if cost < 0 || k < cost {
+            best, cost = c, k
+        }
+    }
+    return best
+}
+
+/*
+pippenger computes the multi-scalar multiplication with the bucket method. The
+points must not be the point at infinity, since they are converted to affine
+coordinates.
+*/
+func pippenger(points []point, scalars []scalar) *point {
+    affine := batchToAffine(points)
+    c := pippengerWindow(len(points))
+    buckets := make([]point, 1<<uint(c)-1)
+    acc := new(point).setInfinity()
+    top := maxBitLen(scalars, c)
+    for i := top - c; i >= 0; i -= c {
+        for j := 0; j < c; j++ {
+            acc.double(acc)
+        }
+        for b := range buckets {
+            buckets[b].setInfinity()
+        }
+        for t := range scalars {
+            if w := scalars[t].window(i, c); w != 0 {
+                buckets[w-1].addAffine(&buckets[w-1], &affine[t])
+            }
+        }
+        // sum = buckets[b] + ... + buckets[len-1], so that adding every partial
+        // sum gives (b+1).buckets[b] for each b
+        var sum point
+        sum.setInfinity()
+        for b := len(buckets) - 1; b >= 0; b-- {
+            sum.add(&sum, &buckets[b])
+            acc.add(acc, &sum)
+        }
+    }
+    return acc
+}
@@ -18,116 +18,84 @@
 package p256
 
 import (
+    "crypto/elliptic"
     "math/big"
-
-    "github.com/ethereum/go-ethereum/crypto/secp256k1"
 )
 
+/*
+MyBitCurve holds the parameters of secp256k1, y² = x³ + B. It implements
+elliptic.Curve with the arithmetic of P256, where the point at infinity is (0, 0).
+*/
 type MyBitCurve struct {
-    secp256k1.BitCurve
+    P       *big.Int // the order of the underlying field
+    N       *big.Int // the order of the base point
+    B       *big.Int // the constant of the curve equation
+    Gx, Gy  *big.Int // (x,y) of the base point
+    BitSize int      // the size of the underlying field
 }
 
-// Add returns the sum of (x1,y1) and (x2,y2)
-func (BitCurve *MyBitCurve) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
-    z := new(big.Int).SetInt64(1)
-    return BitCurve.affineFromJacobian(BitCurve.addJacobian(x1, y1, z, x2, y2, z))
+// See SEC 2 section 2.4.1, http://www.secg.org/sec2-v2.pdf
+var theCurve = &MyBitCurve{
+    P:       hexToBig("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f"),
+    N:       hexToBig("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141"),
+    B:       big.NewInt(7),
+    Gx:      hexToBig("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+    Gy:      hexToBig("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"),
+    BitSize: 256,
 }
 
-// addJacobian takes two points in Jacobian coordinates, (x1, y1, z1) and
-// (x2, y2, z2) and returns their sum, also in Jacobian form.
-func (BitCurve *MyBitCurve) addJacobian(x1, y1, z1, x2, y2, z2 *big.Int) (*big.Int, *big.Int, *big.Int) {
-    // See http://hyperelliptic.org/EFD/g1p/auto-shortw-jacobian-0.html#addition-add-2007-bl
-    z1z1 := new(big.Int).Mul(z1, z1)
-    z1z1.Mod(z1z1, BitCurve.P)
-    z2z2 := new(big.Int).Mul(z2, z2)
-    z2z2.Mod(z2z2, BitCurve.P)
-
-    u1 := new(big.Int).Mul(x1, z2z2)
-    u1.Mod(u1, BitCurve.P)
-    u2 := new(big.Int).Mul(x2, z1z1)
-    u2.Mod(u2, BitCurve.P)
-    h := new(big.Int).Sub(u2, u1)
-    if h.Sign() == -1 {
-        h.Add(h, BitCurve.P)
-    }
-    i := new(big.Int).Lsh(h, 1)
-    i.Mul(i, i)
-    j := new(big.Int).Mul(h, i)
-
-    s1 := new(big.Int).Mul(y1, z2)
-    s1.Mul(s1, z2z2)
-    s1.Mod(s1, BitCurve.P)
-    s2 := new(big.Int).Mul(y2, z1)
-    s2.Mul(s2, z1z1)
-    s2.Mod(s2, BitCurve.P)
-    r := new(big.Int).Sub(s2, s1)
-    if r.Sign() == -1 {
-        r.Add(r, BitCurve.P)
-    }
-    r.Lsh(r, 1)
-    v := new(big.Int).Mul(u1, i)
-
-    x3 := new(big.Int).Set(r)
-    x3.Mul(x3, x3)
-    x3.Sub(x3, j)
-    x3.Sub(x3, v)
-    x3.Sub(x3, v)
-    x3.Mod(x3, BitCurve.P)
-
-    y3 := new(big.Int).Set(r)
-    v.Sub(v, x3)
-    y3.Mul(y3, v)
-    s1.Mul(s1, j)
-    s1.Lsh(s1, 1)
-    y3.Sub(y3, s1)
-    y3.Mod(y3, BitCurve.P)
+// S256 returns a BitCurve which implements secp256k1.
+func S256() *MyBitCurve {
+    return theCurve
+}
 
-    z3 := new(big.Int).Add(z1, z2)
-    z3.Mul(z3, z3)
-    z3.Sub(z3, z1z1)
-    if z3.Sign() == -1 {
-        z3.Add(z3, BitCurve.P)
+// Params returns the parameters of the curve.
+func (BitCurve *MyBitCurve) Params() *elliptic.CurveParams {
+    return &elliptic.CurveParams{
+        P:       BitCurve.P,
+        N:       BitCurve.N,
+        B:       BitCurve.B,
+        Gx:      BitCurve.Gx,
+        Gy:      BitCurve.Gy,
+        BitSize: BitCurve.BitSize,
+        Name:    "secp256k1",
     }
-    z3.Sub(z3, z2z2)
-    if z3.Sign() == -1 {
-        z3.Add(z3, BitCurve.P)
-    }
-    z3.Mul(z3, h)
-    z3.Mod(z3, BitCurve.P)
+}
 
-    return x3, y3, z3
+// IsOnCurve returns true if the point (x,y) lies on the curve.
+func (BitCurve *MyBitCurve) IsOnCurve(x, y *big.Int) bool {
+    p := &P256{X: x, Y: y}
+    return !p.IsZero() && p.Validate() == nil
 }
 
-func (BitCurve *MyBitCurve) affineFromJacobian(x, y, z *big.Int) (xOut, yOut *big.Int) {
-    if z.Sign() == 0 {
-        return new(big.Int), new(big.Int)
-    }
-    zinv := new(big.Int).ModInverse(z, BitCurve.P)
-    zinvsq := new(big.Int).Mul(zinv, zinv)
+// Add returns the sum of (x1,y1) and (x2,y2)
+func (BitCurve *MyBitCurve) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+    return coordinates(new(P256).Add(&P256{X: x1, Y: y1}, &P256{X: x2, Y: y2}))
+}
 
-    xOut = new(big.Int).Mul(x, zinvsq)
-    xOut.Mod(xOut, BitCurve.P)
-    zinvsq.Mul(zinvsq, zinv)
-    yOut = new(big.Int).Mul(y, zinvsq)
-    yOut.Mod(yOut, BitCurve.P)
-    return
+// Double returns 2*(x,y)
+func (BitCurve *MyBitCurve) Double(x1, y1 *big.Int) (*big.Int, *big.Int) {
+    return coordinates(new(P256).Double(&P256{X: x1, Y: y1}))
 }
 
-var theCurve = new(MyBitCurve)
+// ScalarMult returns k*(Bx,By) where k is a number in big-endian form.
+func (BitCurve *MyBitCurve) ScalarMult(Bx, By *big.Int, k []byte) (*big.Int, *big.Int) {
+    return coordinates(new(P256).ScalarMult(&P256{X: Bx, Y: By}, new(big.Int).SetBytes(k)))
+}
 
-func init() {
-    // See SEC 2 section 2.7.1
-    // curve parameters taken from:
-    // http://www.secg.org/sec2-v2.pdf
-    theCurve.P, _ = new(big.Int).SetString("0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 0)
-    theCurve.N, _ = new(big.Int).SetString("0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 0)
-    theCurve.B, _ = new(big.Int).SetString("0x0000000000000000000000000000000000000000000000000000000000000007", 0)
-    theCurve.Gx, _ = new(big.Int).SetString("0x79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", 0)
-    theCurve.Gy, _ = new(big.Int).SetString("0x483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8", 0)
-    theCurve.BitSize = 256
+// ScalarBaseMult returns k*G, where G is the base point of the group and k is an
+// integer in big-endian form.
+func (BitCurve *MyBitCurve) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
+    return coordinates(new(P256).ScalarBaseMult(new(big.Int).SetBytes(k)))
 }
 
-// S256 returns a BitCurve which implements secp256k1.
-func S256() *MyBitCurve {
-    return theCurve
+/*
+coordinates returns the affine coordinates of p, which are (0, 0) for the point at
+infinity.
+*/
+func coordinates(p *P256) (*big.Int, *big.Int) {
+    if p.IsZero() {
+        return new(big.Int), new(big.Int)
+    }
+    return p.affine()
 }
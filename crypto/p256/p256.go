@@ -23,6 +23,7 @@ package p256
 import (
     "bytes"
     "crypto/sha256"
+    "encoding/json"
     "errors"
     "math/big"
     "strconv"
@@ -35,17 +36,41 @@ var (
     CURVE = S256()
 )
 
+// PointSize is the length in bytes of a compressed point, see MarshalBinary.
+const PointSize = 33
+
+/*
+Errors returned when decoding untrusted points. The cofactor of secp256k1 is 1, so
+every point of the curve belongs to the group.
+*/
+var (
+    ErrInvalidEncoding = errors.New("p256: invalid encoding")
+    ErrNotOnCurve      = errors.New("p256: point is not on the curve")
+    // ErrInfinity is returned by callers that do not accept the point at infinity.
+    ErrInfinity = errors.New("p256: unexpected point at infinity")
+)
+
 /*
-Elliptic Curve Point struct.
+Elliptic Curve Point struct. The point at infinity, which is the identity of the
+group, is represented by nil coordinates. The coordinates (0, 0), which do not
+satisfy the curve equation, are also accepted as the point at infinity.
+The results of the arithmetic are kept in projective coordinates, so that the group
+operations do not compute a field inversion each. X and Y are nil until Normalize,
+which the encodings and Equals do not need.
 */
 type P256 struct {
     X, Y *big.Int
+    // proj holds the projective coordinates, or is nil if X and Y are affine.
+    proj *point
 }
 
 /*
 IsZero returns true if and only if the elliptic curve point is the point at infinity.
 */
 func (p *P256) IsZero() bool {
+    if p.proj != nil {
+        return p.proj.isInfinity() == 1
+    }
     c1 := p.X == nil || p.Y == nil
     if !c1 {
         z := new(big.Int).SetInt64(0)
@@ -54,50 +79,97 @@ func (p *P256) IsZero() bool {
     return true
 }
 
+/*
+Equals returns true if and only if p and q are the same point. The projective
+coordinates are compared without normalizing them.
+*/
 func (p *P256) Equals(q *P256) bool {
-    return p.X.Cmp(q.X) == 0 && p.Y.Cmp(q.Y) == 0
+    a, b := p.point(), q.point()
+    return a.equal(&b) == 1
 }
 
 func (e *P256) Copy() *P256 {
+    if e.IsZero() {
+        return new(P256).SetInfinity()
+    }
+    if e.proj != nil {
+        return new(P256).setPoint(e.proj)
+    }
     var xx big.Int
     var yy big.Int
     xx.Set(e.X)
     yy.Set(e.Y)
-    f := P256{&xx, &yy}
+    f := P256{X: &xx, Y: &yy}
     return &f
 }
 
+/*
+Normalize converts p to affine coordinates, so that X and Y can be read directly,
+and returns p.
+*/
+func (p *P256) Normalize() *P256 {
+    if p.IsZero() {
+        return p.SetInfinity()
+    }
+    p.X, p.Y = p.affine()
+    p.proj = nil
+    return p
+}
+
 /*
 Neg returns the inverse of the given elliptic curve point.
 */
 func (p *P256) Neg(a *P256) *P256 {
-    // (X, Y) -> (X, X + Y)
+    // (X, Y, Z) -> (X, -Y, Z)
     if a.IsZero() {
         return p.SetInfinity()
+    } else if a.proj != nil {
+        q := a.point()
+        return p.setPoint(q.condNeg(&q, 1))
     }
-    one := new(big.Int).SetInt64(1)
-    mone := new(big.Int).Sub(CURVE.N, one)
-    p.ScalarMult(p, mone)
+    var y fieldElement
+    y.setBig(a.Y)
+    p.X = a.X
+    p.Y = y.neg(&y).big()
+    p.proj = nil
     return p
 }
 
 /*
-Input points must be distinct
+Add returns the sum of a and b. The inputs may be equal, inverse of each other or
+the point at infinity.
 */
 func (p *P256) Add(a, b *P256) *P256 {
     if a.IsZero() {
-        p.X = b.X
-        p.Y = b.Y
-        return p
+        return p.set(b)
     } else if b.IsZero() {
-        p.X = b.X
-        p.Y = b.Y
-        return p
+        return p.set(a)
+    }
+    var r point
+    if b.proj == nil {
+        q, c := a.point(), b.affinePoint()
+        return p.setPoint(r.addAffine(&q, &c))
+    } else if a.proj == nil {
+        q, c := b.point(), a.affinePoint()
+        return p.setPoint(r.addAffine(&q, &c))
+    }
+    q, c := a.point(), b.point()
+    return p.setPoint(r.add(&q, &c))
+}
 
+/*
+set copies the coordinates of a into p.
+*/
+func (p *P256) set(a *P256) *P256 {
+    if a.IsZero() {
+        return p.SetInfinity()
     }
-    resx, resy := CURVE.Add(a.X, a.Y, b.X, b.Y)
-    p.X = resx
-    p.Y = resy
+    if a.proj != nil {
+        return p.setPoint(a.proj)
+    }
+    p.X = a.X
+    p.Y = a.Y
+    p.proj = nil
     return p
 }
 
@@ -108,73 +180,45 @@ func (p *P256) Double(a *P256) *P256 {
     if a.IsZero() {
         return p.SetInfinity()
     }
-    resx, resy := CURVE.Double(a.X, a.Y)
-    p.X = resx
-    p.Y = resy
-    return p
+    var r point
+    q := a.point()
+    return p.setPoint(r.double(&q))
 }
 
 /*
-ScalarMul encapsulates the scalar Multiplication Algorithm from secP256k1.
+ScalarMul encapsulates the scalar Multiplication Algorithm from secP256k1. The
+scalar may be zero or negative, and it is reduced modulo the order of the group.
+The fixed-base table of a is used if it was built by Precompute and a is in affine
+coordinates, and the endomorphism of the curve otherwise. Both run in constant time with respect to the
+scalar.
 */
 func (p *P256) ScalarMult(a *P256, n *big.Int) *P256 {
-    if a.IsZero() {
-        return p.SetInfinity()
+    var k scalar
+    var r point
+    k.setBig(n)
+    if table := lookupTable(a); table != nil {
+        table.mul(&r, &k)
+        return p.setPoint(&r)
     }
-    cmp := n.Cmp(big.NewInt(0))
-    if cmp == 0 {
-        return p.SetInfinity()
-    }
-    n = bn.Mod(n, CURVE.N)
-    bns := n.Bytes()
-    resx, resy := CURVE.ScalarMult(a.X, a.Y, bns)
-    p.X = resx
-    p.Y = resy
-    return p
+    q := a.point()
+    return p.setPoint(r.scalarMult(&q, &k))
 }
 
 /*
-ScalarBaseMult returns the Scalar Multiplication by the base generator.
+ScalarBaseMult returns the Scalar Multiplication by the base generator, using its
+fixed-base table.
 */
 func (p *P256) ScalarBaseMult(n *big.Int) *P256 {
-    cmp := n.Cmp(big.NewInt(0))
-    if cmp == 0 {
-        return p.SetInfinity()
-    }
-    n = bn.Mod(n, CURVE.N)
-    bns := n.Bytes()
-    resx, resy := CURVE.ScalarBaseMult(bns)
-    p.X = resx
-    p.Y = resy
-    return p
+    return p.ScalarMult(generator(), n)
 }
 
 /*
 Multiply actually is reponsible for the addition of elliptic curve points.
-The name here is to maintain compatibility with bn256 interface.
-This algorithm verifies if the given elliptic curve points are equal, in which case it
-returns the result of Double function, otherwise it returns the result of Add function.
+The name here is to maintain compatibility with bn256 interface. It is the same as
+Add.
 */
 func (p *P256) Multiply(a, b *P256) *P256 {
-    if a.IsZero() {
-        p.X = b.X
-        p.Y = b.Y
-        return p
-    } else if b.IsZero() {
-        p.X = a.X
-        p.Y = a.Y
-        return p
-    }
-    if a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0 {
-        resx, resy := CURVE.Double(a.X, a.Y)
-        p.X = resx
-        p.Y = resy
-        return p
-    }
-    resx, resy := CURVE.Add(a.X, a.Y, b.X, b.Y)
-    p.X = resx
-    p.Y = resy
-    return p
+    return p.Add(a, b)
 }
 
 /*
@@ -183,6 +227,7 @@ SetInfinity sets the given elliptic curve point to the point at infinity.
 func (p *P256) SetInfinity() *P256 {
     p.X = nil
     p.Y = nil
+    p.proj = nil
     return p
 }
 
@@ -191,7 +236,11 @@ String returns the readable representation of the given elliptic curve point, i.
 the tuple formed by X and Y coordinates.
 */
 func (p *P256) String() string {
-    return "P256(" + p.X.String() + "," + p.Y.String() + ")"
+    if p.IsZero() {
+        return "P256(infinity)"
+    }
+    x, y := p.affine()
+    return "P256(" + x.String() + "," + y.String() + ")"
 }
 
 /*
@@ -203,6 +252,9 @@ This implementation is based on the paper:
 Short signatures from the Weil pairing
 Boneh, Lynn and Shacham
 Journal of Cryptology, September 2004, Volume 17, Issue 4, pp 297–319
+
+Deprecated: MapToGroup has no domain separation and is not standardized. Use
+HashToCurve instead.
 */
 func MapToGroup(m string) (*P256, error) {
     var (
@@ -262,15 +314,119 @@ IsOnCurve returns TRUE if and only if p has coordinates X and Y that satisfy the
 Elliptic Curve equation: y^2 = x^3 + 7.
 */
 func (p *P256) IsOnCurve() bool {
+    if p.IsZero() {
+        return false
+    }
+    // y² = x³ + 7
+    q := p.point()
+    return q.isOnCurve() == 1
+}
+
+/*
+MarshalBinary encodes the point in compressed form: 1 byte with the parity of Y
+(0x02 if even, 0x03 if odd) followed by the 32 bytes of X. The point at infinity
+is encoded as 33 zero bytes.
+*/
+func (p *P256) MarshalBinary() ([]byte, error) {
+    ret := make([]byte, PointSize)
+    if p.IsZero() {
+        return ret, nil
+    }
+    x, y := p.affine()
+    ret[0] = 2 + byte(y.Bit(0))
+    xBytes := x.Bytes()
+    copy(ret[PointSize-len(xBytes):], xBytes)
+    return ret, nil
+}
+
+/*
+UnmarshalBinary decodes a point encoded with MarshalBinary, recovering Y from X. It
+returns ErrInvalidEncoding or ErrNotOnCurve if data is not the encoding of a point.
+*/
+func (p *P256) UnmarshalBinary(data []byte) error {
+    if len(data) != PointSize {
+        return ErrInvalidEncoding
+    }
+    if data[0] == 0 {
+        for _, b := range data[1:] {
+            if b != 0 {
+                return ErrInvalidEncoding
+            }
+        }
+        p.SetInfinity()
+        return nil
+    }
+    if data[0] != 2 && data[0] != 3 {
+        return ErrInvalidEncoding
+    }
+    var b [32]byte
+    var x, y, fx, minus fieldElement
+    copy(b[:], data[1:])
+    if x.setBytes(&b) == 0 {
+        return ErrInvalidEncoding
+    }
     // y² = x³ + 7
-    y2 := new(big.Int).Mul(p.Y, p.Y)
-    y2.Mod(y2, CURVE.P)
+    fx.square(&x)
+    fx.mul(&fx, &x)
+    fx.add(&fx, &fieldElement{7})
+    if y.sqrt(&fx) == 0 {
+        return ErrNotOnCurve
+    }
+    minus.neg(&y)
+    y.sel(&minus, &y, y.isOdd()^uint64(data[0]&1))
+    p.X = x.big()
+    p.Y = y.big()
+    p.proj = nil
+    return nil
+}
 
-    x3 := new(big.Int).Mul(p.X, p.X)
-    x3.Mul(x3, p.X)
+/*
+Validate returns nil if p is the point at infinity or a point of the curve with
+coordinates reduced modulo P, and ErrNotOnCurve otherwise.
+*/
+func (p *P256) Validate() error {
+    if p.IsZero() {
+        return nil
+    }
+    if p.proj == nil && (p.X.Sign() < 0 || p.X.Cmp(CURVE.P) >= 0 || p.Y.Sign() < 0 || p.Y.Cmp(CURVE.P) >= 0) {
+        return ErrNotOnCurve
+    }
+    if !p.IsOnCurve() {
+        return ErrNotOnCurve
+    }
+    return nil
+}
 
-    x3.Add(x3, new(big.Int).SetInt64(7))
-    x3.Mod(x3, CURVE.P)
+/*
+MarshalJSON encodes the affine coordinates X and Y of the point, which are null for
+the point at infinity.
+*/
+func (p *P256) MarshalJSON() ([]byte, error) {
+    var coordinates struct {
+        X, Y *big.Int
+    }
+    coordinates.X, coordinates.Y = p.affine()
+    return json.Marshal(coordinates)
+}
 
-    return x3.Cmp(y2) == 0
+/*
+UnmarshalJSON decodes the coordinates X and Y of the point, in the format of the
+default JSON encoding of P256, and checks them with Validate.
+*/
+func (p *P256) UnmarshalJSON(data []byte) error {
+    var coordinates struct {
+        X, Y *big.Int
+    }
+    if err := json.Unmarshal(data, &coordinates); err != nil {
+        return err
+    }
+    if (coordinates.X == nil) != (coordinates.Y == nil) {
+        return ErrInvalidEncoding
+    }
+    q := &P256{X: coordinates.X, Y: coordinates.Y}
+    if err := q.Validate(); err != nil {
+        return err
+    }
+    p.set(q)
+    return nil
 }
@@ -19,7 +19,10 @@ package p256
 
 import (
     "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
     "math/big"
+    "sync"
     "testing"
 )
 
@@ -45,7 +48,8 @@ func TestAdd(t *testing.T) {
     A2x, A2y := curve.ScalarBaseMult(a2)
     p2 := &P256{X: A2x, Y: A2y}
     p3 := p1.Add(p1, p2)
-    sa := new(big.Int).SetInt64(-88).Bytes()
+    // -88 mod N, since Bytes ignores the sign
+    sa := new(big.Int).Sub(curve.N, new(big.Int).SetInt64(88)).Bytes()
     sAx, sAy := curve.ScalarBaseMult(sa)
     sp := &P256{X: sAx, Y: sAy}
     p4 := p3.Add(p3, sp)
@@ -55,6 +59,50 @@ func TestAdd(t *testing.T) {
     }
 }
 
+func TestAddDoubling(t *testing.T) {
+    p1 := new(P256).ScalarBaseMult(new(big.Int).SetInt64(71))
+    p2 := new(P256).ScalarBaseMult(new(big.Int).SetInt64(142))
+    sum := new(P256).Add(p1, p1)
+    if !sum.Equals(p2) {
+        t.Errorf("Assert failure: P + P should be equal to 2P")
+    }
+    p1.Add(p1, p1)
+    if !p1.Equals(p2) {
+        t.Errorf("Assert failure: P + P should be equal to 2P when the receiver is an input")
+    }
+}
+
+func TestAddIdentity(t *testing.T) {
+    p1 := new(P256).ScalarBaseMult(new(big.Int).SetInt64(71))
+    zero := new(P256).SetInfinity()
+    if !new(P256).Add(p1, zero).Equals(p1) || !new(P256).Add(zero, p1).Equals(p1) {
+        t.Errorf("Assert failure: P + O should be equal to P")
+    }
+    if !new(P256).Add(zero, zero).IsZero() {
+        t.Errorf("Assert failure: O + O should be equal to O")
+    }
+    minus := new(P256).Neg(p1)
+    if !new(P256).Add(p1, minus).IsZero() {
+        t.Errorf("Assert failure: P + (-P) should be equal to O")
+    }
+    if !minus.Equals(new(P256).ScalarBaseMult(new(big.Int).SetInt64(-71))) {
+        t.Errorf("Assert failure: -P should be equal to (-71)G")
+    }
+}
+
+func TestScalarMultZero(t *testing.T) {
+    p1 := new(P256).ScalarBaseMult(new(big.Int).SetInt64(71))
+    if !new(P256).ScalarMult(p1, new(big.Int)).IsZero() {
+        t.Errorf("Assert failure: 0P should be equal to O")
+    }
+    if !new(P256).ScalarBaseMult(new(big.Int)).IsZero() {
+        t.Errorf("Assert failure: 0G should be equal to O")
+    }
+    if !new(P256).ScalarMult(new(P256).SetInfinity(), new(big.Int).SetInt64(5)).IsZero() {
+        t.Errorf("Assert failure: 5O should be equal to O")
+    }
+}
+
 func TestScalarMultP256(t *testing.T) {
     curve := S256()
     a1 := new(big.Int).SetInt64(71).Bytes()
@@ -91,3 +139,365 @@ func BenchmarkScalarMultP256(b *testing.B) {
         _ = new(P256).ScalarBaseMult(new(big.Int).SetBytes(a))
     }
 }
+
+func TestMarshalBinary(t *testing.T) {
+    for _, k := range []int64{1, 2, 71, 88} {
+        p := new(P256).ScalarBaseMult(new(big.Int).SetInt64(k))
+        data, _ := p.MarshalBinary()
+        q := new(P256)
+        err := q.UnmarshalBinary(data)
+        if err != nil || !p.Equals(q) {
+            t.Errorf("Assert failure: compressed point %d did not round trip", k)
+        }
+    }
+    data, _ := new(P256).SetInfinity().MarshalBinary()
+    q := new(P256)
+    if err := q.UnmarshalBinary(data); err != nil || !q.IsZero() {
+        t.Errorf("Assert failure: point at infinity did not round trip")
+    }
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+    q := new(P256)
+    data := make([]byte, PointSize)
+    data[0] = 2
+    copy(data[1:], CURVE.P.Bytes())
+    if err := q.UnmarshalBinary(data); err != ErrInvalidEncoding {
+        t.Errorf("Assert failure: expected ErrInvalidEncoding, actual: %v", err)
+    }
+    // x = 5 is not the coordinate of a point of secp256k1
+    data = make([]byte, PointSize)
+    data[0], data[PointSize-1] = 2, 5
+    if err := q.UnmarshalBinary(data); err != ErrNotOnCurve {
+        t.Errorf("Assert failure: expected ErrNotOnCurve, actual: %v", err)
+    }
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+    p := new(P256).ScalarBaseMult(new(big.Int).SetInt64(71))
+    data, _ := json.Marshal(p)
+    p.Normalize()
+    var q *P256
+    if err := json.Unmarshal(data, &q); err != nil || !p.Equals(q) {
+        t.Errorf("Assert failure: point did not round trip")
+    }
+    data, _ = json.Marshal(new(P256).SetInfinity())
+    if err := json.Unmarshal(data, &q); err != nil || !q.IsZero() {
+        t.Errorf("Assert failure: point at infinity did not round trip")
+    }
+    offCurve := &P256{X: p.X, Y: new(big.Int).Add(p.Y, big.NewInt(1))}
+    data, _ = json.Marshal(offCurve)
+    if err := json.Unmarshal(data, &q); err != ErrNotOnCurve {
+        t.Errorf("Assert failure: expected ErrNotOnCurve, actual: %v", err)
+    }
+    if err := json.Unmarshal([]byte(`{"X": 1, "Y": null}`), &q); err != ErrInvalidEncoding {
+        t.Errorf("Assert failure: expected ErrInvalidEncoding, actual: %v", err)
+    }
+}
+
+func TestJacobian(t *testing.T) {
+    curve := S256()
+    for _, k := range []int64{1, 2, 3, 71, 1 << 40} {
+        kx, ky := curve.ScalarBaseMult(big.NewInt(k).Bytes())
+        p := new(P256).ScalarBaseMult(big.NewInt(k))
+        if !p.Equals(&P256{X: kx, Y: ky}) {
+            t.Errorf("Assert failure: %dG is not equal to the affine result", k)
+        }
+        data, _ := p.MarshalBinary()
+        p.Normalize()
+        if p.X.Cmp(kx) != 0 || p.Y.Cmp(ky) != 0 {
+            t.Errorf("Assert failure: normalized %dG is not equal to the affine result", k)
+        }
+        q := new(P256)
+        if err := q.UnmarshalBinary(data); err != nil || !q.Equals(p) {
+            t.Errorf("Assert failure: %dG did not round trip", k)
+        }
+    }
+}
+
+func TestBatchNormalize(t *testing.T) {
+    p := new(P256).ScalarBaseMult(big.NewInt(71))
+    points := []*P256{p, new(P256).SetInfinity(), new(P256).ScalarBaseMult(big.NewInt(17)), p}
+    expected := make([]*P256, len(points))
+    for i := range points {
+        expected[i] = points[i].Copy().Normalize()
+    }
+    BatchNormalize(points...)
+    for i := range points {
+        if !points[i].Equals(expected[i]) || points[i].proj != nil {
+            t.Errorf("Assert failure: point %d was not normalized", i)
+        }
+    }
+}
+
+func BenchmarkAdd(b *testing.B) {
+    p := new(P256).ScalarBaseMult(big.NewInt(71))
+    q := new(P256).ScalarBaseMult(big.NewInt(17))
+    for i := 0; i < b.N; i++ {
+        p.Add(p, q)
+    }
+}
+
+// Test vectors from RFC 9380, appendix J.8.1.
+func TestHashToCurve(t *testing.T) {
+    dst := []byte("QUUX-V01-CS02-with-secp256k1_XMD:SHA-256_SSWU_RO_")
+    vectors := []struct {
+        msg  string
+        x, y string
+    }{
+        {"", "c1cae290e291aee617ebaef1be6d73861479c48b841eaba9b7b5852ddfeb1346", "64fa678e07ae116126f08b022a94af6de15985c996c3a91b64c406a960e51067"},
+        {"abc", "3377e01eab42db296b512293120c6cee72b6ecf9f9205760bd9ff11fb3cb2c4b", "7f95890f33efebd1044d382a01b1bee0900fb6116f94688d487c6c7b9c8371f6"},
+    }
+    for _, v := range vectors {
+        p, err := HashToCurve(dst, []byte(v.msg))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !p.IsOnCurve() {
+            t.Errorf("Assert failure: HashToCurve(%q) is not on the curve", v.msg)
+        }
+        x := make([]byte, 32)
+        y := make([]byte, 32)
+        p.X.FillBytes(x)
+        p.Y.FillBytes(y)
+        if hex.EncodeToString(x) != v.x || hex.EncodeToString(y) != v.y {
+            t.Errorf("Assert failure: HashToCurve(%q) = (%x, %x)", v.msg, x, y)
+        }
+    }
+}
+
+func TestMultiScalarMult(t *testing.T) {
+    for _, n := range []int{0, 1, 5, pippengerThreshold + 3} {
+        points := make([]*P256, n)
+        scalars := make([]*big.Int, n)
+        expected := new(P256).SetInfinity()
+        for i := 0; i < n; i++ {
+            points[i] = new(P256).ScalarBaseMult(big.NewInt(int64(i + 1)))
+            scalars[i], _ = rand.Int(rand.Reader, CURVE.N)
+            if i%7 == 3 {
+                scalars[i].Neg(scalars[i])
+            }
+            if i%11 == 5 {
+                points[i].SetInfinity()
+            }
+            expected.Add(expected, new(P256).ScalarMult(points[i], scalars[i]))
+        }
+        if !MultiScalarMult(points, scalars).Equals(expected) {
+            t.Errorf("Assert failure: multi-scalar multiplication of %d terms is wrong", n)
+        }
+    }
+}
+
+func benchmarkMultiScalarMult(b *testing.B, n int, f func([]*P256, []*big.Int) *P256) {
+    points := make([]*P256, n)
+    scalars := make([]*big.Int, n)
+    for i := 0; i < n; i++ {
+        scalars[i], _ = rand.Int(rand.Reader, CURVE.N)
+        points[i] = new(P256).ScalarBaseMult(scalars[i])
+        scalars[i], _ = rand.Int(rand.Reader, CURVE.N)
+    }
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        f(points, scalars)
+    }
+}
+
+func BenchmarkMultiScalarMult64(b *testing.B) {
+    benchmarkMultiScalarMult(b, 64, MultiScalarMult)
+}
+
+func BenchmarkMultiScalarMult256(b *testing.B) {
+    benchmarkMultiScalarMult(b, 256, MultiScalarMult)
+}
+
+/*
+referenceScalarMult computes k.a with the double-and-add method, for 0 <= k.
+*/
+func referenceScalarMult(a *P256, k *big.Int) *P256 {
+    r := new(P256).SetInfinity()
+    for i := k.BitLen() - 1; i >= 0; i-- {
+        r.Double(r)
+        if k.Bit(i) == 1 {
+            r.Add(r, a)
+        }
+    }
+    return r
+}
+
+func TestPrecompute(t *testing.T) {
+    p := new(P256).ScalarBaseMult(big.NewInt(1234567))
+    q := p.Copy()
+    // the caller's point is left in projective coordinates
+    Precompute(p)
+    if p.proj == nil && q.proj != nil {
+        t.Errorf("Assert failure: Precompute modified the point")
+    }
+    p.Normalize()
+    if lookupTable(p) == nil {
+        t.Fatalf("Assert failure: the table of the point was not registered")
+    }
+    for _, k := range []*big.Int{big.NewInt(1), big.NewInt(-1), big.NewInt(71), new(big.Int).Sub(CURVE.N, big.NewInt(2))} {
+        expected := referenceScalarMult(q, new(big.Int).Mod(k, CURVE.N))
+        if !new(P256).ScalarMult(p, k).Equals(expected) {
+            t.Errorf("Assert failure: fixed-base multiplication by %d is wrong", k)
+        }
+    }
+    k, _ := rand.Int(rand.Reader, CURVE.N)
+    if !new(P256).ScalarMult(p, k).Equals(referenceScalarMult(q, k)) {
+        t.Errorf("Assert failure: fixed-base multiplication by a random scalar is wrong")
+    }
+}
+
+func TestPrecomputeConcurrent(t *testing.T) {
+    p := new(P256).ScalarBaseMult(big.NewInt(7654321))
+    expected := referenceScalarMult(p, big.NewInt(99))
+    var wg sync.WaitGroup
+    for i := 0; i < 4; i++ {
+        wg.Add(2)
+        go func() {
+            defer wg.Done()
+            Precompute(p)
+        }()
+        go func() {
+            defer wg.Done()
+            if !new(P256).ScalarMult(p, big.NewInt(99)).Equals(expected) {
+                t.Errorf("Assert failure: scalar multiplication is wrong during Precompute")
+            }
+        }()
+    }
+    wg.Wait()
+}
+
+func TestFieldElement(t *testing.T) {
+    values := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(7), new(big.Int).Sub(CURVE.P, big.NewInt(1))}
+    for i := 0; i < 20; i++ {
+        k, _ := rand.Int(rand.Reader, CURVE.P)
+        values = append(values, k)
+    }
+    for _, a := range values {
+        for _, b := range values {
+            var x, y, r fieldElement
+            x.setBig(a)
+            y.setBig(b)
+            if r.add(&x, &y).big().Cmp(new(big.Int).Mod(new(big.Int).Add(a, b), CURVE.P)) != 0 {
+                t.Errorf("Assert failure: %d + %d is wrong", a, b)
+            }
+            if r.sub(&x, &y).big().Cmp(new(big.Int).Mod(new(big.Int).Sub(a, b), CURVE.P)) != 0 {
+                t.Errorf("Assert failure: %d - %d is wrong", a, b)
+            }
+            if r.mul(&x, &y).big().Cmp(new(big.Int).Mod(new(big.Int).Mul(a, b), CURVE.P)) != 0 {
+                t.Errorf("Assert failure: %d * %d is wrong", a, b)
+            }
+        }
+        var x, r fieldElement
+        x.setBig(a)
+        if a.Sign() != 0 && r.inv(&x).big().Cmp(new(big.Int).ModInverse(a, CURVE.P)) != 0 {
+            t.Errorf("Assert failure: the inverse of %d is wrong", a)
+        }
+        square := new(big.Int).ModSqrt(a, CURVE.P) != nil
+        if ok := r.sqrt(&x); (ok == 1) != square || (square && r.square(&r).big().Cmp(a) != 0) {
+            t.Errorf("Assert failure: the square root of %d is wrong", a)
+        }
+    }
+}
+
+func TestScalar(t *testing.T) {
+    values := []*big.Int{big.NewInt(0), big.NewInt(1), new(big.Int).Rsh(CURVE.N, 1), new(big.Int).Sub(CURVE.N, big.NewInt(1))}
+    for i := 0; i < 20; i++ {
+        k, _ := rand.Int(rand.Reader, CURVE.N)
+        values = append(values, k)
+    }
+    for _, a := range values {
+        for _, b := range values {
+            var x, y, r scalar
+            x.setBig(a)
+            y.setBig(b)
+            if r.add(&x, &y).big().Cmp(new(big.Int).Mod(new(big.Int).Add(a, b), CURVE.N)) != 0 {
+                t.Errorf("Assert failure: %d + %d is wrong", a, b)
+            }
+            if r.mul(&x, &y).big().Cmp(new(big.Int).Mod(new(big.Int).Mul(a, b), CURVE.N)) != 0 {
+                t.Errorf("Assert failure: %d * %d is wrong", a, b)
+            }
+        }
+        var x, r scalar
+        x.setBig(a)
+        if r.neg(&x).big().Cmp(new(big.Int).Mod(new(big.Int).Neg(a), CURVE.N)) != 0 {
+            t.Errorf("Assert failure: -%d is wrong", a)
+        }
+    }
+}
+
+func TestSplitLambda(t *testing.T) {
+    lambda := glvLambda.big()
+    values := []*big.Int{big.NewInt(0), big.NewInt(1), lambda, new(big.Int).Sub(CURVE.N, big.NewInt(1))}
+    for i := 0; i < 100; i++ {
+        k, _ := rand.Int(rand.Reader, CURVE.N)
+        values = append(values, k)
+    }
+    for _, k := range values {
+        var s scalar
+        k1, k2, neg1, neg2 := s.setBig(k).splitLambda()
+        if k1.bitLen() > glvBits || k2.bitLen() > glvBits {
+            t.Errorf("Assert failure: the halves of %d are too long", k)
+        }
+        r1, r2 := k1.big(), k2.big()
+        if neg1 == 1 {
+            r1.Neg(r1)
+        }
+        if neg2 == 1 {
+            r2.Neg(r2)
+        }
+        r := r2.Mul(r2, lambda)
+        r.Add(r, r1)
+        if r.Mod(r, CURVE.N).Cmp(k) != 0 {
+            t.Errorf("Assert failure: the halves of %d do not recombine", k)
+        }
+    }
+}
+
+func TestEndomorphism(t *testing.T) {
+    p := new(P256).ScalarBaseMult(big.NewInt(71))
+    q := p.point()
+    var phi point
+    phi.endomorphism(&q)
+    expected := referenceScalarMult(p, glvLambda.big()).point()
+    if phi.equal(&expected) != 1 {
+        t.Errorf("Assert failure: the endomorphism is not the multiplication by lambda")
+    }
+}
+
+// Known multiples of the generator.
+func TestScalarBaseMultVectors(t *testing.T) {
+    vectors := []struct {
+        k    int64
+        x, y string
+    }{
+        {2, "c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5", "1ae168fea63dc339a3c58419466ceaeef7f632653266d0e1236431a950cfe52a"},
+        {3, "f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9", "388f7b0f632de8140fe337e62a37f3566500a99934c2231b6cb9fd7584b8e672"},
+    }
+    g := &P256{X: CURVE.Gx, Y: CURVE.Gy}
+    for _, v := range vectors {
+        expected := &P256{X: hexToBig(v.x), Y: hexToBig(v.y)}
+        k := big.NewInt(v.k)
+        if !new(P256).ScalarBaseMult(k).Equals(expected) {
+            t.Errorf("Assert failure: %dG is wrong with the fixed-base table", v.k)
+        }
+        var r point
+        var s scalar
+        q := g.point()
+        if !new(P256).setPoint(r.scalarMult(&q, s.setBig(k))).Equals(expected) {
+            t.Errorf("Assert failure: %dG is wrong with the endomorphism", v.k)
+        }
+    }
+}
+
+func TestScalarMult(t *testing.T) {
+    p := new(P256).ScalarBaseMult(big.NewInt(71))
+    p.Double(p)
+    for i := 0; i < 20; i++ {
+        k, _ := rand.Int(rand.Reader, CURVE.N)
+        if !new(P256).ScalarMult(p, k).Equals(referenceScalarMult(p, k)) {
+            t.Errorf("Assert failure: the multiplication by %d is wrong", k)
+        }
+    }
+}
@@ -0,0 +1,329 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package p256
+
+import (
+    "math/big"
+)
+
+/*
+Arithmetic in homogeneous projective coordinates: (X, Y, Z) represents the affine
+point (X/Z, Y/Z), and (0, 1, 0) represents the point at infinity. The formulas are
+the complete ones for a = 0 from Renes, Costello and Batina, "Complete addition
+formulas for prime order elliptic curves", https://eprint.iacr.org/2015/1060. They
+give the right result for all the inputs, including equal points, opposite points
+and the point at infinity, so the operations never branch on the coordinates.
+*/
+type point struct {
+    x, y, z fieldElement
+}
+
+/*
+affinePoint is a point in affine coordinates, which is never the point at infinity.
+*/
+type affinePoint struct {
+    x, y fieldElement
+}
+
+// fieldB3 is 3.B, where B = 7 is the constant of the curve equation.
+var fieldB3 = fieldElement{21}
+
+func (r *point) setInfinity() *point {
+    *r = point{y: fieldOne}
+    return r
+}
+
+func (r *point) isInfinity() uint64 {
+    return r.z.isZero()
+}
+
+/*
+add sets r to p + q, using algorithm 7 of the paper.
+*/
+func (r *point) add(p, q *point) *point {
+    var t0, t1, t2, t3, t4, x3, y3, z3 fieldElement
+    t0.mul(&p.x, &q.x)
+    t1.mul(&p.y, &q.y)
+    t2.mul(&p.z, &q.z)
+    t3.add(&p.x, &p.y)
+    t4.add(&q.x, &q.y)
+    t3.mul(&t3, &t4)
+    t4.add(&t0, &t1)
+    t3.sub(&t3, &t4)
+    t4.add(&p.y, &p.z)
+    x3.add(&q.y, &q.z)
+    t4.mul(&t4, &x3)
+    x3.add(&t1, &t2)
+    t4.sub(&t4, &x3)
+    x3.add(&p.x, &p.z)
+    y3.add(&q.x, &q.z)
+    x3.mul(&x3, &y3)
+    y3.add(&t0, &t2)
+    y3.sub(&x3, &y3)
+    x3.add(&t0, &t0)
+    t0.add(&x3, &t0)
+    t2.mul(&fieldB3, &t2)
+    z3.add(&t1, &t2)
+    t1.sub(&t1, &t2)
+    y3.mul(&fieldB3, &y3)
+    x3.mul(&t4, &y3)
+    t2.mul(&t3, &t1)
+    x3.sub(&t2, &x3)
+    y3.mul(&y3, &t0)
+    t1.mul(&t1, &z3)
+    y3.add(&t1, &y3)
+    t0.mul(&t0, &t3)
+    z3.mul(&z3, &t4)
+    z3.add(&z3, &t0)
+    *r = point{x3, y3, z3}
+    return r
+}
+
+/*
+addAffine sets r to p + q, using algorithm 8 of the paper, which is cheaper than add.
+*/
+func (r *point) addAffine(p *point, q *affinePoint) *point {
+    var t0, t1, t2, t3, t4, x3, y3, z3 fieldElement
+    t0.mul(&p.x, &q.x)
+    t1.mul(&p.y, &q.y)
+    t3.add(&q.x, &q.y)
+    t4.add(&p.x, &p.y)
+    t3.mul(&t3, &t4)
+    t4.add(&t0, &t1)
+    t3.sub(&t3, &t4)
+    t4.mul(&q.y, &p.z)
+    t4.add(&t4, &p.y)
+    y3.mul(&q.x, &p.z)
+    y3.add(&y3, &p.x)
+    x3.add(&t0, &t0)
+    t0.add(&x3, &t0)
+    t2.mul(&fieldB3, &p.z)
+    z3.add(&t1, &t2)
+    t1.sub(&t1, &t2)
+    y3.mul(&fieldB3, &y3)
+    x3.mul(&t4, &y3)
+    t2.mul(&t3, &t1)
+    x3.sub(&t2, &x3)
+    y3.mul(&y3, &t0)
+    t1.mul(&t1, &z3)
+    y3.add(&t1, &y3)
+    t0.mul(&t0, &t3)
+    z3.mul(&z3, &t4)
+    z3.add(&z3, &t0)
+    *r = point{x3, y3, z3}
+    return r
+}
+
+/*
+double sets r to 2.p, using algorithm 9 of the paper.
+*/
+func (r *point) double(p *point) *point {
+    var t0, t1, t2, x3, y3, z3 fieldElement
+    t0.square(&p.y)
+    z3.add(&t0, &t0)
+    z3.add(&z3, &z3)
+    z3.add(&z3, &z3)
+    t1.mul(&p.y, &p.z)
+    t2.square(&p.z)
+    t2.mul(&fieldB3, &t2)
+    x3.mul(&t2, &z3)
+    y3.add(&t0, &t2)
+    z3.mul(&t1, &z3)
+    t1.add(&t2, &t2)
+    t2.add(&t1, &t2)
+    t0.sub(&t0, &t2)
+    y3.mul(&t0, &y3)
+    y3.add(&x3, &y3)
+    t1.mul(&p.x, &p.y)
+    x3.mul(&t0, &t1)
+    x3.add(&x3, &x3)
+    *r = point{x3, y3, z3}
+    return r
+}
+
+/*
+condNeg sets r to -p if cond is 1, or to p if cond is 0.
+*/
+func (r *point) condNeg(p *point, cond uint64) *point {
+    var y fieldElement
+    y.neg(&p.y)
+    r.x, r.z = p.x, p.z
+    r.y.sel(&y, &p.y, cond)
+    return r
+}
+
+/*
+sel sets r to a if cond is 1, or to b if cond is 0.
+*/
+func (r *point) sel(a, b *point, cond uint64) *point {
+    r.x.sel(&a.x, &b.x, cond)
+    r.y.sel(&a.y, &b.y, cond)
+    r.z.sel(&a.z, &b.z, cond)
+    return r
+}
+
+/*
+lookup sets r to table[i], where i is lower than len(table), reading every entry of
+the table so that the memory accesses do not depend on i.
+*/
+func (r *point) lookup(table []point, i uint64) *point {
+    for j := range table {
+        r.sel(&table[j], r, ctEqual(uint64(j), i))
+    }
+    return r
+}
+
+/*
+equal returns 1 if p and q are the same point, comparing X1.Z2 with X2.Z1 and Y1.Z2
+with Y2.Z1, and 0 otherwise. Since Y is not zero at infinity, the comparison also
+holds when either point is the point at infinity.
+*/
+func (p *point) equal(q *point) uint64 {
+    var a, b, c, d fieldElement
+    a.mul(&p.x, &q.z)
+    b.mul(&q.x, &p.z)
+    c.mul(&p.y, &q.z)
+    d.mul(&q.y, &p.z)
+    return a.equal(&b) & c.equal(&d)
+}
+
+/*
+isOnCurve returns 1 if p satisfies Y².Z = X³ + B.Z³, and 0 otherwise. The point at
+infinity satisfies the equation.
+*/
+func (p *point) isOnCurve() uint64 {
+    var lhs, rhs, z3 fieldElement
+    lhs.square(&p.y)
+    lhs.mul(&lhs, &p.z)
+    rhs.square(&p.x)
+    rhs.mul(&rhs, &p.x)
+    z3.square(&p.z)
+    z3.mul(&z3, &p.z)
+    z3.mul(&z3, &fieldElement{7})
+    rhs.add(&rhs, &z3)
+    return lhs.equal(&rhs)
+}
+
+/*
+batchToAffine returns the affine coordinates of the points, with a single field
+inversion. None of the points may be the point at infinity.
+*/
+func batchToAffine(points []point) []affinePoint {
+    zinv := make([]fieldElement, len(points))
+    for i := range points {
+        zinv[i] = points[i].z
+    }
+    batchInvert(zinv)
+    affine := make([]affinePoint, len(points))
+    for i := range points {
+        affine[i].x.mul(&points[i].x, &zinv[i])
+        affine[i].y.mul(&points[i].y, &zinv[i])
+    }
+    return affine
+}
+
+/*
+point returns the projective coordinates of p, which may be the point at infinity.
+*/
+func (p *P256) point() point {
+    var r point
+    if p.proj != nil {
+        return *p.proj
+    } else if p.IsZero() {
+        return *r.setInfinity()
+    }
+    r.x.setBig(p.X)
+    r.y.setBig(p.Y)
+    r.z = fieldOne
+    return r
+}
+
+/*
+affinePoint returns the coordinates of p, which must be in affine coordinates and
+must not be the point at infinity.
+*/
+func (p *P256) affinePoint() affinePoint {
+    var a affinePoint
+    a.x.setBig(p.X)
+    a.y.setBig(p.Y)
+    return a
+}
+
+/*
+setPoint sets p to q, keeping the projective coordinates until Normalize.
+*/
+func (p *P256) setPoint(q *point) *P256 {
+    if q.isInfinity() == 1 {
+        return p.SetInfinity()
+    }
+    proj := *q
+    p.X = nil
+    p.Y = nil
+    p.proj = &proj
+    return p
+}
+
+/*
+affine returns the affine coordinates of p without modifying it, which requires one
+field inversion if p is in projective coordinates. It returns nil for the point at
+infinity.
+*/
+func (p *P256) affine() (x, y *big.Int) {
+    if p.IsZero() {
+        return nil, nil
+    } else if p.proj == nil {
+        return p.X, p.Y
+    }
+    var zinv, ax, ay fieldElement
+    zinv.inv(&p.proj.z)
+    ax.mul(&p.proj.x, &zinv)
+    ay.mul(&p.proj.y, &zinv)
+    return ax.big(), ay.big()
+}
+
+/*
+BatchNormalize converts the points to affine coordinates, like Normalize, with a
+single field inversion for all of them, using Montgomery's trick. The points that
+are already affine are not modified.
+*/
+func BatchNormalize(points ...*P256) {
+    var proj []*P256
+    var zs []fieldElement
+    seen := make(map[*P256]bool)
+    for _, p := range points {
+        if p == nil || p.proj == nil || seen[p] {
+            continue
+        }
+        seen[p] = true
+        if p.IsZero() {
+            p.SetInfinity()
+            continue
+        }
+        proj = append(proj, p)
+        zs = append(zs, p.proj.z)
+    }
+    batchInvert(zs)
+    for i, p := range proj {
+        var x, y fieldElement
+        x.mul(&p.proj.x, &zs[i])
+        y.mul(&p.proj.y, &zs[i])
+        p.X = x.big()
+        p.Y = y.big()
+        p.proj = nil
+    }
+}
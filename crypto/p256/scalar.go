@@ -0,0 +1,200 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package p256
+
+import (
+    "math/big"
+    "math/bits"
+
+    "github.com/ing-bank/zkrp/util/bn"
+)
+
+/*
+Scalars are integers modulo the order N of the group, represented like the field
+elements by four 64-bit limbs in little-endian order, always fully reduced. The
+products are reduced by folding their upper half multiplied by 2^256 - N, which has
+129 bits, into their lower half until they fit in 256 bits. Like the field
+arithmetic, the operations run in constant time.
+*/
+type scalar [4]uint64
+
+var (
+    scalarN     = scalar(limbsFromHex("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141"))
+    scalarHalfN = scalar(limbsFromHex("7fffffffffffffffffffffffffffffff5d576e7357a4501ddfe92f46681b20a0"))
+    // scalarNC is 2^256 - N, whose limbs above scalarNCLimbs are zero.
+    scalarNC = limbsFromHex("14551231950b75fc4402da1732fc9bebf")
+)
+
+const scalarNCLimbs = 3
+
+/*
+setBig sets k to n mod N, for any integer n.
+*/
+func (k *scalar) setBig(n *big.Int) *scalar {
+    if n.Sign() < 0 || n.Cmp(CURVE.N) >= 0 {
+        n = bn.Mod(n, CURVE.N)
+    }
+    *k = limbsFromBig(n)
+    return k
+}
+
+func (k *scalar) big() *big.Int {
+    return limbsToBig((*[4]uint64)(k))
+}
+
+func (k *scalar) sel(a, b *scalar, cond uint64) *scalar {
+    mask := -cond
+    for i := range k {
+        k[i] = b[i] ^ (mask & (a[i] ^ b[i]))
+    }
+    return k
+}
+
+func (k *scalar) isZero() uint64 {
+    return ctEqual(k[0]|k[1]|k[2]|k[3], 0)
+}
+
+/*
+isHigh returns 1 if k is greater than N/2, i.e. if -k is lower than k, and 0 otherwise.
+*/
+func (k *scalar) isHigh() uint64 {
+    var borrow uint64
+    _, borrow = bits.Sub64(scalarHalfN[0], k[0], 0)
+    _, borrow = bits.Sub64(scalarHalfN[1], k[1], borrow)
+    _, borrow = bits.Sub64(scalarHalfN[2], k[2], borrow)
+    _, borrow = bits.Sub64(scalarHalfN[3], k[3], borrow)
+    return borrow
+}
+
+func (k *scalar) add(a, b *scalar) *scalar {
+    var s, d scalar
+    var c, borrow uint64
+    s[0], c = bits.Add64(a[0], b[0], 0)
+    s[1], c = bits.Add64(a[1], b[1], c)
+    s[2], c = bits.Add64(a[2], b[2], c)
+    s[3], c = bits.Add64(a[3], b[3], c)
+    d[0], borrow = bits.Sub64(s[0], scalarN[0], 0)
+    d[1], borrow = bits.Sub64(s[1], scalarN[1], borrow)
+    d[2], borrow = bits.Sub64(s[2], scalarN[2], borrow)
+    d[3], borrow = bits.Sub64(s[3], scalarN[3], borrow)
+    // the sum is at least N if it overflows 2^256 or if subtracting N does not borrow
+    return k.sel(&d, &s, c|(1^borrow))
+}
+
+func (k *scalar) neg(a *scalar) *scalar {
+    var d scalar
+    var borrow uint64
+    d[0], borrow = bits.Sub64(scalarN[0], a[0], 0)
+    d[1], borrow = bits.Sub64(scalarN[1], a[1], borrow)
+    d[2], borrow = bits.Sub64(scalarN[2], a[2], borrow)
+    d[3], _ = bits.Sub64(scalarN[3], a[3], borrow)
+    return k.sel(&scalar{}, &d, a.isZero())
+}
+
+/*
+condNeg sets k to -a if cond is 1, or to a if cond is 0.
+*/
+func (k *scalar) condNeg(a *scalar, cond uint64) *scalar {
+    var n scalar
+    n.neg(a)
+    return k.sel(&n, a, cond)
+}
+
+func (k *scalar) mul(a, b *scalar) *scalar {
+    t := mulWide((*[4]uint64)(a), (*[4]uint64)(b))
+    // the product has 512 bits, then at most 386, 260, 257 and 256 bits
+    for i := 0; i < 4; i++ {
+        foldN(&t)
+    }
+    var d scalar
+    var borrow uint64
+    d[0], borrow = bits.Sub64(t[0], scalarN[0], 0)
+    d[1], borrow = bits.Sub64(t[1], scalarN[1], borrow)
+    d[2], borrow = bits.Sub64(t[2], scalarN[2], borrow)
+    d[3], borrow = bits.Sub64(t[3], scalarN[3], borrow)
+    lo := scalar{t[0], t[1], t[2], t[3]}
+    return k.sel(&lo, &d, borrow)
+}
+
+/*
+foldN replaces t by t[0..3] + t[4..7].(2^256 - N), which is equal to t modulo N.
+*/
+func foldN(t *[8]uint64) {
+    var r [8]uint64
+    copy(r[:4], t[:4])
+    for i := 0; i < 4; i++ {
+        var carry uint64
+        for j := 0; j < scalarNCLimbs; j++ {
+            hi, lo := bits.Mul64(t[4+i], scalarNC[j])
+            var c uint64
+            lo, c = bits.Add64(lo, r[i+j], 0)
+            hi += c
+            lo, c = bits.Add64(lo, carry, 0)
+            hi += c
+            r[i+j] = lo
+            carry = hi
+        }
+        for j := i + scalarNCLimbs; j < len(r); j++ {
+            r[j], carry = bits.Add64(r[j], carry, 0)
+        }
+    }
+    *t = r
+}
+
+/*
+mulShift384 returns a.b / 2^384 rounded to the nearest integer, which has at most 129
+bits.
+*/
+func mulShift384(a, b *scalar) scalar {
+    t := mulWide((*[4]uint64)(a), (*[4]uint64)(b))
+    var r scalar
+    var c uint64
+    r[0], c = bits.Add64(t[6], t[5]>>63, 0)
+    r[1], c = bits.Add64(t[7], 0, c)
+    r[2] = c
+    return r
+}
+
+/*
+window returns the c bits of k starting at bit i, where c is at most 32. It only
+depends on the value of k through its result.
+*/
+func (k *scalar) window(i, c int) uint64 {
+    if i >= 256 {
+        return 0
+    }
+    limb, shift := i/64, uint(i%64)
+    w := k[limb] >> shift
+    if shift+uint(c) > 64 && limb+1 < len(k) {
+        w |= k[limb+1] << (64 - shift)
+    }
+    return w & (1<<uint(c) - 1)
+}
+
+/*
+bitLen returns the length of k in bits. Unlike the other operations, it does not run
+in constant time.
+*/
+func (k *scalar) bitLen() int {
+    for i := len(k) - 1; i >= 0; i-- {
+        if k[i] != 0 {
+            return 64*i + bits.Len64(k[i])
+        }
+    }
+    return 0
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+/*
+Package transcript implements Fiat-Shamir transcripts in the style of Merlin
+(https://merlin.cool), using SHA-256 instead of STROBE.
+
+Prover and verifier append the same labelled messages in the same order, and
+squeeze challenges from the transcript. Every challenge depends on all the
+messages appended before it, including the previous challenges, and on the
+label of the transcript, which provides domain separation between protocols.
+*/
+package transcript
+
+import (
+    "crypto/sha256"
+    "encoding/binary"
+    "hash"
+    "math/big"
+)
+
+const protocolLabel = "zkrp transcript v1"
+
+// Operation codes, so that appending a message can not be confused with
+// squeezing a challenge.
+const (
+    opAppend    byte = 'A'
+    opChallenge byte = 'C'
+)
+
+/*
+Transcript contains the running hash of the messages appended so far.
+*/
+type Transcript struct {
+    state hash.Hash
+}
+
+/*
+New creates a transcript for the protocol identified by label.
+*/
+func New(label string) *Transcript {
+    t := &Transcript{state: sha256.New()}
+    t.state.Write([]byte(protocolLabel))
+    t.AppendMessage("dom-sep", []byte(label))
+    return t
+}
+
+/*
+write absorbs the operation code, the label and the data, each one prefixed by
+its length, so that the encoding is injective.
+*/
+func (t *Transcript) write(op byte, label string, data []byte) {
+    var length [8]byte
+    t.state.Write([]byte{op})
+    binary.BigEndian.PutUint64(length[:], uint64(len(label)))
+    t.state.Write(length[:])
+    t.state.Write([]byte(label))
+    binary.BigEndian.PutUint64(length[:], uint64(len(data)))
+    t.state.Write(length[:])
+    t.state.Write(data)
+}
+
+/*
+AppendMessage appends a labelled message to the transcript.
+*/
+func (t *Transcript) AppendMessage(label string, message []byte) {
+    t.write(opAppend, label, message)
+}
+
+/*
+AppendScalar appends a non-negative integer, encoded in big-endian order.
+*/
+func (t *Transcript) AppendScalar(label string, k *big.Int) {
+    t.AppendMessage(label, k.Bytes())
+}
+
+/*
+AppendInt64 appends a (possibly negative) integer, encoded as 8 bytes in
+big-endian order.
+*/
+func (t *Transcript) AppendInt64(label string, v int64) {
+    var data [8]byte
+    binary.BigEndian.PutUint64(data[:], uint64(v))
+    t.AppendMessage(label, data[:])
+}
+
+/*
+ChallengeBytes squeezes n bytes from the transcript. The output is absorbed back
+into the transcript, so that later challenges depend on it.
+*/
+func (t *Transcript) ChallengeBytes(label string, n int) []byte {
+    var (
+        size    [8]byte
+        counter [4]byte
+    )
+    binary.BigEndian.PutUint64(size[:], uint64(n))
+    t.write(opChallenge, label, size[:])
+    seed := t.state.Sum(nil)
+
+    output := make([]byte, 0, n+sha256.Size)
+    for i := uint32(0); len(output) < n; i++ {
+        binary.BigEndian.PutUint32(counter[:], i)
+        digest := sha256.New()
+        digest.Write(seed)
+        digest.Write(counter[:])
+        output = digest.Sum(output)
+    }
+    t.state.Write(seed)
+    return output[:n]
+}
+
+/*
+ChallengeScalar squeezes an integer modulo order from the transcript. 128 extra
+bits are squeezed, so that the bias of the modular reduction is negligible.
+*/
+func (t *Transcript) ChallengeScalar(label string, order *big.Int) *big.Int {
+    n := (order.BitLen()+7)/8 + 16
+    k := new(big.Int).SetBytes(t.ChallengeBytes(label, n))
+    return k.Mod(k, order)
+}
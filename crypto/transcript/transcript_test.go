@@ -0,0 +1,72 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package transcript
+
+import (
+    "bytes"
+    "math/big"
+    "testing"
+)
+
+var order, _ = new(big.Int).SetString("115792089237316195423570985008687907852837564279074904382605163141518161494337", 10)
+
+func TestSameMessagesSameChallenges(t *testing.T) {
+    t1 := New("test")
+    t2 := New("test")
+    t1.AppendMessage("msg", []byte("hello"))
+    t2.AppendMessage("msg", []byte("hello"))
+    c1 := t1.ChallengeScalar("c", order)
+    c2 := t2.ChallengeScalar("c", order)
+    if c1.Cmp(c2) != 0 {
+        t.Errorf("Assert failure: challenges should be equal")
+    }
+    if c1.Cmp(order) >= 0 {
+        t.Errorf("Assert failure: challenge should be reduced modulo order")
+    }
+}
+
+func TestDomainSeparation(t *testing.T) {
+    c1 := New("protocol 1").ChallengeBytes("c", 32)
+    c2 := New("protocol 2").ChallengeBytes("c", 32)
+    if bytes.Equal(c1, c2) {
+        t.Errorf("Assert failure: different protocols should produce different challenges")
+    }
+}
+
+func TestFraming(t *testing.T) {
+    // moving bytes from the label to the message must change the challenge
+    t1 := New("test")
+    t2 := New("test")
+    t1.AppendMessage("ab", []byte("c"))
+    t2.AppendMessage("a", []byte("bc"))
+    if bytes.Equal(t1.ChallengeBytes("c", 32), t2.ChallengeBytes("c", 32)) {
+        t.Errorf("Assert failure: encoding of messages should be injective")
+    }
+}
+
+func TestChallengesAreChained(t *testing.T) {
+    tr := New("test")
+    c1 := tr.ChallengeBytes("c", 32)
+    c2 := tr.ChallengeBytes("c", 32)
+    if bytes.Equal(c1, c2) {
+        t.Errorf("Assert failure: consecutive challenges should be different")
+    }
+    if len(tr.ChallengeBytes("long", 100)) != 100 {
+        t.Errorf("Assert failure: wrong challenge length")
+    }
+}
@@ -9,15 +9,15 @@ package merkle
 import (
 	//    "fmt"
 	//    "math"
+	"bytes"
+	"errors"
 	"math/big"
+	"strconv"
 	"sync"
 
 	//    "math/rand"
-	"encoding/json"
 
-	"github.com/ing-bank/zkrp/bulletproofs"
-	"github.com/ing-bank/zkrp/crypto/p256"
-	"github.com/ing-bank/zkrp/util"
+	"github.com/ing-bank/zkrp"
 )
 
 type Path struct {
@@ -33,26 +33,128 @@ type Node struct {
 	Index  int
 	Height int
 	L      int
-	C1     []byte
-	C2     []byte
+	C      []byte
 	Pi     []byte
 }
 
-func buildLeaf(reading int, seed *big.Int, d int64) *Node {
-	p, _ := bulletproofs.SetupGeneric(0, d)
-	proof, _ := bulletproofs.ProveGeneric(big.NewInt(int64(reading)), p, seed)
-	nodeC1, _ := json.Marshal(proof.P1.V)
-	nodeC2, _ := json.Marshal(proof.P2.V)
-	nodePi, _ := json.Marshal(proof)
+// Params contains the parameters of the range proofs of the nodes, computed with
+// the zkrp scheme of the given name. A node with L leaves proves that its value
+// belongs to [0, L * Delta), so each value of L has its own parameters. The company
+// runs the setups on first use, while the users only load the verifier keys.
+type Params struct {
+	Scheme  string
+	Delta   int64
+	mu      sync.Mutex
+	provers map[int]zkrp.ProverParams
+	keys    map[int]zkrp.VerifierKey
+}
+
+func NewParams(scheme string, delta int64) *Params {
+	return &Params{
+		Scheme:  scheme,
+		Delta:   delta,
+		provers: make(map[int]zkrp.ProverParams),
+		keys:    make(map[int]zkrp.VerifierKey),
+	}
+}
+
+// LoadParams returns the parameters of a user, who can only verify the proofs of
+// the nodes whose number of leaves is in keys.
+func LoadParams(scheme string, delta int64, keys map[int][]byte) (*Params, error) {
+	p := NewParams(scheme, delta)
+	for l, data := range keys {
+		key, err := zkrp.UnmarshalVerifierKey(scheme, data)
+		if err != nil {
+			return nil, err
+		}
+		p.keys[l] = key
+	}
+	return p, nil
+}
+
+func (p *Params) setup(l int) error {
+	if _, ok := p.keys[l]; ok {
+		return nil
+	}
+	params, key, err := zkrp.Setup(p.Scheme, 0, p.Delta*int64(l))
+	if err != nil {
+		return err
+	}
+	p.provers[l] = params
+	p.keys[l] = key
+	return nil
+}
+
+func (p *Params) prover(l int) (zkrp.ProverParams, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.setup(l); err != nil {
+		return nil, err
+	}
+	if params, ok := p.provers[l]; ok {
+		return params, nil
+	}
+	return nil, errors.New("no prover parameters for " + strconv.Itoa(l) + " leaves")
+}
+
+// Key returns the verifier key of the nodes with l leaves.
+func (p *Params) Key(l int) (zkrp.VerifierKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[l]; ok {
+		return key, nil
+	}
+	return nil, errors.New("no verifier key for " + strconv.Itoa(l) + " leaves")
+}
+
+// VerifierKeys returns the encodings of the verifier keys of the nodes, indexed by
+// their number of leaves, which are given to the users with their paths.
+func (p *Params) VerifierKeys() (map[int][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make(map[int][]byte, len(p.keys))
+	for l, key := range p.keys {
+		data, err := key.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		keys[l] = data
+	}
+	return keys, nil
+}
+
+// prove returns the encodings of the commitment and of the proof of a node with l
+// leaves and the given value
+func (p *Params) prove(l int, value int, seed *big.Int) ([]byte, []byte, error) {
+	params, err := p.prover(l)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof, err := params.Prove(big.NewInt(int64(value)), seed)
+	if err != nil {
+		return nil, nil, err
+	}
+	nodeC, err := proof.Commitment().MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+	nodePi, err := proof.MarshalBinary()
+	return nodeC, nodePi, err
+}
+
+func buildLeaf(reading int, seed *big.Int, params *Params) (*Node, error) {
+	nodeC, nodePi, err := params.prove(1, reading, seed)
+	if err != nil {
+		return nil, err
+	}
 	n := &Node{
-		C1:     nodeC1,
-		C2:     nodeC2,
+		C:      nodeC,
 		Pi:     nodePi,
 		L:      1,
 		IsLeaf: true,
 		Height: 0,
 	}
-	return n
+	return n, nil
 }
 
 // func buildIntermediate(ns []*Node, vs []int, ls []int, rs []*big.Int, d int64) *Node {
@@ -109,7 +211,10 @@ func buildLeaf(reading int, seed *big.Int, d int64) *Node {
 // 	return buildIntermediate(nodes, values, sizes, seeds, d)
 // }
 
-func buildIntermediate(ns []*Node, vs []int, ls []int, rs []*big.Int, d int64) *Node {
+// buildIntermediate builds the levels above ns and returns the root. The proofs of
+// a level are computed concurrently, and their errors are returned once the level is
+// complete.
+func buildIntermediate(ns []*Node, vs []int, ls []int, rs []*big.Int, params *Params) (*Node, error) {
 	var nodes []*Node
 	var values []int
 	var sizes []int
@@ -118,6 +223,7 @@ func buildIntermediate(ns []*Node, vs []int, ls []int, rs []*big.Int, d int64) *
 	var newL int
 	var sumR *big.Int
 	wg := new(sync.WaitGroup)
+	errs := make([]error, len(ns))
 	for i := 0; i < len(ns); i += 2 {
 		var leftNode, rightNode *Node
 		if i+1 == len(ns) {
@@ -126,7 +232,7 @@ func buildIntermediate(ns []*Node, vs []int, ls []int, rs []*big.Int, d int64) *
 			sizes = append(sizes, ls[i])
 			seeds = append(seeds, rs[i])
 			if len(ns) == 2 {
-				return ns[i]
+				return ns[i], nil
 			}
 		} else {
 			sumV = vs[i] + vs[i+1]
@@ -149,47 +255,40 @@ func buildIntermediate(ns []*Node, vs []int, ls []int, rs []*big.Int, d int64) *
 			leftNode.Parent = n
 			rightNode.Parent = n
 			wg.Add(1)
-			go func(n *Node, sumV int, sumR *big.Int) {
+			go func(i int, n *Node, sumV int, sumR *big.Int) {
 				defer wg.Done()
-				p, _ := bulletproofs.SetupGeneric(0, d*int64(n.L))
-				proof, _ := bulletproofs.ProveGeneric(big.NewInt(int64(sumV)), p, sumR)
-				n.C1, _ = json.Marshal(proof.P1.V)
-				n.C2, _ = json.Marshal(proof.P2.V)
-				n.Pi, _ = json.Marshal(proof)
-			}(n, sumV, sumR)
+				n.C, n.Pi, errs[i] = params.prove(n.L, sumV, sumR)
+			}(i, n, sumV, sumR)
 			if len(ns) == 2 {
-				return n
+				wg.Wait()
+				return n, errs[i]
 			}
 		}
 	}
 	wg.Wait()
-	return buildIntermediate(nodes, values, sizes, seeds, d)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return buildIntermediate(nodes, values, sizes, seeds, params)
 }
 
-func verifyCommitmentSum(root, na, nb *Node, delta int64) bool {
-	var C1root, C2root, C1a, C1b, C2a, C2b, C1sum, C2sum *p256.P256
-	// C1 in parent should equal xa + xb - root.l * delta + max
-	// C1 in child a should equal xa - na.l * delta + max
-	// C1 in child b should equal xb - nb.l * delta + max
-	//  need dummies to compare
-	p, _ := bulletproofs.SetupGeneric(0, delta)
-	dummy := int64(10)
-	sumAdj, _ := util.CommitG1(big.NewInt(int64(dummy-bulletproofs.MAX_RANGE_END)), big.NewInt(int64(dummy)), p.BP2.H)
-	rootAdj, _ := util.CommitG1(big.NewInt(int64(dummy)), big.NewInt(int64(dummy)), p.BP2.H)
-	_ = json.Unmarshal(root.C1, &C1root)
-	_ = json.Unmarshal(na.C1, &C1a)
-	_ = json.Unmarshal(nb.C1, &C1b)
-	C1sum = new(p256.P256).Add(C1a, C1b)
-	adjC1sum := new(p256.P256).Add(C1sum, sumAdj)
-	adjC1root := new(p256.P256).Add(C1root, rootAdj)
-
-	// C2 in parent should equal sum of commitments in children
-	_ = json.Unmarshal(root.C2, &C2root)
-	_ = json.Unmarshal(na.C2, &C2a)
-	_ = json.Unmarshal(nb.C2, &C2b)
-	C2sum = new(p256.P256).Add(C2a, C2b)
-
-	return C2sum.Equals(C2root) && adjC1sum.Equals(adjC1root)
+func verifyCommitmentSum(key zkrp.VerifierKey, root, na, nb *Node) bool {
+	// C in parent should equal the sum of the commitments in children,
+	// since the blinding factor of the parent is the sum of theirs
+	ca, err := key.UnmarshalCommitment(na.C)
+	if err != nil {
+		return false
+	}
+	cb, err := key.UnmarshalCommitment(nb.C)
+	if err != nil {
+		return false
+	}
+	sum, err := key.AddCommitments(ca, cb)
+	if err != nil {
+		return false
+	}
+	sumC, err := sum.MarshalBinary()
+	return err == nil && bytes.Equal(sumC, root.C)
 }
 
 //func VerifyTree(n *Node, gamma, delta int64) (bool) {
@@ -199,96 +298,53 @@ func verifyCommitmentSum(root, na, nb *Node, delta int64) bool {
 //    return verifyCommitmentSum(n, n.Left, n.Right) && VerifyTree(n.Left) && VerifyTree(n.Right)
 //}
 
-func (p *Path) VerifyStructure(delta int64) bool {
+func (p *Path) VerifyStructure(params *Params) bool {
 	for i := 0; i < len(p.Edge); i++ {
-		if !verifyCommitmentSum(p.Core[i+1], p.Core[i], p.Edge[i], delta) {
+		key, err := params.Key(p.Core[i+1].L)
+		if err != nil || !verifyCommitmentSum(key, p.Core[i+1], p.Core[i], p.Edge[i]) {
 			return false
 		}
 	}
 	return true
 }
 
-func (p *Path) VerifyProofs() bool {
-	var mtx sync.Mutex
-	var wg sync.WaitGroup
-
-	valid := true
-
-	for i := 0; i < len(p.Core); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			var (
-				proof   bulletproofs.ProofBPRP
-				commit1 *p256.P256
-				commit2 *p256.P256
-			)
-
-			_ = json.Unmarshal(p.Core[i].Pi, &proof)
-			_ = json.Unmarshal(p.Core[i].C1, &commit1)
-			_ = json.Unmarshal(p.Core[i].C2, &commit2)
-
-			ok1 := proof.P1.V.Equals(commit1)
-			ok2 := proof.P2.V.Equals(commit2)
-			ok3, _ := proof.Verify()
-
-			//fmt.Println(ok1, ok2, ok3)
-
-			mtx.Lock()
-			defer mtx.Unlock()
-			if !(ok1 && ok2 && ok3) {
-				valid = false
-			}
-		}(i)
-	}
-	wg.Wait()
-
-	if !valid {
-		return false
+func (p *Path) VerifyProofs(params *Params) bool {
+	// a node with L leaves proves that its value belongs to [0, L * delta)
+	nodes := append(append([]*Node{}, p.Core...), p.Edge...)
+	proofs := make(map[int][]zkrp.Proof)
+	for _, n := range nodes {
+		key, err := params.Key(n.L)
+		if err != nil {
+			return false
+		}
+		proof, err := key.UnmarshalProof(n.Pi)
+		if err != nil {
+			return false
+		}
+		proofC, err := proof.Commitment().MarshalBinary()
+		if err != nil || !bytes.Equal(proofC, n.C) {
+			return false
+		}
+		proofs[n.L] = append(proofs[n.L], proof)
 	}
 
-	for i := 0; i < len(p.Edge); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			var (
-				proof   bulletproofs.ProofBPRP
-				commit1 *p256.P256
-				commit2 *p256.P256
-			)
-
-			_ = json.Unmarshal(p.Edge[i].Pi, &proof)
-			_ = json.Unmarshal(p.Edge[i].C1, &commit1)
-			_ = json.Unmarshal(p.Edge[i].C2, &commit2)
-
-			ok1 := proof.P1.V.Equals(commit1)
-			ok2 := proof.P2.V.Equals(commit2)
-			ok3, _ := proof.Verify()
-
-			//fmt.Println(ok1, ok2, ok3)
-
-			mtx.Lock()
-			defer mtx.Unlock()
-			if !(ok1 && ok2 && ok3) {
-				valid = false
-			}
-		}(i)
+	for l, ps := range proofs {
+		key, _ := params.Key(l)
+		if valid, _ := zkrp.BatchVerify(key, ps); !valid {
+			return false
+		}
 	}
-	wg.Wait()
-	return valid
+	return true
 }
 
 func (n *Node) CopyNode() *Node {
 	// deep copy byte arrays
-	nodeC1 := make([]byte, len(n.C1))
-	nodeC2 := make([]byte, len(n.C2))
+	nodeC := make([]byte, len(n.C))
 	nodePi := make([]byte, len(n.Pi))
-	copy(nodeC1, n.C1)
-	copy(nodeC2, n.C2)
+	copy(nodeC, n.C)
 	copy(nodePi, n.Pi)
 	result := &Node{
-		C1: nodeC1,
-		C2: nodeC2,
+		C:  nodeC,
 		Pi: nodePi,
 		L:  n.L,
 	}
@@ -361,34 +417,38 @@ func (n *Node) GetLeaf(index int) *Node {
 	return nil
 }
 
-// return the root node
-func (n *Node) BuildTree(vs map[int]int, rs map[int]*big.Int, d int64) *Node {
+// return the root node, or the errors of the proofs that could not be computed
+func (n *Node) BuildTree(vs map[int]int, rs map[int]*big.Int, params *Params) (*Node, error) {
 	// initialize the leaves
 	leaves := make([]*Node, len(vs))
 	values := make([]int, len(vs))
 	sizes := make([]int, len(vs))
 	seeds := make([]*big.Int, len(vs))
 
-	nch := make(chan *Node, len(vs))
+	wg := new(sync.WaitGroup)
+	errs := make([]error, len(vs))
 	for k := range vs {
 		values[k] = vs[k]
 		seeds[k] = rs[k]
 		sizes[k] = 1
+		wg.Add(1)
 		go func(idx int) {
-			n := buildLeaf(vs[idx], rs[idx], d)
-			n.Index = idx
-			nch <- n
+			defer wg.Done()
+			n, err := buildLeaf(vs[idx], rs[idx], params)
+			if err == nil {
+				n.Index = idx
+			}
+			leaves[idx], errs[idx] = n, err
 		}(k)
 	}
-
-	for range vs {
-		n := <-nch
-		leaves[n.Index] = n
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 
-	root := buildIntermediate(leaves, values, sizes, seeds, d)
+	root, err := buildIntermediate(leaves, values, sizes, seeds, params)
 	// verify full tree
 	//    valid := VerifyTree(root)
 	//    fmt.Println("full tree valid:",valid)
-	return root
+	return root, err
 }
@@ -0,0 +1,65 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package util
+
+import (
+    "crypto/sha256"
+    "encoding/binary"
+    "io"
+)
+
+/*
+deterministicReader outputs the stream SHA256(seed || 0), SHA256(seed || 1), ...
+where the counter is encoded as 8 bytes in big-endian order.
+*/
+type deterministicReader struct {
+    seed    []byte
+    counter uint64
+    block   []byte
+}
+
+/*
+NewDeterministicReader returns a reader that always outputs the same stream of
+bytes for a given seed. It can be passed to the provers instead of crypto/rand
+in order to reproduce test vectors.
+
+It must NOT be used to compute real proofs: the blinding values would be known
+to anyone who knows the seed, which breaks the zero knowledge property.
+*/
+func NewDeterministicReader(seed []byte) io.Reader {
+    return &deterministicReader{seed: append([]byte{}, seed...)}
+}
+
+func (r *deterministicReader) Read(p []byte) (int, error) {
+    n := 0
+    for n < len(p) {
+        if len(r.block) == 0 {
+            var counter [8]byte
+            binary.BigEndian.PutUint64(counter[:], r.counter)
+            digest := sha256.New()
+            digest.Write(r.seed)
+            digest.Write(counter[:])
+            r.block = digest.Sum(nil)
+            r.counter++
+        }
+        k := copy(p[n:], r.block)
+        r.block = r.block[k:]
+        n += k
+    }
+    return n, nil
+}
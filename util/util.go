@@ -18,13 +18,12 @@
 package util
 
 import (
-    "crypto/sha256"
+    "errors"
     "math/big"
 
     "github.com/ing-bank/zkrp/crypto/bn256"
     "github.com/ing-bank/zkrp/crypto/p256"
     "github.com/ing-bank/zkrp/util/bn"
-    "github.com/ing-bank/zkrp/util/byteconversion"
 )
 
 // Constants that are going to be used frequently, then we just need to compute them once.
@@ -36,13 +35,18 @@ var (
 
 /*
 Decompose receives as input a bigint x and outputs an array of integers such that
-x = sum(xi.u^i), i.e. it returns the decomposition of x into base u.
+x = sum(xi.u^i), i.e. it returns the decomposition of x into base u. It returns an
+error if x does not belong to [0, u^l), since it has no such decomposition.
 */
 func Decompose(x *big.Int, u int64, l int64) ([]int64, error) {
     var (
         result []int64
         i      int64
     )
+    ul := new(big.Int).Exp(big.NewInt(u), big.NewInt(l), nil)
+    if x.Sign() < 0 || x.Cmp(ul) >= 0 {
+        return nil, errors.New("x does not belong to the interval [0, u^l)")
+    }
     result = make([]int64, l)
     i = 0
     for i < l {
@@ -73,29 +77,3 @@ func CommitG1(x, r *big.Int, h *p256.P256) (*p256.P256, error) {
     C.Add(C, Hr)
     return C, nil
 }
-
-/*
-HashSet is responsible for the computing a Zp element given elements from GT and G2.
-*/
-func HashSet(a *bn256.GT, D *bn256.G2) (*big.Int, error) {
-    digest := sha256.New()
-    digest.Write([]byte(a.String()))
-    digest.Write([]byte(D.String()))
-    output := digest.Sum(nil)
-    tmp := output[0:]
-    return byteconversion.FromByteArray(tmp)
-}
-
-/*
-Hash is responsible for the computing a Zp element given elements from GT and G2.
-*/
-func Hash(a []*bn256.GT, D *bn256.G2) (*big.Int, error) {
-    digest := sha256.New()
-    for i := range a {
-        digest.Write([]byte(a[i].String()))
-    }
-    digest.Write([]byte(D.String()))
-    output := digest.Sum(nil)
-    tmp := output[0:]
-    return byteconversion.FromByteArray(tmp)
-}
@@ -16,3 +16,55 @@
  */
 
 package util
+
+import (
+    "bytes"
+    "io"
+    "math/big"
+    "testing"
+
+    "github.com/ing-bank/zkrp/crypto/p256"
+)
+
+func TestDeterministicReader(t *testing.T) {
+    out1 := make([]byte, 100)
+    out2 := make([]byte, 100)
+    r1 := NewDeterministicReader([]byte("seed"))
+    r2 := NewDeterministicReader([]byte("seed"))
+    _, _ = io.ReadFull(r1, out1[:7])
+    _, _ = io.ReadFull(r1, out1[7:])
+    _, _ = io.ReadFull(r2, out2)
+    if !bytes.Equal(out1, out2) {
+        t.Errorf("Assert failure: same seed should produce the same stream")
+    }
+
+    out3 := make([]byte, 100)
+    _, _ = io.ReadFull(NewDeterministicReader([]byte("other seed")), out3)
+    if bytes.Equal(out1, out3) {
+        t.Errorf("Assert failure: different seeds should produce different streams")
+    }
+}
+
+func TestCommitG1Zero(t *testing.T) {
+    H, _ := p256.MapToGroup("test")
+    r := new(big.Int).SetInt64(10)
+    C0, _ := CommitG1(new(big.Int), r, H)
+    if !C0.Equals(new(p256.P256).ScalarMult(H, r)) {
+        t.Errorf("Assert failure: commitment to 0 should be equal to H^r")
+    }
+    Cx, _ := CommitG1(new(big.Int).SetInt64(5), new(big.Int), H)
+    if !Cx.Equals(new(p256.P256).ScalarBaseMult(new(big.Int).SetInt64(5))) {
+        t.Errorf("Assert failure: commitment with r = 0 should be equal to G^x")
+    }
+    zero, _ := CommitG1(new(big.Int), new(big.Int), H)
+    if !zero.IsZero() {
+        t.Errorf("Assert failure: commitment to 0 with r = 0 should be the point at infinity")
+    }
+
+    // C + C commits to 2x with blinding factor 2r
+    C, _ := CommitG1(new(big.Int).SetInt64(5), r, H)
+    C2, _ := CommitG1(new(big.Int).SetInt64(10), new(big.Int).SetInt64(20), H)
+    if !new(p256.P256).Add(C, C).Equals(C2) {
+        t.Errorf("Assert failure: C + C should commit to 2x")
+    }
+}
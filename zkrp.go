@@ -0,0 +1,213 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+/*
+Package zkrp defines a common interface for the zero knowledge range proof schemes
+of this repository, so that applications can switch between them by name.
+
+The schemes register themselves when their package is imported, in the same way
+as the drivers of database/sql:
+
+    import (
+        "github.com/ing-bank/zkrp"
+        _ "github.com/ing-bank/zkrp/bulletproofs"
+    )
+
+    params, key, err := zkrp.Setup("bulletproofs", 18, 200)
+    proof, err := params.Prove(x, r)
+    ok, err := key.Verify(proof)
+*/
+package zkrp
+
+import (
+    "errors"
+    "math/big"
+    "sort"
+    "sync"
+)
+
+/*
+Commitment is a commitment to the secret, which is part of the proof.
+*/
+type Commitment interface {
+    MarshalBinary() ([]byte, error)
+}
+
+/*
+Proof is a range proof computed by ProverParams.Prove.
+*/
+type Proof interface {
+    MarshalBinary() ([]byte, error)
+    // Commitment returns the commitment to the secret.
+    Commitment() Commitment
+}
+
+/*
+ProverParams contains the public parameters of a scheme for an interval [a, b),
+which are given to the provers.
+*/
+type ProverParams interface {
+    // Interval returns the interval [a, b) of the parameters.
+    Interval() (int64, int64)
+    // Commit returns the commitment to x with blinding factor r, which is the
+    // commitment of the proofs computed with the same values.
+    Commit(x, r *big.Int) (Commitment, error)
+    // Prove computes the proof that x belongs to [a, b).
+    Prove(x, r *big.Int) (Proof, error)
+}
+
+/*
+VerifierKey contains the parameters kept by the verifier of a scheme for an interval
+[a, b). It may contain secret values of the setup, so it must not be given to the
+provers.
+*/
+type VerifierKey interface {
+    // Interval returns the interval [a, b) of the parameters.
+    Interval() (int64, int64)
+    // Commit returns the commitment to x with blinding factor r, like
+    // ProverParams.Commit, so that the verifier can check an opening.
+    Commit(x, r *big.Int) (Commitment, error)
+    // Verify checks the proof, which must have been computed with the prover
+    // parameters obtained with the key.
+    Verify(proof Proof) (bool, error)
+    // UnmarshalProof decodes a proof encoded with Proof.MarshalBinary.
+    UnmarshalProof(data []byte) (Proof, error)
+    // UnmarshalCommitment decodes a commitment encoded with
+    // Commitment.MarshalBinary.
+    UnmarshalCommitment(data []byte) (Commitment, error)
+    // AddCommitments returns the commitment to the sum of the values committed in
+    // a and b, whose blinding factor is the sum of theirs.
+    AddCommitments(a, b Commitment) (Commitment, error)
+    // MarshalBinary encodes the public part of the key, which is enough to verify
+    // the proofs. It is decoded by Scheme.UnmarshalVerifierKey.
+    MarshalBinary() ([]byte, error)
+}
+
+/*
+BatchVerifier is implemented by the verifier keys of the schemes that can verify
+several proofs at once faster than one at a time.
+*/
+type BatchVerifier interface {
+    // BatchVerify returns true if and only if all the proofs are valid.
+    BatchVerify(proofs []Proof) (bool, error)
+}
+
+/*
+Scheme is a range proof scheme.
+*/
+type Scheme interface {
+    // Name returns the name of the scheme in the registry.
+    Name() string
+    // Setup computes the parameters of the provers and the key of the verifier
+    // for the interval [a, b).
+    Setup(a, b int64) (ProverParams, VerifierKey, error)
+    // UnmarshalVerifierKey decodes a key encoded with VerifierKey.MarshalBinary.
+    UnmarshalVerifierKey(data []byte) (VerifierKey, error)
+}
+
+/*
+ErrWrongProofType is returned by VerifierKey.Verify when the proof was computed by
+another scheme.
+*/
+var ErrWrongProofType = errors.New("proof was computed by another scheme")
+
+var registry = struct {
+    sync.RWMutex
+    schemes map[string]Scheme
+}{schemes: make(map[string]Scheme)}
+
+/*
+Register makes a scheme available by its name. It panics if a scheme with the same
+name is already registered.
+*/
+func Register(scheme Scheme) {
+    registry.Lock()
+    defer registry.Unlock()
+    name := scheme.Name()
+    if _, ok := registry.schemes[name]; ok {
+        panic("zkrp: scheme " + name + " is already registered")
+    }
+    registry.schemes[name] = scheme
+}
+
+/*
+Lookup returns the scheme registered with the given name.
+*/
+func Lookup(name string) (Scheme, error) {
+    registry.RLock()
+    scheme, ok := registry.schemes[name]
+    registry.RUnlock()
+    if !ok {
+        return nil, errors.New("zkrp: unknown scheme " + name)
+    }
+    return scheme, nil
+}
+
+/*
+Schemes returns the names of the registered schemes, in increasing order.
+*/
+func Schemes() []string {
+    registry.RLock()
+    names := make([]string, 0, len(registry.schemes))
+    for name := range registry.schemes {
+        names = append(names, name)
+    }
+    registry.RUnlock()
+    sort.Strings(names)
+    return names
+}
+
+/*
+Setup computes the parameters of the provers and the key of the verifier of the
+scheme with the given name for the interval [a, b).
+*/
+func Setup(name string, a, b int64) (ProverParams, VerifierKey, error) {
+    scheme, err := Lookup(name)
+    if err != nil {
+        return nil, nil, err
+    }
+    return scheme.Setup(a, b)
+}
+
+/*
+UnmarshalVerifierKey decodes a verifier key of the scheme with the given name,
+encoded with VerifierKey.MarshalBinary.
+*/
+func UnmarshalVerifierKey(name string, data []byte) (VerifierKey, error) {
+    scheme, err := Lookup(name)
+    if err != nil {
+        return nil, err
+    }
+    return scheme.UnmarshalVerifierKey(data)
+}
+
+/*
+BatchVerify returns true if and only if all the proofs are valid. It uses the batch
+verification of the scheme if the key implements BatchVerifier, and verifies the
+proofs one at a time otherwise.
+*/
+func BatchVerify(key VerifierKey, proofs []Proof) (bool, error) {
+    if batch, ok := key.(BatchVerifier); ok {
+        return batch.BatchVerify(proofs)
+    }
+    for _, proof := range proofs {
+        if ok, err := key.Verify(proof); !ok || err != nil {
+            return false, err
+        }
+    }
+    return true, nil
+}
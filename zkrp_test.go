@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2019 ING BANK N.V.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package zkrp_test
+
+import (
+    "bytes"
+    "math/big"
+    "testing"
+
+    "github.com/ing-bank/zkrp"
+    _ "github.com/ing-bank/zkrp/bulletproofs"
+    _ "github.com/ing-bank/zkrp/ccs08"
+)
+
+func TestSchemes(t *testing.T) {
+    names := zkrp.Schemes()
+    if len(names) != 2 || names[0] != "bulletproofs" || names[1] != "ccs08" {
+        t.Fatalf("Assert failure: unexpected schemes %v", names)
+    }
+    r := new(big.Int).SetInt64(12)
+    for _, name := range names {
+        params, key, err := zkrp.Setup(name, -20, 200)
+        if err != nil {
+            t.Fatalf("%s: setup error: %s", name, err)
+        }
+        proof, err := params.Prove(new(big.Int).SetInt64(40), r)
+        if err != nil {
+            t.Fatalf("%s: prove error: %s", name, err)
+        }
+        C, _ := params.Commit(new(big.Int).SetInt64(40), r)
+        c1, _ := C.MarshalBinary()
+        c2, _ := proof.Commitment().MarshalBinary()
+        if !bytes.Equal(c1, c2) {
+            t.Errorf("%s: Assert failure: proof should contain the commitment", name)
+        }
+
+        data, err := proof.MarshalBinary()
+        if err != nil {
+            t.Fatalf("%s: encode error: %s", name, err)
+        }
+        decoded, err := key.UnmarshalProof(data)
+        if err != nil {
+            t.Fatalf("%s: decode error: %s", name, err)
+        }
+        if ok, _ := key.Verify(decoded); !ok {
+            t.Errorf("%s: Assert failure: proof should verify", name)
+        }
+
+        // the verifier key can be transferred without its secret values
+        encoded, err := key.MarshalBinary()
+        if err != nil {
+            t.Fatalf("%s: key encode error: %s", name, err)
+        }
+        loaded, err := zkrp.UnmarshalVerifierKey(name, encoded)
+        if err != nil {
+            t.Fatalf("%s: key decode error: %s", name, err)
+        }
+        proof2, _ := params.Prove(new(big.Int).SetInt64(-7), new(big.Int).SetInt64(30))
+        if ok, _ := zkrp.BatchVerify(loaded, []zkrp.Proof{decoded, proof2}); !ok {
+            t.Errorf("%s: Assert failure: proofs should verify with the decoded key", name)
+        }
+
+        // the commitments are homomorphic
+        sum, err := loaded.AddCommitments(proof.Commitment(), proof2.Commitment())
+        if err != nil {
+            t.Fatalf("%s: add error: %s", name, err)
+        }
+        C, _ = params.Commit(new(big.Int).SetInt64(33), new(big.Int).SetInt64(42))
+        c1, _ = C.MarshalBinary()
+        c2, _ = sum.MarshalBinary()
+        if !bytes.Equal(c1, c2) {
+            t.Errorf("%s: Assert failure: commitments should be homomorphic", name)
+        }
+        if C, err = loaded.UnmarshalCommitment(c1); err != nil {
+            t.Fatalf("%s: commitment decode error: %s", name, err)
+        }
+        if c2, _ = C.MarshalBinary(); !bytes.Equal(c1, c2) {
+            t.Errorf("%s: Assert failure: commitment should be decoded", name)
+        }
+
+        // the prover may either fail or compute an invalid proof
+        if proof, err = params.Prove(new(big.Int).SetInt64(200), r); err == nil {
+            if ok, _ := key.Verify(proof); ok {
+                t.Errorf("%s: Assert failure: secret out of the interval should be rejected", name)
+            }
+        }
+    }
+
+    bp, _, _ := zkrp.Setup("bulletproofs", 0, 100)
+    _, cc, _ := zkrp.Setup("ccs08", 0, 100)
+    proof, _ := bp.Prove(new(big.Int).SetInt64(40), r)
+    if ok, err := cc.Verify(proof); ok || err != zkrp.ErrWrongProofType {
+        t.Errorf("Assert failure: proof of another scheme should be rejected")
+    }
+    if _, _, err := zkrp.Setup("unknown", 0, 100); err == nil {
+        t.Errorf("Assert failure: unknown scheme should be rejected")
+    }
+}
+
+/*
+BenchmarkSchemes compares the registered schemes for the same interval, e.g.:
+    go test -bench Schemes -run none
+*/
+func BenchmarkSchemes(b *testing.B) {
+    r := new(big.Int).SetInt64(12)
+    x := new(big.Int).SetInt64(419835123)
+    for _, name := range zkrp.Schemes() {
+        params, key, err := zkrp.Setup(name, 347184000, 599644800)
+        if err != nil {
+            b.Fatal(err)
+        }
+        proof, _ := params.Prove(x, r)
+        b.Run(name+"/prove", func(b *testing.B) {
+            for i := 0; i < b.N; i++ {
+                _, _ = params.Prove(x, r)
+            }
+        })
+        b.Run(name+"/verify", func(b *testing.B) {
+            for i := 0; i < b.N; i++ {
+                _, _ = key.Verify(proof)
+            }
+        })
+    }
+}